@@ -0,0 +1,784 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"service-weaver-app/components"
+	"service-weaver-app/models"
+)
+
+// registerAPI registers the /api/v1 JSON endpoints for products, orders,
+// warehouses, transfers, reservations, stock alerts, lots, suppliers and
+// purchase orders.
+func registerAPI() {
+	handle("GET /api/v1/products", listProductsHandler)
+	handle("POST /api/v1/products", createProductHandler)
+	handle("GET /api/v1/products/{id}", getProductHandler)
+	handle("PUT /api/v1/products/{id}", replaceProductHandler)
+	handle("PATCH /api/v1/products/{id}", patchProductHandler)
+	handle("DELETE /api/v1/products/{id}", deleteProductHandler)
+	handle("GET /api/v1/products/{id}/stock", getStockHandler)
+	handle("POST /api/v1/products/{id}/stock", adjustStockHandler)
+	handle("GET /api/v1/products/{id}/movements", listMovementsHandler)
+	handle("GET /api/v1/search/products", searchProductsHandler)
+	handle("GET /api/v1/autocomplete/products", autocompleteProductsHandler)
+	handle("GET /api/v1/orders", listOrdersHandler)
+	handle("POST /api/v1/orders", placeOrderHandler)
+	handle("GET /api/v1/orders/{id}", getOrderHandler)
+	handle("POST /api/v1/orders/{id}/status", setOrderStatusHandler)
+	handle("GET /api/v1/orders/{id}/lots", listOrderLotsHandler)
+	handle("GET /api/v1/warehouses", listWarehousesHandler)
+	handle("POST /api/v1/warehouses", createWarehouseHandler)
+	handle("GET /api/v1/transfers", listTransfersHandler)
+	handle("POST /api/v1/transfers", createTransferHandler)
+	handle("GET /api/v1/transfers/{id}", getTransferHandler)
+	handle("POST /api/v1/transfers/{id}/ship", shipTransferHandler)
+	handle("POST /api/v1/transfers/{id}/receive", receiveTransferHandler)
+	handle("GET /api/v1/transfers/{id}/lots", listTransferLotsHandler)
+	handle("POST /api/v1/reservations", createReservationHandler)
+	handle("GET /api/v1/reservations/{id}", getReservationHandler)
+	handle("POST /api/v1/reservations/{id}/confirm", confirmReservationHandler)
+	handle("POST /api/v1/reservations/{id}/release", releaseReservationHandler)
+	handle("GET /api/v1/stock-alerts", listStockAlertsHandler)
+	handle("GET /api/v1/lots", listLotsHandler)
+	handle("POST /api/v1/lots", createLotHandler)
+	handle("GET /api/v1/lots/expiring", listExpiringLotsHandler)
+	handle("POST /api/v1/lots/quarantine-expired", quarantineExpiredLotsHandler)
+	handle("GET /api/v1/lots/{id}", getLotHandler)
+	handle("POST /api/v1/lots/{id}/quarantine", quarantineLotHandler)
+	handle("GET /api/v1/suppliers", listSuppliersHandler)
+	handle("POST /api/v1/suppliers", createSupplierHandler)
+	handle("GET /api/v1/suppliers/{id}", getSupplierHandler)
+	handle("PUT /api/v1/suppliers/{id}", replaceSupplierHandler)
+	handle("DELETE /api/v1/suppliers/{id}", deleteSupplierHandler)
+	handle("GET /api/v1/purchase-orders", listPurchaseOrdersHandler)
+	handle("POST /api/v1/purchase-orders", placePurchaseOrderHandler)
+	handle("GET /api/v1/purchase-orders/{id}", getPurchaseOrderHandler)
+	handle("PUT /api/v1/purchase-orders/{id}", replacePurchaseOrderHandler)
+	handle("DELETE /api/v1/purchase-orders/{id}", deletePurchaseOrderHandler)
+	handle("POST /api/v1/purchase-orders/{id}/send", sendPurchaseOrderHandler)
+	handle("POST /api/v1/purchase-orders/{id}/receive", receivePurchaseOrderHandler)
+	handle("POST /api/v1/purchase-orders/{id}/close", closePurchaseOrderHandler)
+	handle("GET /api/v1/replenishment", listReplenishmentHandler)
+	handle("POST /api/v1/replenishment/purchase-orders", createSuggestedPurchaseOrdersHandler)
+}
+
+// writeJSON responds with status and v encoded as JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// decodeBody decodes the JSON request body into v, responding with a 400
+// and returning false if it is malformed.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeErrorCode(w, http.StatusBadRequest, "bad_request", "Invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func listProductsHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseProductQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := inventory.GetProducts(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func createProductHandler(w http.ResponseWriter, r *http.Request) {
+	var product models.Product
+	if !decodeBody(w, r, &product) {
+		return
+	}
+	if err := inventory.AddProduct(r.Context(), product); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/products/"+product.ID)
+	writeJSON(w, http.StatusCreated, product)
+}
+
+func getProductHandler(w http.ResponseWriter, r *http.Request) {
+	product, err := inventory.GetProduct(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+}
+
+// replaceProductHandler sets every editable field of a product. The body is
+// a complete product; its id may be omitted but must otherwise match the
+// path.
+func replaceProductHandler(w http.ResponseWriter, r *http.Request) {
+	var product models.Product
+	if !decodeBody(w, r, &product) {
+		return
+	}
+	id := r.PathValue("id")
+	if product.ID != "" && product.ID != id {
+		writeErrorCode(w, http.StatusBadRequest, "bad_request", "Product id does not match the path")
+		return
+	}
+	update := models.ProductUpdate{
+		Name:            &product.Name,
+		Description:     &product.Description,
+		Tags:            &product.Tags,
+		Stock:           &product.Stock,
+		Price:           &product.Price,
+		ReorderPoint:    &product.ReorderPoint,
+		ReorderQuantity: &product.ReorderQuantity,
+		SupplierID:      &product.SupplierID,
+		SafetyStock:     &product.SafetyStock,
+	}
+	updateProduct(w, r, id, update)
+}
+
+// patchProductHandler changes only the product fields present in the body.
+func patchProductHandler(w http.ResponseWriter, r *http.Request) {
+	var update models.ProductUpdate
+	if !decodeBody(w, r, &update) {
+		return
+	}
+	updateProduct(w, r, r.PathValue("id"), update)
+}
+
+func updateProduct(w http.ResponseWriter, r *http.Request, id string, update models.ProductUpdate) {
+	product, err := inventory.UpdateProduct(r.Context(), id, update)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, product)
+}
+
+func deleteProductHandler(w http.ResponseWriter, r *http.Request) {
+	if err := inventory.DeleteProduct(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getStockHandler reports a product's stock at each warehouse and in total,
+// now or, given the at parameter, as of that time.
+func getStockHandler(w http.ResponseWriter, r *http.Request) {
+	at, err := optionalTime(r.URL.Query(), "at")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var report models.StockReport
+	if at.IsZero() {
+		report, err = inventory.CheckStockLevels(r.Context(), r.PathValue("id"))
+	} else {
+		report, err = inventory.CheckStockLevelsAt(r.Context(), r.PathValue("id"), at)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// adjustStockHandler adds a quantity, which may be negative, to a product's
+// stock at a warehouse, the default one if the body names none, and
+// responds with the new stock report.
+func adjustStockHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		WarehouseID string `json:"warehouse_id"`
+		Quantity    int    `json:"quantity"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.WarehouseID == "" {
+		req.WarehouseID = components.DefaultWarehouseID
+	}
+	id := r.PathValue("id")
+	if err := inventory.UpdateStockAt(r.Context(), req.WarehouseID, id, req.Quantity); err != nil {
+		writeError(w, err)
+		return
+	}
+	getStockHandler(w, r)
+}
+
+// listMovementsHandler returns a page of the stock ledger entries of a
+// product.
+func listMovementsHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseMovementQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q.ProductID = r.PathValue("id")
+	page, err := inventory.GetStockMovements(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// searchProductsHandler returns the products matching the q parameter, best
+// matches first.
+func searchProductsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	matches, err := inventory.SearchProducts(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, matches)
+}
+
+// autocompleteProductsHandler suggests products for the partial search text
+// in the q parameter.
+func autocompleteProductsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, err := parseLimit(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	suggestions, err := inventory.AutocompleteProducts(r.Context(), r.URL.Query().Get("q"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, suggestions)
+}
+
+func listOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseOrderQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := orders.GetOrders(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// placeOrderHandler creates an order from a body holding its items and,
+// optionally, the warehouse to fill them from.
+func placeOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Items       []models.OrderItem `json:"items"`
+		WarehouseID string             `json:"warehouse_id"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	order, err := orders.CreateOrder(r.Context(), models.Order{Items: req.Items, WarehouseID: req.WarehouseID})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	trackOrderCreated(r.Context(), order)
+	w.Header().Set("Location", "/api/v1/orders/"+order.ID)
+	writeJSON(w, http.StatusCreated, order)
+}
+
+func getOrderHandler(w http.ResponseWriter, r *http.Request) {
+	order, err := orders.GetOrder(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+// listOrderLotsHandler returns the lots an order's lines took stock from.
+func listOrderLotsHandler(w http.ResponseWriter, r *http.Request) {
+	allocations, err := orders.GetOrderLots(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, allocations)
+}
+
+// setOrderStatusHandler moves an order to the status in the body and
+// responds with the updated order.
+func setOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Status string `json:"status"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	id := r.PathValue("id")
+	if err := orders.UpdateOrderStatus(r.Context(), id, req.Status); err != nil {
+		writeError(w, err)
+		return
+	}
+	order, err := orders.GetOrder(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, order)
+}
+
+func listWarehousesHandler(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := inventory.GetWarehouses(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, warehouses)
+}
+
+func createWarehouseHandler(w http.ResponseWriter, r *http.Request) {
+	var warehouse models.Warehouse
+	if !decodeBody(w, r, &warehouse) {
+		return
+	}
+	if err := inventory.AddWarehouse(r.Context(), warehouse); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, warehouse)
+}
+
+// listTransfersHandler returns the transfers matching the status and
+// warehouse_id parameters, newest first.
+func listTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := models.TransferFilter{Status: params.Get("status"), WarehouseID: params.Get("warehouse_id")}
+	transfers, err := inventory.GetTransfers(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transfers)
+}
+
+// createTransferHandler creates a Draft transfer from a body holding its
+// source, destination and lines.
+func createTransferHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SourceID      string                `json:"source_id"`
+		DestinationID string                `json:"destination_id"`
+		Lines         []models.TransferLine `json:"lines"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	transfer, err := inventory.CreateTransfer(r.Context(), models.Transfer{SourceID: req.SourceID, DestinationID: req.DestinationID, Lines: req.Lines})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/transfers/"+transfer.ID)
+	writeJSON(w, http.StatusCreated, transfer)
+}
+
+func getTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, err := inventory.GetTransfer(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transfer)
+}
+
+func shipTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, err := inventory.ShipTransfer(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transfer)
+}
+
+func receiveTransferHandler(w http.ResponseWriter, r *http.Request) {
+	transfer, err := inventory.ReceiveTransfer(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transfer)
+}
+
+// listTransferLotsHandler returns the lots a transfer's lines took stock
+// from at the source.
+func listTransferLotsHandler(w http.ResponseWriter, r *http.Request) {
+	lots, err := inventory.GetTransferLots(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lots)
+}
+
+// createReservationHandler holds stock for a body holding the product,
+// quantity and optionally the warehouse and TTL in seconds.
+func createReservationHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ProductID   string `json:"product_id"`
+		WarehouseID string `json:"warehouse_id"`
+		Quantity    int    `json:"quantity"`
+		TTLSeconds  int    `json:"ttl_seconds"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	reservation, err := inventory.ReserveStock(r.Context(), req.WarehouseID, req.ProductID, req.Quantity, ttl)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/reservations/"+reservation.ID)
+	writeJSON(w, http.StatusCreated, reservation)
+}
+
+func getReservationHandler(w http.ResponseWriter, r *http.Request) {
+	reservation, err := inventory.GetReservation(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func confirmReservationHandler(w http.ResponseWriter, r *http.Request) {
+	reservation, err := inventory.ConfirmReservation(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+func releaseReservationHandler(w http.ResponseWriter, r *http.Request) {
+	reservation, err := inventory.ReleaseReservation(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reservation)
+}
+
+// listStockAlertsHandler returns the open stock alerts, optionally for one
+// product_id, oldest first: the products that need reordering.
+func listStockAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	filter := models.StockAlertFilter{ProductID: r.URL.Query().Get("product_id"), Open: true}
+	alerts, err := inventory.GetStockAlerts(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+// listLotsHandler returns the lots matching the product_id, warehouse_id
+// and status query parameters, earliest expiry first.
+func listLotsHandler(w http.ResponseWriter, r *http.Request) {
+	lots, err := inventory.GetLots(r.Context(), parseLotFilter(r))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lots)
+}
+
+// createLotHandler receives a new lot from a body holding the product, lot
+// number, quantity, expiry time and optionally the warehouse.
+func createLotHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ProductID   string    `json:"product_id"`
+		WarehouseID string    `json:"warehouse_id"`
+		LotNumber   string    `json:"lot_number"`
+		Quantity    int       `json:"quantity"`
+		ExpiresAt   time.Time `json:"expires_at"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	lot, err := inventory.AddLot(r.Context(), models.Lot{
+		ProductID:   req.ProductID,
+		WarehouseID: req.WarehouseID,
+		LotNumber:   req.LotNumber,
+		Quantity:    req.Quantity,
+		ExpiresAt:   req.ExpiresAt,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/lots/"+lot.ID)
+	writeJSON(w, http.StatusCreated, lot)
+}
+
+// listExpiringLotsHandler returns the Active lots expiring within the
+// number of days in the days query parameter.
+func listExpiringLotsHandler(w http.ResponseWriter, r *http.Request) {
+	days, err := parseExpiringDays(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	lots, err := inventory.GetExpiringLots(r.Context(), days)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lots)
+}
+
+// quarantineExpiredLotsHandler quarantines every expired lot and responds
+// with them.
+func quarantineExpiredLotsHandler(w http.ResponseWriter, r *http.Request) {
+	lots, err := inventory.QuarantineExpiredLots(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lots)
+}
+
+func getLotHandler(w http.ResponseWriter, r *http.Request) {
+	lot, err := inventory.GetLot(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lot)
+}
+
+func quarantineLotHandler(w http.ResponseWriter, r *http.Request) {
+	lot, err := inventory.QuarantineLot(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, lot)
+}
+
+func listSuppliersHandler(w http.ResponseWriter, r *http.Request) {
+	suppliers, err := purchasing.GetSuppliers(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, suppliers)
+}
+
+func createSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	var supplier models.Supplier
+	if !decodeBody(w, r, &supplier) {
+		return
+	}
+	if err := purchasing.AddSupplier(r.Context(), supplier); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/suppliers/"+supplier.ID)
+	writeJSON(w, http.StatusCreated, supplier)
+}
+
+func getSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	supplier, err := purchasing.GetSupplier(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, supplier)
+}
+
+// replaceSupplierHandler replaces every field of a supplier. The body may
+// omit the id but must not contradict the path.
+func replaceSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	var supplier models.Supplier
+	if !decodeBody(w, r, &supplier) {
+		return
+	}
+	id := r.PathValue("id")
+	if supplier.ID != "" && supplier.ID != id {
+		writeErrorCode(w, http.StatusBadRequest, "bad_request", "Supplier id does not match the path")
+		return
+	}
+	supplier, err := purchasing.UpdateSupplier(r.Context(), id, supplier)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, supplier)
+}
+
+func deleteSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	if err := purchasing.DeleteSupplier(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purchaseOrderRequest is the body that creates or replaces a purchase
+// order. Received quantities are not accepted; they change only through
+// receipts.
+type purchaseOrderRequest struct {
+	SupplierID  string                     `json:"supplier_id"`
+	WarehouseID string                     `json:"warehouse_id"`
+	ExpectedAt  *time.Time                 `json:"expected_at"`
+	Lines       []models.PurchaseOrderLine `json:"lines"`
+}
+
+func (req purchaseOrderRequest) purchaseOrder() models.PurchaseOrder {
+	return models.PurchaseOrder{SupplierID: req.SupplierID, WarehouseID: req.WarehouseID, ExpectedAt: req.ExpectedAt, Lines: req.Lines}
+}
+
+// listPurchaseOrdersHandler returns the purchase orders matching the status
+// and supplier_id parameters, newest first.
+func listPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	filter := models.PurchaseOrderFilter{Status: params.Get("status"), SupplierID: params.Get("supplier_id")}
+	pos, err := purchasing.GetPurchaseOrders(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pos)
+}
+
+// placePurchaseOrderHandler creates a Draft purchase order.
+func placePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req purchaseOrderRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	po, err := purchasing.CreatePurchaseOrder(r.Context(), req.purchaseOrder())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/v1/purchase-orders/"+po.ID)
+	writeJSON(w, http.StatusCreated, po)
+}
+
+func getPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	po, err := purchasing.GetPurchaseOrder(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+// replacePurchaseOrderHandler replaces the supplier, warehouse, expected
+// date and lines of a Draft purchase order.
+func replacePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req purchaseOrderRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	po, err := purchasing.UpdatePurchaseOrder(r.Context(), r.PathValue("id"), req.purchaseOrder())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+func deletePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if err := purchasing.DeletePurchaseOrder(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sendPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	po, err := purchasing.SendPurchaseOrder(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+// receivePurchaseOrderHandler records a receipt from a body holding the
+// quantity of each product that arrived.
+func receivePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Lines []models.ReceiptLine `json:"lines"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	po, err := purchasing.ReceivePurchaseOrder(r.Context(), r.PathValue("id"), req.Lines)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+func closePurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	po, err := purchasing.ClosePurchaseOrder(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, po)
+}
+
+func listReplenishmentHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseReplenishmentQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	suggestions, err := purchasing.SuggestReplenishment(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, suggestions)
+}
+
+// createSuggestedPurchaseOrdersHandler creates a Draft purchase order per
+// supplier from the suggestions the query parameters select.
+func createSuggestedPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := parseReplenishmentQuery(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	pos, err := purchasing.CreateSuggestedPurchaseOrders(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, pos)
+}
+
+// trackOrderCreated records the metrics of a newly created order.
+func trackOrderCreated(ctx context.Context, order models.Order) {
+	trackMetric(ctx, "orders_created", 1)
+	trackMetric(ctx, "order_total", order.Total)
+	for _, item := range order.Items {
+		trackLabeledMetric(ctx, "units_sold", float64(item.Quantity), map[string]string{"product_id": item.ProductID, "warehouse_id": item.WarehouseID})
+	}
+}
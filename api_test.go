@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"service-weaver-app/components"
+	"service-weaver-app/models"
+)
+
+// discardAnalytics is an Analytics that drops every metric.
+type discardAnalytics struct{ components.Analytics }
+
+func (discardAnalytics) TrackLabeledMetric(ctx context.Context, name string, value float64, labels map[string]string) error {
+	return nil
+}
+
+var registerOnce sync.Once
+
+// newTestServer serves the application routes backed by a fresh in-memory
+// store.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	registerOnce.Do(registerRoutes)
+	store := components.NewMemoryStore()
+	inventory = components.NewInventoryManagement(store)
+	orders = components.NewOrderProcessing(inventory, store)
+	purchasing = components.NewPurchasing(store)
+	analytics = discardAnalytics{}
+
+	server := httptest.NewServer(withActor(http.DefaultServeMux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// call sends a request with an optional JSON body and decodes the JSON
+// response into out, returning the status code.
+func call(t *testing.T, server *httptest.Server, method, path, body string, out interface{}) int {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: decode response: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestAPIProducts(t *testing.T) {
+	server := newTestServer(t)
+
+	var product models.Product
+	if code := call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":5,"price":2.5}`, &product); code != http.StatusCreated {
+		t.Fatalf("create: status %d", code)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":5,"price":2.5}`, &apiErr); code != http.StatusConflict || apiErr.Code != "duplicate_product" {
+		t.Errorf("duplicate create: %d %+v", code, apiErr)
+	}
+
+	if code := call(t, server, "PATCH", "/api/v1/products/p1", `{"stock":7}`, &product); code != http.StatusOK || product.Stock != 7 || product.Name != "widget" {
+		t.Errorf("patch: %d %+v", code, product)
+	}
+	if code := call(t, server, "PUT", "/api/v1/products/p1", `{"name":"gadget","stock":1,"price":3}`, &product); code != http.StatusOK || !reflect.DeepEqual(product, models.Product{ID: "p1", Name: "gadget", Stock: 1, Price: 3}) {
+		t.Errorf("put: %d %+v", code, product)
+	}
+	if code := call(t, server, "PATCH", "/api/v1/products/p1", `{"stock":-1}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_product" {
+		t.Errorf("invalid patch: %d %+v", code, apiErr)
+	}
+
+	var products models.ProductPage
+	if code := call(t, server, "GET", "/api/v1/products", "", &products); code != http.StatusOK || len(products.Products) != 1 {
+		t.Errorf("list: %d %+v", code, products)
+	}
+	if code := call(t, server, "GET", "/api/v1/products?name=gad&max_price=3&sort=-price&limit=1", "", &products); code != http.StatusOK || len(products.Products) != 1 {
+		t.Errorf("filtered list: %d %+v", code, products)
+	}
+	for _, query := range []string{"stock_below=few", "sort=colour", "limit=0", "cursor=nonsense"} {
+		if code := call(t, server, "GET", "/api/v1/products?"+query, "", &apiErr); code != http.StatusBadRequest || apiErr.Code != "invalid_query" {
+			t.Errorf("list with %s: %d %+v", query, code, apiErr)
+		}
+	}
+
+	if code := call(t, server, "DELETE", "/api/v1/products/p1", "", nil); code != http.StatusNoContent {
+		t.Errorf("delete: status %d", code)
+	}
+	if code := call(t, server, "GET", "/api/v1/products/p1", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "product_not_found" {
+		t.Errorf("get deleted: %d %+v", code, apiErr)
+	}
+}
+
+func TestAPIOrders(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":5,"price":2.5}`, nil)
+
+	var order models.Order
+	if code := call(t, server, "POST", "/api/v1/orders", `{"items":[{"product_id":"p1","quantity":2}]}`, &order); code != http.StatusCreated || order.Total != 5 {
+		t.Fatalf("create: %d %+v", code, order)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "POST", "/api/v1/orders", `{"items":[{"product_id":"p1","quantity":10}]}`, &apiErr); code != http.StatusConflict || apiErr.Code != "insufficient_stock" {
+		t.Errorf("oversell: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "DELETE", "/api/v1/products/p1", "", &apiErr); code != http.StatusConflict || apiErr.Code != "product_in_use" {
+		t.Errorf("delete ordered product: %d %+v", code, apiErr)
+	}
+
+	path := "/api/v1/orders/" + order.ID
+	if code := call(t, server, "POST", path+"/status", `{"status":"Confirmed"}`, &order); code != http.StatusOK || order.Status != models.OrderStatusConfirmed {
+		t.Errorf("confirm: %d %+v", code, order)
+	}
+	if code := call(t, server, "POST", path+"/status", `{"status":"Delivered"}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_transition" {
+		t.Errorf("illegal transition: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "GET", path, "", &order); code != http.StatusOK || len(order.Items) != 1 {
+		t.Errorf("get: %d %+v", code, order)
+	}
+
+	var all models.OrderPage
+	if code := call(t, server, "GET", "/api/v1/orders", "", &all); code != http.StatusOK || len(all.Orders) != 1 {
+		t.Errorf("list: %d %+v", code, all)
+	}
+	if code := call(t, server, "GET", "/api/v1/orders?status=Pending&product_id=p1", "", &all); code != http.StatusOK || len(all.Orders) != 0 {
+		t.Errorf("list pending: %d %+v", code, all)
+	}
+	if code := call(t, server, "GET", "/api/v1/orders?from=yesterday", "", &apiErr); code != http.StatusBadRequest || apiErr.Code != "invalid_query" {
+		t.Errorf("list with bad time: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "GET", "/api/v1/orders/999", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "order_not_found" {
+		t.Errorf("get missing: %d %+v", code, apiErr)
+	}
+}
+
+func TestAPISearch(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"Blue widget","tags":["garden"],"stock":5,"price":2.5}`, nil)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p2","name":"Hose","description":"Waters the garden","stock":5,"price":9}`, nil)
+
+	var matches []models.ProductMatch
+	if code := call(t, server, "GET", "/api/v1/search/products?q=gard", "", &matches); code != http.StatusOK || len(matches) != 2 || matches[0].Product.ID != "p1" {
+		t.Errorf("search: %d %+v", code, matches)
+	}
+	var suggestions []models.ProductSuggestion
+	if code := call(t, server, "GET", "/api/v1/autocomplete/products?q=wid", "", &suggestions); code != http.StatusOK || len(suggestions) != 1 || suggestions[0].Name != "Blue widget" {
+		t.Errorf("autocomplete: %d %+v", code, suggestions)
+	}
+	if code := call(t, server, "GET", "/api/v1/autocomplete/products", "", &suggestions); code != http.StatusOK || len(suggestions) != 0 {
+		t.Errorf("autocomplete without text: %d %+v", code, suggestions)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "GET", "/api/v1/search/products?q=gard&limit=1000", "", &apiErr); code != http.StatusBadRequest || apiErr.Code != "invalid_query" {
+		t.Errorf("search with large limit: %d %+v", code, apiErr)
+	}
+}
+
+func TestAPIWarehouses(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":1,"price":2}`, nil)
+
+	var warehouse models.Warehouse
+	if code := call(t, server, "POST", "/api/v1/warehouses", `{"id":"east","name":"East","priority":1}`, &warehouse); code != http.StatusCreated || warehouse.Priority != 1 {
+		t.Fatalf("create: %d %+v", code, warehouse)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "POST", "/api/v1/warehouses", `{"id":"east","name":"East"}`, &apiErr); code != http.StatusConflict || apiErr.Code != "duplicate_warehouse" {
+		t.Errorf("create duplicate: %d %+v", code, apiErr)
+	}
+	var warehouses []models.Warehouse
+	if code := call(t, server, "GET", "/api/v1/warehouses", "", &warehouses); code != http.StatusOK || len(warehouses) != 2 || warehouses[1].ID != "east" {
+		t.Errorf("list: %d %+v", code, warehouses)
+	}
+
+	var report models.StockReport
+	if code := call(t, server, "POST", "/api/v1/products/p1/stock", `{"warehouse_id":"east","quantity":4}`, &report); code != http.StatusOK || report.Total != 5 || len(report.Levels) != 2 {
+		t.Errorf("adjust: %d %+v", code, report)
+	}
+	if code := call(t, server, "POST", "/api/v1/products/p1/stock", `{"warehouse_id":"west","quantity":1}`, &apiErr); code != http.StatusNotFound || apiErr.Code != "warehouse_not_found" {
+		t.Errorf("adjust at missing warehouse: %d %+v", code, apiErr)
+	}
+
+	var order models.Order
+	if code := call(t, server, "POST", "/api/v1/orders", `{"warehouse_id":"east","items":[{"product_id":"p1","quantity":3}]}`, &order); code != http.StatusCreated || order.WarehouseID != "east" || order.Items[0].WarehouseID != "east" {
+		t.Fatalf("order from east: %d %+v", code, order)
+	}
+	want := []models.StockLevel{{WarehouseID: components.DefaultWarehouseID, Quantity: 1, Available: 1}, {WarehouseID: "east", Quantity: 1, Available: 1}}
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 2 || !reflect.DeepEqual(report.Levels, want) {
+		t.Errorf("get: %d %+v", code, report)
+	}
+}
+
+func TestAPITransfers(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":5,"price":2}`, nil)
+	call(t, server, "POST", "/api/v1/warehouses", `{"id":"east","name":"East"}`, nil)
+
+	var transfer models.Transfer
+	body := `{"source_id":"main","destination_id":"east","lines":[{"product_id":"p1","quantity":3}]}`
+	if code := call(t, server, "POST", "/api/v1/transfers", body, &transfer); code != http.StatusCreated || transfer.Status != models.TransferStatusDraft {
+		t.Fatalf("create: %d %+v", code, transfer)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "POST", "/api/v1/transfers", `{"source_id":"main","destination_id":"main","lines":[{"product_id":"p1","quantity":1}]}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_transfer" {
+		t.Errorf("create to the same warehouse: %d %+v", code, apiErr)
+	}
+
+	path := "/api/v1/transfers/" + transfer.ID
+	if code := call(t, server, "POST", path+"/ship", "", &transfer); code != http.StatusOK || transfer.Status != models.TransferStatusInTransit {
+		t.Fatalf("ship: %d %+v", code, transfer)
+	}
+	var report models.StockReport
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 2 || report.InTransit != 3 {
+		t.Errorf("stock in transit: %d %+v", code, report)
+	}
+	if code := call(t, server, "POST", path+"/ship", "", &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_transfer" {
+		t.Errorf("ship again: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "POST", path+"/receive", "", &transfer); code != http.StatusOK || transfer.Status != models.TransferStatusReceived {
+		t.Fatalf("receive: %d %+v", code, transfer)
+	}
+	var lots []models.TransferLot
+	if code := call(t, server, "GET", path+"/lots", "", &lots); code != http.StatusOK || lots == nil || len(lots) != 0 {
+		t.Errorf("lots: %d %+v", code, lots)
+	}
+	if code := call(t, server, "GET", "/api/v1/transfers/999/lots", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "transfer_not_found" {
+		t.Errorf("lots of missing: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 5 || report.InTransit != 0 {
+		t.Errorf("stock after receiving: %d %+v", code, report)
+	}
+
+	var transfers []models.Transfer
+	if code := call(t, server, "GET", "/api/v1/transfers?status=Received&warehouse_id=east", "", &transfers); code != http.StatusOK || len(transfers) != 1 {
+		t.Errorf("list: %d %+v", code, transfers)
+	}
+	if code := call(t, server, "GET", "/api/v1/transfers/999", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "transfer_not_found" {
+		t.Errorf("get missing: %d %+v", code, apiErr)
+	}
+}
+
+func TestAPIStockMovements(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":5,"price":2}`, nil)
+	var order models.Order
+	call(t, server, "POST", "/api/v1/orders", `{"items":[{"product_id":"p1","quantity":2}]}`, &order)
+	call(t, server, "POST", "/api/v1/products/p1/stock", `{"quantity":4}`, nil)
+
+	var page models.MovementPage
+	if code := call(t, server, "GET", "/api/v1/products/p1/movements", "", &page); code != http.StatusOK || len(page.Movements) != 3 {
+		t.Fatalf("list: %d %+v", code, page)
+	}
+	sale := page.Movements[1]
+	if sale.Reason != models.MovementReasonSale || sale.Delta != -2 || sale.Reference != order.ID {
+		t.Errorf("sale movement: %+v", sale)
+	}
+	if code := call(t, server, "GET", "/api/v1/products/p1/movements?reason=adjustment", "", &page); code != http.StatusOK || len(page.Movements) != 1 || page.Movements[0].Delta != 4 {
+		t.Errorf("list adjustments: %d %+v", code, page)
+	}
+
+	var report models.StockReport
+	at := sale.CreatedAt.Format(time.RFC3339Nano)
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock?at="+at, "", &report); code != http.StatusOK || report.Total != 3 {
+		t.Errorf("stock after the sale: %d %+v", code, report)
+	}
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 7 {
+		t.Errorf("current stock: %d %+v", code, report)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock?at=yesterday", "", &apiErr); code != http.StatusBadRequest || apiErr.Code != "invalid_query" {
+		t.Errorf("stock at a bad time: %d %+v", code, apiErr)
+	}
+}
+
+func TestAPIReservations(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":5,"price":2}`, nil)
+
+	var reservation models.Reservation
+	if code := call(t, server, "POST", "/api/v1/reservations", `{"product_id":"p1","quantity":3,"ttl_seconds":60}`, &reservation); code != http.StatusCreated || reservation.Status != models.ReservationStatusActive {
+		t.Fatalf("create: %d %+v", code, reservation)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "POST", "/api/v1/reservations", `{"product_id":"p1","quantity":3}`, &apiErr); code != http.StatusConflict || apiErr.Code != "insufficient_stock" {
+		t.Errorf("reserve held stock: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "POST", "/api/v1/reservations", `{"product_id":"p1","quantity":1,"ttl_seconds":-1}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_reservation" {
+		t.Errorf("create with negative ttl: %d %+v", code, apiErr)
+	}
+	var report models.StockReport
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 5 || report.Reserved != 3 || report.Available != 2 {
+		t.Errorf("stock while held: %d %+v", code, report)
+	}
+
+	path := "/api/v1/reservations/" + reservation.ID
+	if code := call(t, server, "POST", path+"/confirm", "", &reservation); code != http.StatusOK || reservation.Status != models.ReservationStatusConfirmed {
+		t.Fatalf("confirm: %d %+v", code, reservation)
+	}
+	if code := call(t, server, "POST", path+"/release", "", &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_reservation" {
+		t.Errorf("release confirmed: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 2 || report.Reserved != 0 {
+		t.Errorf("stock after confirming: %d %+v", code, report)
+	}
+	if code := call(t, server, "GET", path, "", &reservation); code != http.StatusOK || reservation.Quantity != 3 {
+		t.Errorf("get: %d %+v", code, reservation)
+	}
+	if code := call(t, server, "GET", "/api/v1/reservations/999", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "reservation_not_found" {
+		t.Errorf("get missing: %d %+v", code, apiErr)
+	}
+}
+
+func TestAPIStockAlerts(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":5,"price":2,"reorder_point":2,"reorder_quantity":20}`, nil)
+	var apiErr errorResponse
+	if code := call(t, server, "PATCH", "/api/v1/products/p1", `{"reorder_quantity":-1}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_product" {
+		t.Errorf("negative reorder quantity: %d %+v", code, apiErr)
+	}
+
+	var alerts []models.StockAlert
+	if code := call(t, server, "GET", "/api/v1/stock-alerts", "", &alerts); code != http.StatusOK || len(alerts) != 0 {
+		t.Errorf("list above the reorder point: %d %+v", code, alerts)
+	}
+	call(t, server, "POST", "/api/v1/orders", `{"items":[{"product_id":"p1","quantity":3}]}`, nil)
+	call(t, server, "POST", "/api/v1/orders", `{"items":[{"product_id":"p1","quantity":1}]}`, nil)
+	if code := call(t, server, "GET", "/api/v1/stock-alerts?product_id=p1", "", &alerts); code != http.StatusOK || len(alerts) != 1 || alerts[0].Stock != 2 || alerts[0].ReorderQuantity != 20 {
+		t.Errorf("list after crossing: %d %+v", code, alerts)
+	}
+	call(t, server, "POST", "/api/v1/products/p1/stock", `{"quantity":10}`, nil)
+	if code := call(t, server, "GET", "/api/v1/stock-alerts", "", &alerts); code != http.StatusOK || len(alerts) != 0 {
+		t.Errorf("list after restocking: %d %+v", code, alerts)
+	}
+}
+
+func TestAPIPurchaseOrders(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":1,"price":2}`, nil)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p2","name":"gadget","stock":0,"price":3}`, nil)
+
+	var supplier models.Supplier
+	if code := call(t, server, "POST", "/api/v1/suppliers", `{"id":"acme","name":"Acme","lead_time_days":7}`, &supplier); code != http.StatusCreated || supplier.LeadTimeDays != 7 {
+		t.Fatalf("create supplier: %d %+v", code, supplier)
+	}
+	var apiErr errorResponse
+	if code := call(t, server, "POST", "/api/v1/suppliers", `{"id":"acme","name":"Acme"}`, &apiErr); code != http.StatusConflict || apiErr.Code != "duplicate_supplier" {
+		t.Errorf("duplicate supplier: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "PUT", "/api/v1/suppliers/acme", `{"name":"Acme Ltd","email":"orders@acme.test","lead_time_days":3}`, &supplier); code != http.StatusOK || supplier.Name != "Acme Ltd" || supplier.LeadTimeDays != 3 {
+		t.Errorf("replace supplier: %d %+v", code, supplier)
+	}
+
+	var po models.PurchaseOrder
+	body := `{"supplier_id":"acme","expected_at":"2026-11-02T00:00:00Z","lines":[{"product_id":"p1","quantity":4,"unit_cost":1.25},{"product_id":"p2","quantity":2}]}`
+	if code := call(t, server, "POST", "/api/v1/purchase-orders", body, &po); code != http.StatusCreated || po.Status != models.PurchaseOrderStatusDraft || po.ExpectedAt == nil {
+		t.Fatalf("create purchase order: %d %+v", code, po)
+	}
+	if code := call(t, server, "POST", "/api/v1/purchase-orders", `{"supplier_id":"nobody","lines":[{"product_id":"p1","quantity":1}]}`, &apiErr); code != http.StatusNotFound || apiErr.Code != "supplier_not_found" {
+		t.Errorf("create for a missing supplier: %d %+v", code, apiErr)
+	}
+
+	path := "/api/v1/purchase-orders/" + po.ID
+	if code := call(t, server, "POST", path+"/send", "", &po); code != http.StatusOK || po.Status != models.PurchaseOrderStatusSent {
+		t.Fatalf("send: %d %+v", code, po)
+	}
+	if code := call(t, server, "POST", path+"/receive", `{"lines":[{"product_id":"p9","quantity":1}]}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_receipt" {
+		t.Errorf("receive an unordered product: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "POST", path+"/receive", `{"lines":[{"product_id":"p1","quantity":5}]}`, &po); code != http.StatusOK ||
+		po.Status != models.PurchaseOrderStatusPartiallyReceived || po.Lines[0].Received != 5 || po.Lines[0].OverReceived != 1 {
+		t.Fatalf("receive: %d %+v", code, po)
+	}
+	var report models.StockReport
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 6 {
+		t.Errorf("stock after receiving: %d %+v", code, report)
+	}
+	var page models.MovementPage
+	if code := call(t, server, "GET", "/api/v1/products/p1/movements?reason=receipt", "", &page); code != http.StatusOK || len(page.Movements) != 1 || page.Movements[0].Reference != po.ID {
+		t.Errorf("receipt movements: %d %+v", code, page)
+	}
+	if code := call(t, server, "PUT", path, body, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_purchase_order" {
+		t.Errorf("replace a sent purchase order: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "POST", path+"/close", "", &po); code != http.StatusOK || po.Status != models.PurchaseOrderStatusClosed {
+		t.Errorf("close: %d %+v", code, po)
+	}
+
+	var pos []models.PurchaseOrder
+	if code := call(t, server, "GET", "/api/v1/purchase-orders?supplier_id=acme&status=Closed", "", &pos); code != http.StatusOK || len(pos) != 1 {
+		t.Errorf("list: %d %+v", code, pos)
+	}
+	if code := call(t, server, "DELETE", "/api/v1/suppliers/acme", "", &apiErr); code != http.StatusConflict || apiErr.Code != "supplier_in_use" {
+		t.Errorf("delete a supplier in use: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "GET", "/api/v1/purchase-orders/999", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "purchase_order_not_found" {
+		t.Errorf("get missing: %d %+v", code, apiErr)
+	}
+}
+
+func TestAPIReplenishment(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/suppliers", `{"id":"acme","name":"Acme","lead_time_days":5}`, nil)
+	var product models.Product
+	if code := call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"widget","stock":10,"price":2,"supplier_id":"acme","safety_stock":2}`, &product); code != http.StatusCreated || product.SupplierID != "acme" {
+		t.Fatalf("create product: %d %+v", code, product)
+	}
+	call(t, server, "POST", "/api/v1/products", `{"id":"p2","name":"gadget","stock":0,"price":3,"safety_stock":1}`, nil)
+	call(t, server, "POST", "/api/v1/orders", `{"items":[{"product_id":"p1","quantity":7}]}`, nil)
+
+	var apiErr errorResponse
+	if code := call(t, server, "PATCH", "/api/v1/products/p2", `{"supplier_id":"nobody"}`, &apiErr); code != http.StatusNotFound || apiErr.Code != "supplier_not_found" {
+		t.Errorf("patch to a missing supplier: %d %+v", code, apiErr)
+	}
+	var page models.ProductPage
+	if code := call(t, server, "GET", "/api/v1/products?supplier_id=acme", "", &page); code != http.StatusOK || len(page.Products) != 1 || page.Products[0].ID != "p1" {
+		t.Errorf("list by supplier: %d %+v", code, page)
+	}
+
+	var suggestions []models.ReplenishmentSuggestion
+	if code := call(t, server, "GET", "/api/v1/replenishment?window_days=7", "", &suggestions); code != http.StatusOK || len(suggestions) != 2 ||
+		suggestions[0].ProductID != "p1" || suggestions[0].ReorderLevel != 7 || suggestions[0].SuggestedQuantity != 9 {
+		t.Fatalf("suggestions: %d %+v", code, suggestions)
+	}
+	if code := call(t, server, "GET", "/api/v1/replenishment?window_days=0", "", &apiErr); code != http.StatusBadRequest || apiErr.Code != "invalid_query" {
+		t.Errorf("zero window: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "GET", "/api/v1/replenishment?supplier_id=nobody", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "supplier_not_found" {
+		t.Errorf("missing supplier: %d %+v", code, apiErr)
+	}
+
+	// p2 has no supplier, so only p1 is ordered.
+	var pos []models.PurchaseOrder
+	if code := call(t, server, "POST", "/api/v1/replenishment/purchase-orders?window_days=7", "", &pos); code != http.StatusCreated || len(pos) != 1 ||
+		pos[0].SupplierID != "acme" || len(pos[0].Lines) != 1 || pos[0].Lines[0].Quantity != 9 {
+		t.Errorf("create purchase orders: %d %+v", code, pos)
+	}
+}
+
+func TestAPILots(t *testing.T) {
+	server := newTestServer(t)
+	call(t, server, "POST", "/api/v1/products", `{"id":"p1","name":"yoghurt","stock":0,"price":2}`, nil)
+	soon := time.Now().UTC().AddDate(0, 0, 3).Format(time.RFC3339)
+	later := time.Now().UTC().AddDate(0, 0, 60).Format(time.RFC3339)
+	past := time.Now().UTC().AddDate(0, 0, -1).Format(time.RFC3339)
+
+	var lot models.Lot
+	if code := call(t, server, "POST", "/api/v1/lots", `{"product_id":"p1","lot_number":"L2","quantity":3,"expires_at":"`+later+`"}`, &lot); code != http.StatusCreated || lot.WarehouseID != components.DefaultWarehouseID {
+		t.Fatalf("create: %d %+v", code, lot)
+	}
+	var soonLot models.Lot
+	call(t, server, "POST", "/api/v1/lots", `{"product_id":"p1","lot_number":"L1","quantity":2,"expires_at":"`+soon+`"}`, &soonLot)
+	var apiErr errorResponse
+	if code := call(t, server, "POST", "/api/v1/lots", `{"product_id":"p1","lot_number":"L1","quantity":1,"expires_at":"`+later+`"}`, &apiErr); code != http.StatusConflict || apiErr.Code != "duplicate_lot" {
+		t.Errorf("create duplicate: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "POST", "/api/v1/lots", `{"product_id":"p1","lot_number":"L3","quantity":1}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_lot" {
+		t.Errorf("create without expiry: %d %+v", code, apiErr)
+	}
+
+	var order models.Order
+	if code := call(t, server, "POST", "/api/v1/orders", `{"items":[{"product_id":"p1","quantity":3}]}`, &order); code != http.StatusCreated {
+		t.Fatalf("place order: %d", code)
+	}
+	var allocations []models.LotAllocation
+	if code := call(t, server, "GET", "/api/v1/orders/"+order.ID+"/lots", "", &allocations); code != http.StatusOK || len(allocations) != 2 ||
+		allocations[0].LotNumber != "L1" || allocations[0].Quantity != 2 || allocations[1].LotNumber != "L2" || allocations[1].Quantity != 1 {
+		t.Errorf("order lots: %d %+v", code, allocations)
+	}
+
+	var lots []models.Lot
+	if code := call(t, server, "GET", "/api/v1/lots/expiring?days=7", "", &lots); code != http.StatusOK || len(lots) != 1 || lots[0].ID != soonLot.ID {
+		t.Errorf("expiring within 7 days: %d %+v", code, lots)
+	}
+	if code := call(t, server, "GET", "/api/v1/lots/expiring?days=-1", "", &apiErr); code != http.StatusBadRequest || apiErr.Code != "invalid_query" {
+		t.Errorf("expiring with negative days: %d %+v", code, apiErr)
+	}
+
+	path := "/api/v1/lots/" + lot.ID
+	if code := call(t, server, "POST", path+"/quarantine", "", &lot); code != http.StatusOK || lot.Status != models.LotStatusQuarantined || lot.Quantity != 2 {
+		t.Fatalf("quarantine: %d %+v", code, lot)
+	}
+	if code := call(t, server, "POST", path+"/quarantine", "", &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_lot" {
+		t.Errorf("quarantine twice: %d %+v", code, apiErr)
+	}
+	var report models.StockReport
+	if code := call(t, server, "GET", "/api/v1/products/p1/stock", "", &report); code != http.StatusOK || report.Total != 0 {
+		t.Errorf("stock after quarantine: %d %+v", code, report)
+	}
+
+	if code := call(t, server, "POST", "/api/v1/lots", `{"product_id":"p1","lot_number":"OLD","quantity":1,"expires_at":"`+past+`"}`, &apiErr); code != http.StatusUnprocessableEntity || apiErr.Code != "invalid_lot" {
+		t.Errorf("create expired: %d %+v", code, apiErr)
+	}
+	if code := call(t, server, "POST", "/api/v1/lots/quarantine-expired", "", &lots); code != http.StatusOK || len(lots) != 0 {
+		t.Errorf("quarantine expired: %d %+v", code, lots)
+	}
+	if code := call(t, server, "GET", "/api/v1/lots?product_id=p1&status=Quarantined", "", &lots); code != http.StatusOK || len(lots) != 1 {
+		t.Errorf("list quarantined: %d %+v", code, lots)
+	}
+	if code := call(t, server, "GET", "/api/v1/lots/999", "", &apiErr); code != http.StatusNotFound || apiErr.Code != "lot_not_found" {
+		t.Errorf("get missing: %d %+v", code, apiErr)
+	}
+}
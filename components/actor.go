@@ -0,0 +1,23 @@
+package components
+
+import "context"
+
+// SystemActor is recorded for changes made without an actor in the context.
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx that records actor as the user or process
+// responsible for changes made with it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, or SystemActor if there
+// is none.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
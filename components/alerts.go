@@ -0,0 +1,106 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+// StockAlertInterval is how often DispatchStockAlerts announces new stock
+// alerts.
+const StockAlertInterval = 5 * time.Second
+
+// watchReorderPoint opens a stock alert for a product whose stock has
+// fallen to its reorder point, unless one is already open, and resolves the
+// open alert of a product whose stock is above it. Stock in transit between
+// warehouses counts, since moving it does not make the product run out. It
+// runs after every change to a product's stock or reorder point, so each
+// crossing of the reorder point opens exactly one alert.
+func watchReorderPoint(ctx context.Context, tx StoreTx, product models.Product) error {
+	inTransit, err := tx.InTransitStock(ctx, product.ID)
+	if err != nil {
+		return fmt.Errorf("could not fetch in-transit stock: %w", err)
+	}
+	if product.ReorderPoint > 0 && product.Stock+inTransit <= product.ReorderPoint {
+		alert := models.StockAlert{
+			ProductID:       product.ID,
+			Stock:           product.Stock,
+			ReorderPoint:    product.ReorderPoint,
+			ReorderQuantity: product.ReorderQuantity,
+		}
+		if _, err := tx.OpenStockAlert(ctx, &alert); err != nil {
+			return fmt.Errorf("could not open stock alert: %w", err)
+		}
+		return nil
+	}
+	if err := tx.ResolveStockAlert(ctx, product.ID); err != nil {
+		return fmt.Errorf("could not resolve stock alert: %w", err)
+	}
+	return nil
+}
+
+// GetStockAlerts returns the stock alerts matching filter, oldest first.
+// The open alerts are the products that need reordering.
+func (im *InventoryManagementImpl) GetStockAlerts(ctx context.Context, filter models.StockAlertFilter) ([]models.StockAlert, error) {
+	var alerts []models.StockAlert
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		alerts, err = tx.ListStockAlerts(ctx, filter)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch stock alerts: %w", err)
+	}
+	if alerts == nil {
+		alerts = []models.StockAlert{}
+	}
+	return alerts, nil
+}
+
+// NotifyStockAlerts calls notify once for every stock alert that has not
+// been announced yet, oldest first, and returns how many it announced. Each
+// alert is marked as notified before notify is called, so that concurrent
+// callers never announce the same alert twice.
+func (im *InventoryManagementImpl) NotifyStockAlerts(ctx context.Context, notify func(ctx context.Context, alert models.StockAlert)) (int, error) {
+	pending, err := im.GetStockAlerts(ctx, models.StockAlertFilter{Unnotified: true})
+	if err != nil {
+		return 0, err
+	}
+	notified := 0
+	for _, alert := range pending {
+		var claimed bool
+		err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+			var err error
+			claimed, err = tx.MarkStockAlertNotified(ctx, alert.ID)
+			return err
+		})
+		if err != nil {
+			return notified, fmt.Errorf("could not mark stock alert %s notified: %w", alert.ID, err)
+		}
+		if claimed {
+			notify(ctx, alert)
+			notified++
+		}
+	}
+	return notified, nil
+}
+
+// DispatchStockAlerts calls NotifyStockAlerts with notify every interval
+// until ctx is done, logging failures.
+func DispatchStockAlerts(ctx context.Context, inventory InventoryManagement, interval time.Duration, notify func(ctx context.Context, alert models.StockAlert)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := inventory.NotifyStockAlerts(ctx, notify); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to dispatch stock alerts: %v", err)
+			}
+		}
+	}
+}
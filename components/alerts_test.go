@@ -0,0 +1,152 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestStockAlerts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		productID := h.productID()
+		product := models.Product{ID: productID, Name: "watched", Stock: 5, Price: 1, ReorderPoint: 3, ReorderQuantity: 10}
+		if err := inventory.AddProduct(ctx, product); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		alerts := func(filter models.StockAlertFilter) []models.StockAlert {
+			t.Helper()
+			filter.ProductID = productID
+			alerts, err := inventory.GetStockAlerts(ctx, filter)
+			if err != nil {
+				t.Fatalf("GetStockAlerts: %v", err)
+			}
+			return alerts
+		}
+		// notify announces the pending alerts and returns those of the
+		// product; other tests may have left alerts of their own.
+		notify := func() []models.StockAlert {
+			t.Helper()
+			var notified []models.StockAlert
+			_, err := inventory.NotifyStockAlerts(ctx, func(ctx context.Context, alert models.StockAlert) {
+				if alert.ProductID == productID {
+					notified = append(notified, alert)
+				}
+			})
+			if err != nil {
+				t.Fatalf("NotifyStockAlerts: %v", err)
+			}
+			return notified
+		}
+
+		negative := -1
+		if _, err := inventory.UpdateProduct(ctx, productID, models.ProductUpdate{ReorderPoint: &negative}); !errors.Is(err, ErrInvalidProduct) {
+			t.Errorf("UpdateProduct(negative reorder point) error = %v, want ErrInvalidProduct", err)
+		}
+
+		if err := inventory.UpdateStock(ctx, productID, -1); err != nil {
+			t.Fatalf("UpdateStock: %v", err)
+		}
+		if got := alerts(models.StockAlertFilter{}); len(got) != 0 {
+			t.Errorf("alerts above the reorder point = %+v, want none", got)
+		}
+
+		// Crossing the reorder point raises one alert, however many stock
+		// changes follow while the stock stays at or below it.
+		for _, quantity := range []int{2, 1} {
+			if _, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: quantity}}}); err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			}
+		}
+		open := alerts(models.StockAlertFilter{Open: true})
+		if len(open) != 1 || open[0].Stock != 2 || open[0].ReorderPoint != 3 || open[0].ReorderQuantity != 10 {
+			t.Fatalf("open alerts after crossing = %+v, want one at stock 2", open)
+		}
+		if got := notify(); len(got) != 1 || got[0].ID != open[0].ID {
+			t.Errorf("notified = %+v, want the open alert", got)
+		}
+		if got := notify(); len(got) != 0 {
+			t.Errorf("notified again = %+v, want none", got)
+		}
+
+		// Restocking resolves the alert, so the next crossing raises another.
+		if err := inventory.UpdateStock(ctx, productID, 5); err != nil {
+			t.Fatalf("UpdateStock: %v", err)
+		}
+		if got := alerts(models.StockAlertFilter{Open: true}); len(got) != 0 {
+			t.Errorf("open alerts after restocking = %+v, want none", got)
+		}
+		if got := alerts(models.StockAlertFilter{}); len(got) != 1 || got[0].ResolvedAt == nil || got[0].NotifiedAt == nil {
+			t.Errorf("alerts after restocking = %+v, want one notified and resolved", got)
+		}
+		point := 8
+		if _, err := inventory.UpdateProduct(ctx, productID, models.ProductUpdate{ReorderPoint: &point}); err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+		if got := alerts(models.StockAlertFilter{Open: true}); len(got) != 1 || got[0].Stock != 6 || got[0].ReorderPoint != 8 {
+			t.Errorf("open alerts after raising the reorder point = %+v, want one at stock 6", got)
+		}
+		if got := notify(); len(got) != 1 {
+			t.Errorf("notified after raising the reorder point = %+v, want one alert", got)
+		}
+	})
+}
+
+func TestStockAlertsCountInTransitStock(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+
+		destination := h.productID()
+		if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: destination, Name: "alerts", Priority: 3000}); err != nil {
+			t.Fatalf("AddWarehouse: %v", err)
+		}
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "moved", Stock: 5, Price: 1, ReorderPoint: 3}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		open := func() []models.StockAlert {
+			t.Helper()
+			alerts, err := inventory.GetStockAlerts(ctx, models.StockAlertFilter{ProductID: productID, Open: true})
+			if err != nil {
+				t.Fatalf("GetStockAlerts: %v", err)
+			}
+			return alerts
+		}
+
+		transfer, err := inventory.CreateTransfer(ctx, models.Transfer{
+			SourceID:      DefaultWarehouseID,
+			DestinationID: destination,
+			Lines:         []models.TransferLine{{ProductID: productID, Quantity: 4}},
+		})
+		if err != nil {
+			t.Fatalf("CreateTransfer: %v", err)
+		}
+		// Shipping leaves one unit in the warehouses, but the four in transit
+		// keep the product above its reorder point.
+		if _, err := inventory.ShipTransfer(ctx, transfer.ID); err != nil {
+			t.Fatalf("ShipTransfer: %v", err)
+		}
+		if got := open(); len(got) != 0 {
+			t.Errorf("open alerts while in transit = %+v, want none", got)
+		}
+		if _, err := inventory.ReceiveTransfer(ctx, transfer.ID); err != nil {
+			t.Fatalf("ReceiveTransfer: %v", err)
+		}
+		if got := open(); len(got) != 0 {
+			t.Errorf("open alerts after receiving = %+v, want none", got)
+		}
+
+		if err := inventory.UpdateStockAt(ctx, destination, productID, -2); err != nil {
+			t.Fatalf("UpdateStockAt: %v", err)
+		}
+		if got := open(); len(got) != 1 || got[0].Stock != 3 {
+			t.Errorf("open alerts after selling = %+v, want one at stock 3", got)
+		}
+	})
+}
@@ -2,28 +2,417 @@ package components
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"service-weaver-app/database"
 	"service-weaver-app/models"
 )
 
+const (
+	// metricBufferSize is the number of tracked metrics that may wait for
+	// the background writer before TrackMetric starts dropping them.
+	metricBufferSize = 4096
+	// metricBatchSize is the largest number of metrics written per INSERT.
+	metricBatchSize = 200
+	// metricFlushInterval is how long a partial batch may wait before it is
+	// written.
+	metricFlushInterval = time.Second
+	// maxMetricValue bounds the magnitude of a metric value: the value
+	// column is NUMERIC(10,2), which holds less than 1e8 once rounded to
+	// cents.
+	maxMetricValue = 1e8
+)
+
+// AnalyticsImpl is the implementation of Analytics. Tracked metrics are
+// buffered and written to the metrics table in batches by a background
+// goroutine, so TrackMetric never waits on the database.
 type AnalyticsImpl struct {
-	sync.Mutex
-	metrics []models.Metric
+	db      *sql.DB
+	dialect database.Dialect
+	pending chan models.Metric
+	flushes chan chan error
+	done    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
 }
 
-func NewAnalytics() *AnalyticsImpl {
-	return &AnalyticsImpl{metrics: []models.Metric{}}
+// NewAnalytics initializes a new AnalyticsImpl instance for a database of
+// the given dialect and starts its background writer. Call Close to write
+// any buffered metrics and stop it.
+func NewAnalytics(db *sql.DB, dialect database.Dialect) *AnalyticsImpl {
+	a := &AnalyticsImpl{
+		db:      db,
+		dialect: dialect,
+		pending: make(chan models.Metric, metricBufferSize),
+		flushes: make(chan chan error),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go a.run()
+	return a
 }
 
+// TrackMetric queues an unlabeled metric for writing.
 func (a *AnalyticsImpl) TrackMetric(ctx context.Context, name string, value float64) error {
-	a.Lock()
-	defer a.Unlock()
-	a.metrics = append(a.metrics, models.Metric{
-		Name:  name,
-		Value: value,
-		Time:  time.Now().Unix(),
-	})
+	return a.TrackLabeledMetric(ctx, name, value, nil)
+}
+
+// TrackLabeledMetric queues a metric with key/value labels for writing. It
+// returns an error without blocking if the write buffer is full, and
+// rejects values the metrics table cannot store, which would otherwise fail
+// the whole batch they are written in.
+func (a *AnalyticsImpl) TrackLabeledMetric(ctx context.Context, name string, value float64, labels map[string]string) error {
+	// Written this way round so that NaN is rejected too.
+	if !(math.Abs(math.Round(value*100)/100) < maxMetricValue) {
+		return fmt.Errorf("value %v of %s is out of range", value, name)
+	}
+	metric := models.Metric{
+		Name:   name,
+		Value:  value,
+		Time:   time.Now().Unix(),
+		Labels: labels,
+	}
+	select {
+	case <-a.done:
+		return fmt.Errorf("analytics is closed")
+	default:
+	}
+	select {
+	case a.pending <- metric:
+		return nil
+	default:
+		return fmt.Errorf("metric buffer full, dropped %s", name)
+	}
+}
+
+// GetMetrics returns the metrics matching q, oldest first. Buffered metrics
+// are written before querying so they are included in the result.
+func (a *AnalyticsImpl) GetMetrics(ctx context.Context, q models.MetricQuery) ([]models.Metric, error) {
+	if err := a.Flush(ctx); err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	if q.Name != "" {
+		args = append(args, q.Name)
+		conditions = append(conditions, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if !q.From.IsZero() {
+		args = append(args, q.From.UTC())
+		conditions = append(conditions, fmt.Sprintf("time >= $%d", len(args)))
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To.UTC())
+		conditions = append(conditions, fmt.Sprintf("time < $%d", len(args)))
+	}
+	if len(q.Labels) > 0 {
+		var err error
+		if args, conditions, err = a.labelConditions(q.Labels, args, conditions); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `SELECT name, value, time, labels FROM metrics`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY time, id"
+
+	rows, err := a.db.QueryContext(ctx, a.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var metrics []models.Metric
+	for rows.Next() {
+		var metric models.Metric
+		var at time.Time
+		var labels []byte
+		if err := rows.Scan(&metric.Name, &metric.Value, &at, &labels); err != nil {
+			return nil, fmt.Errorf("could not scan metric: %w", err)
+		}
+		metric.Time = at.Unix()
+		if err := json.Unmarshal(labels, &metric.Labels); err != nil {
+			return nil, fmt.Errorf("could not decode metric labels: %w", err)
+		}
+		if len(metric.Labels) == 0 {
+			metric.Labels = nil
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+// AggregateMetrics returns the count, sum, average, minimum, maximum and 95th
+// percentile of a metric for each bucket in the query's time range, oldest
+// bucket first. When the query groups by labels there is one point per
+// bucket and combination of label values. Buckets without any values are
+// omitted.
+func (a *AnalyticsImpl) AggregateMetrics(ctx context.Context, q models.AggregateQuery) ([]models.AggregatePoint, error) {
+	if q.Name == "" {
+		return nil, fmt.Errorf("%w: metric name is required", ErrInvalidQuery)
+	}
+	switch q.Bucket {
+	case models.BucketMinute, models.BucketHour, models.BucketDay:
+	default:
+		return nil, fmt.Errorf("%w: invalid bucket %q", ErrInvalidQuery, q.Bucket)
+	}
+	if err := a.Flush(ctx); err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{q.Name}
+	conditions := []string{"name = $1"}
+	if !q.From.IsZero() {
+		args = append(args, q.From.UTC())
+		conditions = append(conditions, fmt.Sprintf("time >= $%d", len(args)))
+	}
+	if !q.To.IsZero() {
+		args = append(args, q.To.UTC())
+		conditions = append(conditions, fmt.Sprintf("time < $%d", len(args)))
+	}
+	if len(q.Labels) > 0 {
+		var err error
+		if args, conditions, err = a.labelConditions(q.Labels, args, conditions); err != nil {
+			return nil, err
+		}
+	}
+
+	groups := []string{"bucket"}
+	columns := []string{a.bucketColumn(q.Bucket) + " AS bucket"}
+	for i, key := range q.GroupBy {
+		args = append(args, key)
+		column := fmt.Sprintf("label_%d", i)
+		columns = append(columns, fmt.Sprintf("%s AS %s", a.labelValue(len(args)), column))
+		groups = append(groups, column)
+	}
+
+	var query string
+	if a.dialect == database.SQLite {
+		query = sqliteAggregateQuery(columns, conditions, groups)
+	} else {
+		query = `SELECT ` + strings.Join(columns, ", ") + `,
+				COUNT(*), SUM(value), AVG(value), MIN(value), MAX(value),
+				percentile_cont(0.95) WITHIN GROUP (ORDER BY value)
+			FROM metrics
+			WHERE ` + strings.Join(conditions, " AND ") + `
+			GROUP BY ` + strings.Join(groups, ", ") + `
+			ORDER BY ` + strings.Join(groups, ", ")
+	}
+
+	rows, err := a.db.QueryContext(ctx, a.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not aggregate metrics: %w", err)
+	}
+	defer rows.Close()
+
+	var points []models.AggregatePoint
+	for rows.Next() {
+		var point models.AggregatePoint
+		var bucket int64
+		values := make([]sql.NullString, len(q.GroupBy))
+		dest := []interface{}{&bucket}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		dest = append(dest, &point.Count, &point.Sum, &point.Avg, &point.Min, &point.Max, &point.P95)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("could not scan aggregate: %w", err)
+		}
+		point.Bucket = bucket
+		if len(q.GroupBy) > 0 {
+			point.Labels = make(map[string]string, len(q.GroupBy))
+			for i, key := range q.GroupBy {
+				point.Labels[key] = values[i].String
+			}
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// labelConditions adds a condition matching metrics that carry every one of
+// labels to conditions, appending its parameters to args.
+func (a *AnalyticsImpl) labelConditions(labels map[string]string, args []interface{}, conditions []string) ([]interface{}, []string, error) {
+	if a.dialect == database.SQLite {
+		keys := make([]string, 0, len(labels))
+		for key := range labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			args = append(args, key, labels[key])
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", a.labelValue(len(args)-1), len(args)))
+		}
+		return args, conditions, nil
+	}
+
+	encoded, err := json.Marshal(labels)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not encode labels: %w", err)
+	}
+	args = append(args, string(encoded))
+	conditions = append(conditions, fmt.Sprintf("labels @> $%d::jsonb", len(args)))
+	return args, conditions, nil
+}
+
+// labelValue returns the SQL expression for the value of the label whose
+// key is query parameter n, or NULL if a metric lacks the label. The key is
+// bound rather than spliced into a JSON path, so it may hold any character.
+func (a *AnalyticsImpl) labelValue(n int) string {
+	if a.dialect == database.SQLite {
+		return fmt.Sprintf("(SELECT value FROM json_each(metrics.labels) WHERE key = $%d)", n)
+	}
+	return fmt.Sprintf("labels->>$%d", n)
+}
+
+// bucketColumn returns the SQL expression for the start of the bucket a
+// metric falls in, in Unix seconds. bucket must be a valid models.Bucket*
+// value.
+func (a *AnalyticsImpl) bucketColumn(bucket string) string {
+	if a.dialect == database.SQLite {
+		seconds := map[string]int{models.BucketMinute: 60, models.BucketHour: 3600, models.BucketDay: 86400}[bucket]
+		return fmt.Sprintf("(CAST(strftime('%%s', time) AS INTEGER) / %d * %d)", seconds, seconds)
+	}
+	return fmt.Sprintf("CAST(EXTRACT(EPOCH FROM date_trunc('%s', time)) AS BIGINT)", bucket)
+}
+
+// sqliteAggregateQuery builds the aggregation query for SQLite, which lacks
+// percentile_cont. The 95th percentile is interpolated between the two
+// values around rank 1 + 0.95 * (count - 1) the same way percentile_cont
+// does.
+func sqliteAggregateQuery(columns, conditions, groups []string) string {
+	partition := strings.Join(groups, ", ")
+	rank := "(1 + 0.95 * (n - 1))"
+	lower := fmt.Sprintf("MAX(CASE WHEN rn = CAST(%s AS INTEGER) THEN value END)", rank)
+	upper := fmt.Sprintf("MAX(CASE WHEN rn = CAST(%s AS INTEGER) + 1 THEN value END)", rank)
+	return `WITH selected AS (
+			SELECT ` + strings.Join(columns, ", ") + `, value
+			FROM metrics
+			WHERE ` + strings.Join(conditions, " AND ") + `
+		), ranked AS (
+			SELECT *,
+				ROW_NUMBER() OVER (PARTITION BY ` + partition + ` ORDER BY value) AS rn,
+				COUNT(*) OVER (PARTITION BY ` + partition + `) AS n
+			FROM selected
+		)
+		SELECT ` + partition + `,
+			COUNT(*), SUM(value), AVG(value), MIN(value), MAX(value),
+			` + lower + ` + (MAX(` + rank + `) - CAST(MAX(` + rank + `) AS INTEGER)) * (COALESCE(` + upper + `, ` + lower + `) - ` + lower + `)
+		FROM ranked
+		GROUP BY ` + partition + `
+		ORDER BY ` + partition
+}
+
+// Flush writes every metric tracked so far to the database.
+func (a *AnalyticsImpl) Flush(ctx context.Context) error {
+	reply := make(chan error, 1)
+	select {
+	case a.flushes <- reply:
+	case <-a.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes any buffered metrics and stops the background writer.
+func (a *AnalyticsImpl) Close() error {
+	a.once.Do(func() { close(a.done) })
+	<-a.stopped
+	return nil
+}
+
+// run collects tracked metrics into batches and writes them when a batch is
+// full, when the flush interval elapses, on Flush and on Close.
+func (a *AnalyticsImpl) run() {
+	defer close(a.stopped)
+
+	ticker := time.NewTicker(metricFlushInterval)
+	defer ticker.Stop()
+
+	var batch []models.Metric
+	write := func() error {
+		// Pick up everything already queued so a flush covers every
+		// metric tracked before it was requested.
+		for drained := false; !drained; {
+			select {
+			case metric := <-a.pending:
+				batch = append(batch, metric)
+			default:
+				drained = true
+			}
+		}
+		var err error
+		for len(batch) > 0 {
+			n := min(len(batch), metricBatchSize)
+			if err = a.writeBatch(batch[:n]); err != nil {
+				log.Printf("Failed to write %d metrics: %v", n, err)
+			}
+			batch = batch[n:]
+		}
+		batch = nil
+		return err
+	}
+
+	for {
+		select {
+		case metric := <-a.pending:
+			batch = append(batch, metric)
+			if len(batch) >= metricBatchSize {
+				write()
+			}
+		case <-ticker.C:
+			write()
+		case reply := <-a.flushes:
+			reply <- write()
+		case <-a.done:
+			write()
+			return
+		}
+	}
+}
+
+// writeBatch inserts metrics with a single multi-row INSERT.
+func (a *AnalyticsImpl) writeBatch(metrics []models.Metric) error {
+	// SQLite keeps labels as JSON text.
+	cast := "::jsonb"
+	if a.dialect == database.SQLite {
+		cast = ""
+	}
+	values := make([]string, 0, len(metrics))
+	args := make([]interface{}, 0, 4*len(metrics))
+	for _, m := range metrics {
+		labels := []byte("{}")
+		if len(m.Labels) > 0 {
+			var err error
+			if labels, err = json.Marshal(m.Labels); err != nil {
+				return fmt.Errorf("could not encode labels of %s: %w", m.Name, err)
+			}
+		}
+		n := len(args)
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d%s)", n+1, n+2, n+3, n+4, cast))
+		args = append(args, m.Name, m.Value, time.Unix(m.Time, 0).UTC(), string(labels))
+	}
+	query := `INSERT INTO metrics (name, value, time, labels) VALUES ` + strings.Join(values, ", ")
+	if _, err := a.db.Exec(a.dialect.Rebind(query), args...); err != nil {
+		return fmt.Errorf("could not insert metrics: %w", err)
+	}
 	return nil
 }
@@ -0,0 +1,163 @@
+package components
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"service-weaver-app/database"
+	"service-weaver-app/models"
+)
+
+func TestAnalyticsPersistsMetrics(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *sql.DB, dialect database.Dialect) {
+		ctx := context.Background()
+
+		name := fmt.Sprintf("test_metric_%d", rand.Int31())
+		t.Cleanup(func() { db.Exec(`DELETE FROM metrics WHERE name = $1`, name) })
+
+		analytics := NewAnalytics(db, dialect)
+		defer analytics.Close()
+
+		start := time.Now().Add(-time.Second)
+		for _, v := range []float64{1, 2.5, 4} {
+			if err := analytics.TrackMetric(ctx, name, v); err != nil {
+				t.Fatalf("TrackMetric: %v", err)
+			}
+		}
+
+		metrics, err := analytics.GetMetrics(ctx, models.MetricQuery{Name: name, From: start})
+		if err != nil {
+			t.Fatalf("GetMetrics: %v", err)
+		}
+		if len(metrics) != 3 {
+			t.Fatalf("got %d metrics, want 3", len(metrics))
+		}
+		if metrics[1].Value != 2.5 {
+			t.Errorf("second metric value = %v, want 2.5", metrics[1].Value)
+		}
+
+		metrics, err = analytics.GetMetrics(ctx, models.MetricQuery{Name: name, To: start})
+		if err != nil {
+			t.Fatalf("GetMetrics: %v", err)
+		}
+		if len(metrics) != 0 {
+			t.Errorf("got %d metrics before the range, want 0", len(metrics))
+		}
+	})
+}
+
+func TestAnalyticsAggregateMetrics(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *sql.DB, dialect database.Dialect) {
+		ctx := context.Background()
+
+		name := fmt.Sprintf("test_metric_%d", rand.Int31())
+		t.Cleanup(func() { db.Exec(`DELETE FROM metrics WHERE name = $1`, name) })
+
+		analytics := NewAnalytics(db, dialect)
+		defer analytics.Close()
+
+		for v := 1; v <= 20; v++ {
+			if err := analytics.TrackMetric(ctx, name, float64(v)); err != nil {
+				t.Fatalf("TrackMetric: %v", err)
+			}
+		}
+
+		points, err := analytics.AggregateMetrics(ctx, models.AggregateQuery{Name: name, Bucket: models.BucketDay})
+		if err != nil {
+			t.Fatalf("AggregateMetrics: %v", err)
+		}
+		if len(points) != 1 {
+			t.Fatalf("got %d buckets, want 1", len(points))
+		}
+		got := points[0]
+		if got.Count != 20 || got.Sum != 210 || got.Avg != 10.5 || got.Min != 1 || got.Max != 20 {
+			t.Errorf("aggregate = %+v, want count 20, sum 210, avg 10.5, min 1, max 20", got)
+		}
+		if math.Abs(got.P95-19.05) > 1e-9 {
+			t.Errorf("p95 = %v, want 19.05", got.P95)
+		}
+	})
+}
+
+func TestAnalyticsLabels(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *sql.DB, dialect database.Dialect) {
+		ctx := context.Background()
+
+		name := fmt.Sprintf("test_metric_%d", rand.Int31())
+		t.Cleanup(func() { db.Exec(`DELETE FROM metrics WHERE name = $1`, name) })
+
+		analytics := NewAnalytics(db, dialect)
+		defer analytics.Close()
+
+		analytics.TrackMetric(ctx, name, 1)
+		analytics.TrackLabeledMetric(ctx, name, 2, map[string]string{"route": "/a"})
+		analytics.TrackLabeledMetric(ctx, name, 3, map[string]string{"route": "/a"})
+		analytics.TrackLabeledMetric(ctx, name, 4, map[string]string{"route": "/b"})
+		analytics.TrackLabeledMetric(ctx, name, 5, map[string]string{`say "hi"`: "yes"})
+
+		metrics, err := analytics.GetMetrics(ctx, models.MetricQuery{Name: name, Labels: map[string]string{"route": "/a"}})
+		if err != nil {
+			t.Fatalf("GetMetrics: %v", err)
+		}
+		if len(metrics) != 2 || metrics[0].Labels["route"] != "/a" {
+			t.Errorf("filtered metrics = %+v, want the two /a metrics", metrics)
+		}
+
+		points, err := analytics.AggregateMetrics(ctx, models.AggregateQuery{Name: name, Bucket: models.BucketDay, GroupBy: []string{"route"}})
+		if err != nil {
+			t.Fatalf("AggregateMetrics: %v", err)
+		}
+		sums := make(map[string]float64)
+		for _, p := range points {
+			sums[p.Labels["route"]] += p.Sum
+		}
+		want := map[string]float64{"": 6, "/a": 5, "/b": 4}
+		for route, sum := range want {
+			if sums[route] != sum {
+				t.Errorf("sum for route %q = %v, want %v", route, sums[route], sum)
+			}
+		}
+
+		quoted, err := analytics.GetMetrics(ctx, models.MetricQuery{Name: name, Labels: map[string]string{`say "hi"`: "yes"}})
+		if err != nil || len(quoted) != 1 || quoted[0].Value != 5 {
+			t.Errorf("metrics with a quoted label key = %+v, %v, want the one valued 5", quoted, err)
+		}
+	})
+}
+
+func TestAnalyticsRejectsInvalidInput(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *sql.DB, dialect database.Dialect) {
+		ctx := context.Background()
+
+		name := fmt.Sprintf("test_metric_%d", rand.Int31())
+		t.Cleanup(func() { db.Exec(`DELETE FROM metrics WHERE name = $1`, name) })
+
+		analytics := NewAnalytics(db, dialect)
+		defer analytics.Close()
+
+		for _, v := range []float64{1e8, -1e9, 99999999.999, math.NaN(), math.Inf(1)} {
+			if err := analytics.TrackMetric(ctx, name, v); err == nil {
+				t.Errorf("TrackMetric(%v) succeeded, want an error", v)
+			}
+		}
+		// A value that fits is still written alongside the rejected ones.
+		if err := analytics.TrackMetric(ctx, name, 99999999.99); err != nil {
+			t.Fatalf("TrackMetric: %v", err)
+		}
+		if metrics, err := analytics.GetMetrics(ctx, models.MetricQuery{Name: name}); err != nil || len(metrics) != 1 {
+			t.Errorf("GetMetrics = %+v, %v, want the one valid metric", metrics, err)
+		}
+
+		for _, q := range []models.AggregateQuery{{Bucket: models.BucketDay}, {Name: name, Bucket: "week"}} {
+			if _, err := analytics.AggregateMetrics(ctx, q); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("AggregateMetrics(%+v) error = %v, want ErrInvalidQuery", q, err)
+			}
+		}
+	})
+}
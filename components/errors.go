@@ -0,0 +1,86 @@
+package components
+
+import "errors"
+
+// Errors returned by the components. They are wrapped with details such as
+// the product or order ID, so compare them with errors.Is.
+var (
+	// ErrProductNotFound is returned when a product does not exist.
+	ErrProductNotFound = errors.New("product not found")
+	// ErrOrderNotFound is returned when an order does not exist.
+	ErrOrderNotFound = errors.New("order not found")
+	// ErrDuplicateProduct is returned when adding a product whose ID is
+	// already taken.
+	ErrDuplicateProduct = errors.New("product already exists")
+	// ErrProductInUse is returned when deleting a product that orders,
+	// transfers or purchase orders still refer to.
+	ErrProductInUse = errors.New("product is referenced by orders, transfers or purchase orders")
+	// ErrInvalidProduct is returned when a product is malformed, such as one
+	// without a name or with negative stock.
+	ErrInvalidProduct = errors.New("invalid product")
+	// ErrInsufficientStock is returned when a product does not have enough
+	// stock for a change.
+	ErrInsufficientStock = errors.New("insufficient stock")
+	// ErrInvalidTransition is returned, as an *InvalidTransitionError, when
+	// an order cannot move to the requested status.
+	ErrInvalidTransition = errors.New("invalid order status transition")
+	// ErrInvalidOrder is returned when an order is malformed, such as one
+	// without items or with a non-positive quantity.
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrInvalidQuery is returned when a listing query has an unknown sort
+	// field, a malformed cursor or a page size out of range.
+	ErrInvalidQuery = errors.New("invalid query")
+	// ErrWarehouseNotFound is returned when a warehouse does not exist.
+	ErrWarehouseNotFound = errors.New("warehouse not found")
+	// ErrDuplicateWarehouse is returned when adding a warehouse whose ID is
+	// already taken.
+	ErrDuplicateWarehouse = errors.New("warehouse already exists")
+	// ErrInvalidWarehouse is returned when a warehouse is malformed, such as
+	// one without an ID or a name.
+	ErrInvalidWarehouse = errors.New("invalid warehouse")
+	// ErrTransferNotFound is returned when a transfer does not exist.
+	ErrTransferNotFound = errors.New("transfer not found")
+	// ErrInvalidTransfer is returned when a transfer is malformed, such as
+	// one between a warehouse and itself, or cannot ship or be received in
+	// its current status.
+	ErrInvalidTransfer = errors.New("invalid transfer")
+	// ErrReservationNotFound is returned when a reservation does not exist.
+	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrInvalidReservation is returned when a reservation is malformed,
+	// such as one with a non-positive quantity or a TTL out of range, or
+	// cannot be confirmed or released in its current status.
+	ErrInvalidReservation = errors.New("invalid reservation")
+	// ErrReservationExpired is returned when confirming a reservation that
+	// has passed its expiry.
+	ErrReservationExpired = errors.New("reservation expired")
+	// ErrSupplierNotFound is returned when a supplier does not exist.
+	ErrSupplierNotFound = errors.New("supplier not found")
+	// ErrDuplicateSupplier is returned when adding a supplier whose ID is
+	// already taken.
+	ErrDuplicateSupplier = errors.New("supplier already exists")
+	// ErrSupplierInUse is returned when deleting a supplier that purchase
+	// orders still refer to.
+	ErrSupplierInUse = errors.New("supplier is referenced by purchase orders")
+	// ErrInvalidSupplier is returned when a supplier is malformed, such as
+	// one without an ID or a name.
+	ErrInvalidSupplier = errors.New("invalid supplier")
+	// ErrPurchaseOrderNotFound is returned when a purchase order does not
+	// exist.
+	ErrPurchaseOrderNotFound = errors.New("purchase order not found")
+	// ErrInvalidPurchaseOrder is returned when a purchase order is
+	// malformed, such as one without lines, or cannot be changed in its
+	// current status.
+	ErrInvalidPurchaseOrder = errors.New("invalid purchase order")
+	// ErrInvalidReceipt is returned when a receipt against a purchase order
+	// is malformed or names a product the order does not.
+	ErrInvalidReceipt = errors.New("invalid receipt")
+	// ErrLotNotFound is returned when a lot does not exist.
+	ErrLotNotFound = errors.New("lot not found")
+	// ErrDuplicateLot is returned when adding a lot whose number the product
+	// already has at the warehouse.
+	ErrDuplicateLot = errors.New("lot already exists")
+	// ErrInvalidLot is returned when a lot is malformed, such as one without
+	// a lot number or expiry date, has already expired, or cannot be
+	// quarantined in its current status.
+	ErrInvalidLot = errors.New("invalid lot")
+)
@@ -0,0 +1,53 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestComponentErrors(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+
+		productID := h.productID()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		product := models.Product{ID: productID, Name: "errors", Stock: 1, Price: 1}
+		if err := inventory.AddProduct(ctx, product); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 1}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+
+		tests := []struct {
+			name string
+			err  error
+			want error
+		}{
+			{"duplicate product", inventory.AddProduct(ctx, product), ErrDuplicateProduct},
+			{"unknown product", inventory.UpdateStock(ctx, h.productID(), 1), ErrProductNotFound},
+			{"negative stock", inventory.UpdateStock(ctx, productID, -5), ErrInsufficientStock},
+			{"oversell", func() error {
+				_, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 1}}})
+				return err
+			}(), ErrInsufficientStock},
+			{"empty order", func() error {
+				_, err := orders.CreateOrder(ctx, models.Order{})
+				return err
+			}(), ErrInvalidOrder},
+			{"unknown order", orders.CancelOrder(ctx, "999999999"), ErrOrderNotFound},
+			{"illegal transition", orders.UpdateOrderStatus(ctx, order.ID, models.OrderStatusDelivered), ErrInvalidTransition},
+		}
+		for _, tt := range tests {
+			if !errors.Is(tt.err, tt.want) {
+				t.Errorf("%s: error = %v, want %v", tt.name, tt.err, tt.want)
+			}
+		}
+	})
+}
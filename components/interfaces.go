@@ -2,6 +2,8 @@ package components
 
 import (
 	"context"
+	"time"
+
 	"service-weaver-app/models"
 )
 
@@ -12,17 +14,76 @@ type InventoryManagement interface {
 	UpdateStock(ctx context.Context, productID string, quantity int) error
 	CheckStock(ctx context.Context, productID string) (int, error)
 	GetProduct(ctx context.Context, productID string) (models.Product, error)
-	GetProducts(ctx context.Context) ([]models.Product, error)
+	GetProducts(ctx context.Context, q models.ProductQuery) (models.ProductPage, error)
+	SearchProducts(ctx context.Context, text string, limit int) ([]models.ProductMatch, error)
+	AutocompleteProducts(ctx context.Context, text string, limit int) ([]models.ProductSuggestion, error)
+	UpdateProduct(ctx context.Context, productID string, update models.ProductUpdate) (models.Product, error)
+	DeleteProduct(ctx context.Context, productID string) error
+	TotalStock(ctx context.Context) (int, error)
+	AddWarehouse(ctx context.Context, warehouse models.Warehouse) error
+	GetWarehouses(ctx context.Context) ([]models.Warehouse, error)
+	CheckStockLevels(ctx context.Context, productID string) (models.StockReport, error)
+	UpdateStockAt(ctx context.Context, warehouseID, productID string, quantity int) error
+	CreateTransfer(ctx context.Context, transfer models.Transfer) (models.Transfer, error)
+	GetTransfer(ctx context.Context, transferID string) (models.Transfer, error)
+	GetTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error)
+	ShipTransfer(ctx context.Context, transferID string) (models.Transfer, error)
+	ReceiveTransfer(ctx context.Context, transferID string) (models.Transfer, error)
+	GetTransferLots(ctx context.Context, transferID string) ([]models.TransferLot, error)
+	GetStockMovements(ctx context.Context, q models.MovementQuery) (models.MovementPage, error)
+	CheckStockLevelsAt(ctx context.Context, productID string, at time.Time) (models.StockReport, error)
+	ReserveStock(ctx context.Context, warehouseID, productID string, quantity int, ttl time.Duration) (models.Reservation, error)
+	GetReservation(ctx context.Context, reservationID string) (models.Reservation, error)
+	ConfirmReservation(ctx context.Context, reservationID string) (models.Reservation, error)
+	ReleaseReservation(ctx context.Context, reservationID string) (models.Reservation, error)
+	ReleaseExpiredReservations(ctx context.Context) (int, error)
+	GetStockAlerts(ctx context.Context, filter models.StockAlertFilter) ([]models.StockAlert, error)
+	NotifyStockAlerts(ctx context.Context, notify func(ctx context.Context, alert models.StockAlert)) (int, error)
+	AddLot(ctx context.Context, lot models.Lot) (models.Lot, error)
+	GetLot(ctx context.Context, lotID string) (models.Lot, error)
+	GetLots(ctx context.Context, filter models.LotFilter) ([]models.Lot, error)
+	GetExpiringLots(ctx context.Context, withinDays int) ([]models.Lot, error)
+	QuarantineLot(ctx context.Context, lotID string) (models.Lot, error)
+	QuarantineExpiredLots(ctx context.Context) ([]models.Lot, error)
 }
 
+// OrderProcessing defines methods for placing orders and moving them through
+// their lifecycle.
 type OrderProcessing interface {
 	CreateOrder(ctx context.Context, order models.Order) (models.Order, error)
 	UpdateOrderStatus(ctx context.Context, orderID string, status string) error
-	GetOrders(ctx context.Context) ([]models.Order, error)
+	CancelOrder(ctx context.Context, orderID string) error
+	GetOrder(ctx context.Context, orderID string) (models.Order, error)
+	GetOrders(ctx context.Context, q models.OrderQuery) (models.OrderPage, error)
+	GetOrderTimeline(ctx context.Context, orderID string) ([]models.OrderStatusChange, error)
+	GetOrderLots(ctx context.Context, orderID string) ([]models.LotAllocation, error)
+	CountOrdersByStatus(ctx context.Context) (map[string]int, error)
+}
+
+// Purchasing defines methods for managing suppliers and buying stock from
+// them with purchase orders.
+type Purchasing interface {
+	AddSupplier(ctx context.Context, supplier models.Supplier) error
+	GetSupplier(ctx context.Context, supplierID string) (models.Supplier, error)
+	GetSuppliers(ctx context.Context) ([]models.Supplier, error)
+	UpdateSupplier(ctx context.Context, supplierID string, supplier models.Supplier) (models.Supplier, error)
+	DeleteSupplier(ctx context.Context, supplierID string) error
+	CreatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) (models.PurchaseOrder, error)
+	GetPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error)
+	GetPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error)
+	UpdatePurchaseOrder(ctx context.Context, poID string, po models.PurchaseOrder) (models.PurchaseOrder, error)
+	DeletePurchaseOrder(ctx context.Context, poID string) error
+	SendPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error)
+	ReceivePurchaseOrder(ctx context.Context, poID string, lines []models.ReceiptLine) (models.PurchaseOrder, error)
+	ClosePurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error)
+	SuggestReplenishment(ctx context.Context, q models.ReplenishmentQuery) ([]models.ReplenishmentSuggestion, error)
+	CreateSuggestedPurchaseOrders(ctx context.Context, q models.ReplenishmentQuery) ([]models.PurchaseOrder, error)
 }
 
 // Analytics defines methods for tracking metrics.
 type Analytics interface {
 	TrackMetric(ctx context.Context, name string, value float64) error
-	GetMetrics(ctx context.Context) ([]models.Metric, error)
+	TrackLabeledMetric(ctx context.Context, name string, value float64, labels map[string]string) error
+	GetMetrics(ctx context.Context, q models.MetricQuery) ([]models.Metric, error)
+	AggregateMetrics(ctx context.Context, q models.AggregateQuery) ([]models.AggregatePoint, error)
 }
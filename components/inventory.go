@@ -2,85 +2,222 @@ package components
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+
 	"service-weaver-app/models"
 )
 
 // InventoryManagementImpl is the implementation of InventoryManagement.
 type InventoryManagementImpl struct {
-	db *sql.DB
+	store Store
 }
 
 // NewInventoryManagement initializes a new InventoryManagementImpl instance.
-func NewInventoryManagement(db *sql.DB) *InventoryManagementImpl {
-	return &InventoryManagementImpl{db: db}
+func NewInventoryManagement(store Store) *InventoryManagementImpl {
+	return &InventoryManagementImpl{store: store}
 }
 
 // AddProduct adds a new product to the inventory.
 func (im *InventoryManagementImpl) AddProduct(ctx context.Context, product models.Product) error {
-	query := `INSERT INTO products (id, name, stock, price) VALUES ($1, $2, $3, $4)`
-	_, err := im.db.ExecContext(ctx, query, product.ID, product.Name, product.Stock, product.Price)
+	if product.ID == "" {
+		return fmt.Errorf("%w: id is required", ErrInvalidProduct)
+	}
+	update := models.ProductUpdate{
+		Name:            &product.Name,
+		Tags:            &product.Tags,
+		Stock:           &product.Stock,
+		Price:           &product.Price,
+		ReorderPoint:    &product.ReorderPoint,
+		ReorderQuantity: &product.ReorderQuantity,
+		SafetyStock:     &product.SafetyStock,
+	}
+	if err := validateUpdate(update); err != nil {
+		return err
+	}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if err := checkProductSupplier(ctx, tx, product.SupplierID); err != nil {
+			return err
+		}
+		if err := tx.InsertProduct(ctx, product); err != nil {
+			return err
+		}
+		movement := models.StockMovement{WarehouseID: DefaultWarehouseID, ProductID: product.ID, Delta: product.Stock, Reason: models.MovementReasonInitial}
+		if err := recordMovement(ctx, tx, movement); err != nil {
+			return err
+		}
+		return watchReorderPoint(ctx, tx, product)
+	})
+	if errors.Is(err, errDuplicate) {
+		return fmt.Errorf("%w: %s", ErrDuplicateProduct, product.ID)
+	}
 	if err != nil {
 		return fmt.Errorf("could not add product: %w", err)
 	}
 	return nil
 }
 
-// UpdateStock updates the stock level of an existing product.
+// UpdateStock adds quantity, which may be negative, to the stock of an
+// existing product at the default warehouse. Stock never becomes negative.
 func (im *InventoryManagementImpl) UpdateStock(ctx context.Context, productID string, quantity int) error {
-	query := `UPDATE products SET stock = stock + $1 WHERE id = $2`
-	_, err := im.db.ExecContext(ctx, query, quantity, productID)
-	if err != nil {
-		return fmt.Errorf("could not update stock: %w", err)
-	}
-	return nil
+	return im.UpdateStockAt(ctx, DefaultWarehouseID, productID, quantity)
 }
 
-// CheckStock returns the current stock level of a product.
+// CheckStock returns the total stock of a product across all warehouses.
 func (im *InventoryManagementImpl) CheckStock(ctx context.Context, productID string) (int, error) {
-	var stock int
-	query := `SELECT stock FROM products WHERE id = $1`
-	err := im.db.QueryRowContext(ctx, query, productID).Scan(&stock)
+	product, err := im.GetProduct(ctx, productID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return 0, fmt.Errorf("product not found")
-		}
-		return 0, fmt.Errorf("could not check stock: %w", err)
+		return 0, err
 	}
-	return stock, nil
+	return product.Stock, nil
 }
 
 // GetProduct retrieves the details of a specific product by its ID.
 func (im *InventoryManagementImpl) GetProduct(ctx context.Context, productID string) (models.Product, error) {
 	var product models.Product
-	query := `SELECT id, name, stock, price FROM products WHERE id = $1`
-	err := im.db.QueryRowContext(ctx, query, productID).Scan(&product.ID, &product.Name, &product.Stock, &product.Price)
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		product, err = tx.GetProduct(ctx, productID)
+		return err
+	})
+	if errors.Is(err, errNotFound) {
+		return models.Product{}, fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+	}
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return models.Product{}, fmt.Errorf("product not found")
-		}
 		return models.Product{}, fmt.Errorf("could not fetch product: %w", err)
 	}
 	return product, nil
 }
 
-// GetProducts retrieves all products from the inventory.
-func (im *InventoryManagementImpl) GetProducts(ctx context.Context) ([]models.Product, error) {
-	query := `SELECT id, name, stock, price FROM products`
-	rows, err := im.db.QueryContext(ctx, query)
+// GetProducts retrieves one page of the products matching the query.
+func (im *InventoryManagementImpl) GetProducts(ctx context.Context, q models.ProductQuery) (models.ProductPage, error) {
+	spec, err := productPager.plan(q.Sort, q.Cursor, q.Limit)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch products: %w", err)
+		return models.ProductPage{}, err
 	}
-	defer rows.Close()
 
 	var products []models.Product
-	for rows.Next() {
-		var product models.Product
-		if err := rows.Scan(&product.ID, &product.Name, &product.Stock, &product.Price); err != nil {
-			return nil, fmt.Errorf("could not scan product: %w", err)
+	err = im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		products, err = tx.ListProducts(ctx, q.ProductFilter, spec)
+		return err
+	})
+	if err != nil {
+		return models.ProductPage{}, fmt.Errorf("could not fetch products: %w", err)
+	}
+
+	var page models.ProductPage
+	page.Products, page.NextCursor, page.PrevCursor = productPager.page(products, spec)
+	return page, nil
+}
+
+// UpdateProduct changes the fields of a product that are set in update and
+// returns the updated product. A new total Stock is reached by changing the
+// stock at the default warehouse.
+func (im *InventoryManagementImpl) UpdateProduct(ctx context.Context, productID string, update models.ProductUpdate) (models.Product, error) {
+	if err := validateUpdate(update); err != nil {
+		return models.Product{}, err
+	}
+
+	var product models.Product
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if update.SupplierID != nil {
+			if err := checkProductSupplier(ctx, tx, *update.SupplierID); err != nil {
+				return err
+			}
+		}
+		var err error
+		product, err = tx.UpdateProduct(ctx, productID, update)
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrProductNotFound, productID)
 		}
-		products = append(products, product)
+		if err != nil {
+			return fmt.Errorf("could not update product: %w", err)
+		}
+		if update.Stock != nil && *update.Stock != product.Stock {
+			movement := models.StockMovement{WarehouseID: DefaultWarehouseID, ProductID: productID, Delta: *update.Stock - product.Stock, Reason: models.MovementReasonAdjustment}
+			product, err = adjustStock(ctx, tx, movement)
+		} else if update.ReorderPoint != nil || update.ReorderQuantity != nil {
+			err = watchReorderPoint(ctx, tx, product)
+		}
+		return err
+	})
+	if err != nil {
+		return models.Product{}, err
+	}
+	return product, nil
+}
+
+// DeleteProduct removes a product that no order refers to.
+func (im *InventoryManagementImpl) DeleteProduct(ctx context.Context, productID string) error {
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		return tx.DeleteProduct(ctx, productID)
+	})
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+	}
+	if errors.Is(err, errInUse) {
+		return fmt.Errorf("%w: %s", ErrProductInUse, productID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete product: %w", err)
+	}
+	return nil
+}
+
+// validateUpdate checks the product fields set in update.
+func validateUpdate(update models.ProductUpdate) error {
+	switch {
+	case update.Name != nil && *update.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidProduct)
+	case update.Stock != nil && *update.Stock < 0:
+		return fmt.Errorf("%w: stock must not be negative", ErrInvalidProduct)
+	case update.Price != nil && *update.Price < 0:
+		return fmt.Errorf("%w: price must not be negative", ErrInvalidProduct)
+	case update.ReorderPoint != nil && *update.ReorderPoint < 0:
+		return fmt.Errorf("%w: reorder_point must not be negative", ErrInvalidProduct)
+	case update.ReorderQuantity != nil && *update.ReorderQuantity < 0:
+		return fmt.Errorf("%w: reorder_quantity must not be negative", ErrInvalidProduct)
+	case update.SafetyStock != nil && *update.SafetyStock < 0:
+		return fmt.Errorf("%w: safety_stock must not be negative", ErrInvalidProduct)
+	}
+	if update.Tags != nil {
+		for _, tag := range *update.Tags {
+			if strings.TrimSpace(tag) == "" {
+				return fmt.Errorf("%w: tags must not be blank", ErrInvalidProduct)
+			}
+		}
+	}
+	return nil
+}
+
+// checkProductSupplier checks that the supplier a product is bought from,
+// if any, exists.
+func checkProductSupplier(ctx context.Context, tx StoreTx, supplierID string) error {
+	if supplierID == "" {
+		return nil
+	}
+	_, err := tx.GetSupplier(ctx, supplierID)
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s", ErrSupplierNotFound, supplierID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not fetch supplier: %w", err)
+	}
+	return nil
+}
+
+// TotalStock returns the total number of units in stock across all products.
+func (im *InventoryManagementImpl) TotalStock(ctx context.Context) (int, error) {
+	var total int
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		total, err = tx.TotalStock(ctx)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not sum stock: %w", err)
 	}
-	return products, nil
+	return total, nil
 }
@@ -0,0 +1,324 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+const (
+	// DefaultExpiringDays is how far ahead the expiring lots report looks
+	// when no number of days is given.
+	DefaultExpiringDays = 30
+	// LotSweepInterval is how often SweepExpiredLots quarantines expired
+	// lots.
+	LotSweepInterval = time.Hour
+)
+
+// AddLot receives a new lot of a product at a warehouse, or the default
+// warehouse if the lot names none, adding its quantity to the stock there
+// and recording the movement in the ledger. It returns the lot with its ID.
+// A lot that has already expired is rejected with ErrInvalidLot.
+func (im *InventoryManagementImpl) AddLot(ctx context.Context, lot models.Lot) (models.Lot, error) {
+	if lot.WarehouseID == "" {
+		lot.WarehouseID = DefaultWarehouseID
+	}
+	switch {
+	case lot.ProductID == "":
+		return models.Lot{}, fmt.Errorf("%w: product_id is required", ErrInvalidLot)
+	case lot.LotNumber == "":
+		return models.Lot{}, fmt.Errorf("%w: lot_number is required", ErrInvalidLot)
+	case lot.Quantity <= 0:
+		return models.Lot{}, fmt.Errorf("%w: quantity must be positive", ErrInvalidLot)
+	case lot.ExpiresAt.IsZero():
+		return models.Lot{}, fmt.Errorf("%w: expires_at is required", ErrInvalidLot)
+	case !lot.ExpiresAt.After(time.Now()):
+		return models.Lot{}, fmt.Errorf("%w: lot %s expired at %s", ErrInvalidLot, lot.LotNumber, lot.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if err := createLot(ctx, tx, &lot); err != nil {
+			return err
+		}
+		movement := models.StockMovement{
+			WarehouseID: lot.WarehouseID,
+			ProductID:   lot.ProductID,
+			Delta:       lot.Quantity,
+			Reason:      models.MovementReasonLot,
+			Reference:   lot.ID,
+		}
+		_, err := adjustStock(ctx, tx, movement)
+		return err
+	})
+	if err != nil {
+		return models.Lot{}, err
+	}
+	return lot, nil
+}
+
+// createLot records an Active lot of stock that its caller adds to the
+// warehouse.
+func createLot(ctx context.Context, tx StoreTx, lot *models.Lot) error {
+	lot.Status = models.LotStatusActive
+	lot.ExpiresAt = lot.ExpiresAt.UTC().Truncate(time.Microsecond)
+	if _, err := tx.GetProduct(ctx, lot.ProductID); errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s", ErrProductNotFound, lot.ProductID)
+	} else if err != nil {
+		return fmt.Errorf("could not fetch product: %w", err)
+	}
+	if _, err := tx.GetWarehouse(ctx, lot.WarehouseID); errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s", ErrWarehouseNotFound, lot.WarehouseID)
+	} else if err != nil {
+		return fmt.Errorf("could not fetch warehouse: %w", err)
+	}
+	err := tx.InsertLot(ctx, lot)
+	if errors.Is(err, errDuplicate) {
+		return fmt.Errorf("%w: lot %s of product %s at warehouse %s", ErrDuplicateLot, lot.LotNumber, lot.ProductID, lot.WarehouseID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not add lot: %w", err)
+	}
+	return nil
+}
+
+// lockLotByNumber returns the lot of a product at a warehouse with the lot
+// number, locked like StoreTx.LockLot, and whether there is one.
+func lockLotByNumber(ctx context.Context, tx StoreTx, productID, warehouseID, lotNumber string) (models.Lot, bool, error) {
+	filter := models.LotFilter{ProductID: productID, WarehouseID: warehouseID, LotNumber: lotNumber}
+	found, err := tx.ListLots(ctx, filter)
+	if err != nil {
+		return models.Lot{}, false, fmt.Errorf("could not fetch lots: %w", err)
+	}
+	if len(found) == 0 {
+		return models.Lot{}, false, nil
+	}
+	lot, err := tx.LockLot(ctx, found[0].ID)
+	if err != nil {
+		return models.Lot{}, false, fmt.Errorf("could not fetch lot: %w", err)
+	}
+	return lot, true, nil
+}
+
+// GetLot returns a lot.
+func (im *InventoryManagementImpl) GetLot(ctx context.Context, lotID string) (models.Lot, error) {
+	var lot models.Lot
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		lot, err = tx.GetLot(ctx, lotID)
+		return err
+	})
+	if errors.Is(err, errNotFound) {
+		return models.Lot{}, fmt.Errorf("%w: %s", ErrLotNotFound, lotID)
+	}
+	if err != nil {
+		return models.Lot{}, fmt.Errorf("could not fetch lot: %w", err)
+	}
+	return lot, nil
+}
+
+// GetLots returns the lots matching filter, earliest expiry first.
+func (im *InventoryManagementImpl) GetLots(ctx context.Context, filter models.LotFilter) ([]models.Lot, error) {
+	lots := []models.Lot{}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		found, err := tx.ListLots(ctx, filter)
+		lots = append(lots, found...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch lots: %w", err)
+	}
+	return lots, nil
+}
+
+// GetExpiringLots returns the Active lots that expire within the next
+// withinDays days, earliest expiry first. Lots that have already expired
+// but are not yet quarantined are included.
+func (im *InventoryManagementImpl) GetExpiringLots(ctx context.Context, withinDays int) ([]models.Lot, error) {
+	if withinDays < 0 {
+		return nil, fmt.Errorf("%w: days must not be negative", ErrInvalidQuery)
+	}
+	filter := models.LotFilter{
+		Status:    models.LotStatusActive,
+		ExpiresBy: time.Now().UTC().AddDate(0, 0, withinDays),
+	}
+	return im.GetLots(ctx, filter)
+}
+
+// QuarantineLot marks an Active lot Quarantined and takes what is left of
+// it out of stock, recording the movement in the ledger, so that it can no
+// longer be sold. If reservations hold the stock it would take,
+// ErrInsufficientStock is returned and nothing changes.
+func (im *InventoryManagementImpl) QuarantineLot(ctx context.Context, lotID string) (models.Lot, error) {
+	var lot models.Lot
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		lot, err = tx.LockLot(ctx, lotID)
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrLotNotFound, lotID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not fetch lot: %w", err)
+		}
+		if lot.Status != models.LotStatusActive {
+			return fmt.Errorf("%w: lot %s is %s and cannot be quarantined", ErrInvalidLot, lotID, lot.Status)
+		}
+		return quarantineLot(ctx, tx, &lot)
+	})
+	if err != nil {
+		return models.Lot{}, err
+	}
+	return lot, nil
+}
+
+// QuarantineExpiredLots quarantines every Active lot that has expired, in a
+// single transaction, and returns them. Lots whose stock reservations hold
+// are left Active, to be quarantined once the reservations end; the
+// reservations cannot be confirmed meanwhile.
+func (im *InventoryManagementImpl) QuarantineExpiredLots(ctx context.Context) ([]models.Lot, error) {
+	quarantined := []models.Lot{}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		expired, err := tx.ListLots(ctx, models.LotFilter{Status: models.LotStatusActive, ExpiresBy: time.Now().UTC()})
+		if err != nil {
+			return fmt.Errorf("could not fetch lots: %w", err)
+		}
+		for _, lot := range expired {
+			lot, err := tx.LockLot(ctx, lot.ID)
+			if err != nil {
+				return fmt.Errorf("could not fetch lot: %w", err)
+			}
+			if lot.Status != models.LotStatusActive {
+				continue
+			}
+			err = quarantineLot(ctx, tx, &lot)
+			if errors.Is(err, ErrInsufficientStock) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			quarantined = append(quarantined, lot)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quarantined, nil
+}
+
+// quarantineLot quarantines a locked Active lot. The stock comes out of the
+// level directly rather than through changeStock, which would take it from
+// the product's other lots. If the stock cannot be taken nothing changes.
+func quarantineLot(ctx context.Context, tx StoreTx, lot *models.Lot) error {
+	if lot.Quantity > 0 {
+		if err := quarantineStock(ctx, tx, lot.WarehouseID, lot.ProductID, lot.ID, lot.Quantity); err != nil {
+			return err
+		}
+	}
+	if err := tx.SetLotStatus(ctx, lot.ID, models.LotStatusQuarantined); err != nil {
+		return fmt.Errorf("could not update lot status: %w", err)
+	}
+	updated, err := tx.GetLot(ctx, lot.ID)
+	if err != nil {
+		return fmt.Errorf("could not fetch lot: %w", err)
+	}
+	*lot = updated
+	return nil
+}
+
+// splitExpired splits locked Active lots into those that have not expired,
+// in order, and the total quantity of those that have.
+func splitExpired(lots []models.Lot) ([]models.Lot, int) {
+	now := time.Now().UTC()
+	var fresh []models.Lot
+	expired := 0
+	for _, lot := range lots {
+		if lot.ExpiresAt.After(now) {
+			fresh = append(fresh, lot)
+		} else {
+			expired += lot.Quantity
+		}
+	}
+	return fresh, expired
+}
+
+// expiredStock returns the quantity of a product in Active lots that have
+// expired, by warehouse.
+func expiredStock(ctx context.Context, tx StoreTx, productID string) (map[string]int, error) {
+	lots, err := tx.ListLots(ctx, models.LotFilter{ProductID: productID, Status: models.LotStatusActive, ExpiresBy: time.Now().UTC()})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch lots: %w", err)
+	}
+	expired := make(map[string]int)
+	for _, lot := range lots {
+		expired[lot.WarehouseID] += lot.Quantity
+	}
+	return expired, nil
+}
+
+// availableStock is the part of a stock level that orders and new
+// reservations can take: what neither reservations nor expired lots hold.
+// Reservations made before a lot expired may hold its stock too, so the
+// two can overlap.
+func availableStock(level models.StockLevel) int {
+	return max(level.Quantity-level.Reserved-level.Expired, 0)
+}
+
+// keepExpired returns ErrInsufficientStock unless the stock of a product
+// at a warehouse that no reservation holds still covers expired, the
+// quantity of its expired lots. Expired lots stay in stock until they are
+// quarantined, but nothing may take or reserve their units; a reservation
+// that came to hold them can no longer be confirmed.
+func keepExpired(ctx context.Context, tx StoreTx, warehouseID, productID string, expired int) error {
+	if expired == 0 {
+		return nil
+	}
+	levels, err := tx.ListStockLevels(ctx, productID)
+	if err != nil {
+		return fmt.Errorf("could not fetch stock levels: %w", err)
+	}
+	for _, level := range levels {
+		if level.WarehouseID == warehouseID && level.Quantity-level.Reserved < expired {
+			return fmt.Errorf("%w for product %s at warehouse %s", ErrInsufficientStock, productID, warehouseID)
+		}
+	}
+	return nil
+}
+
+// quarantineStock takes quantity units of a quarantined lot out of the stock
+// level directly and records the movement in the ledger.
+func quarantineStock(ctx context.Context, tx StoreTx, warehouseID, productID, lotID string, quantity int) error {
+	if _, err := changeLevel(ctx, tx, warehouseID, productID, -quantity); err != nil {
+		return err
+	}
+	movement := models.StockMovement{
+		WarehouseID: warehouseID,
+		ProductID:   productID,
+		Delta:       -quantity,
+		Reason:      models.MovementReasonQuarantine,
+		Reference:   lotID,
+	}
+	return recordMovement(ctx, tx, movement)
+}
+
+// SweepExpiredLots calls QuarantineExpiredLots once straight away, so that
+// lots that expired while the service was down are taken out of stock, and
+// then every interval until ctx is done, logging failures.
+func SweepExpiredLots(ctx context.Context, inventory InventoryManagement, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := inventory.QuarantineExpiredLots(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to quarantine expired lots: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
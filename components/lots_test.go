@@ -0,0 +1,468 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+func TestLots(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "milk", Stock: 2, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		now := time.Now().UTC()
+		stock := func() int {
+			t.Helper()
+			stock, err := inventory.CheckStock(ctx, productID)
+			if err != nil {
+				t.Fatalf("CheckStock: %v", err)
+			}
+			return stock
+		}
+		quantities := func() map[string]int {
+			t.Helper()
+			lots, err := inventory.GetLots(ctx, models.LotFilter{ProductID: productID})
+			if err != nil {
+				t.Fatalf("GetLots: %v", err)
+			}
+			got := make(map[string]int)
+			for _, lot := range lots {
+				got[lot.LotNumber] = lot.Quantity
+			}
+			return got
+		}
+
+		invalid := []models.Lot{
+			{ProductID: productID, Quantity: 1, ExpiresAt: now},
+			{ProductID: productID, LotNumber: "X", ExpiresAt: now},
+			{ProductID: productID, LotNumber: "X", Quantity: 1},
+		}
+		for _, lot := range invalid {
+			if _, err := inventory.AddLot(ctx, lot); !errors.Is(err, ErrInvalidLot) {
+				t.Errorf("AddLot(%+v) error = %v, want ErrInvalidLot", lot, err)
+			}
+		}
+		if _, err := inventory.AddLot(ctx, models.Lot{ProductID: productID + "-missing", LotNumber: "X", Quantity: 1, ExpiresAt: now.AddDate(0, 0, 1)}); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("AddLot(missing product) error = %v, want ErrProductNotFound", err)
+		}
+
+		late, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "LATE", Quantity: 3, ExpiresAt: now.AddDate(0, 0, 10)})
+		if err != nil || late.WarehouseID != DefaultWarehouseID || late.Status != models.LotStatusActive {
+			t.Fatalf("AddLot = %+v, %v", late, err)
+		}
+		early, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "EARLY", Quantity: 2, ExpiresAt: now.AddDate(0, 0, 5)})
+		if err != nil {
+			t.Fatalf("AddLot: %v", err)
+		}
+		if _, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "LATE", Quantity: 1, ExpiresAt: now.AddDate(0, 0, 1)}); !errors.Is(err, ErrDuplicateLot) {
+			t.Errorf("AddLot(duplicate) error = %v, want ErrDuplicateLot", err)
+		}
+		if got := stock(); got != 7 {
+			t.Errorf("stock after adding lots = %d, want 7", got)
+		}
+
+		// The earliest expiry goes first.
+		order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 4}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		allocations, err := orders.GetOrderLots(ctx, order.ID)
+		if err != nil || len(allocations) != 2 {
+			t.Fatalf("GetOrderLots = %+v, %v", allocations, err)
+		}
+		if a := allocations[0]; a.OrderItemID != order.Items[0].ID || a.LotID != early.ID || a.LotNumber != "EARLY" || a.Quantity != 2 {
+			t.Errorf("first allocation = %+v, want 2 from EARLY", a)
+		}
+		if a := allocations[1]; a.LotID != late.ID || a.Quantity != 2 {
+			t.Errorf("second allocation = %+v, want 2 from LATE", a)
+		}
+		if got := quantities(); got["EARLY"] != 0 || got["LATE"] != 1 {
+			t.Errorf("lots after ordering = %v, want EARLY 0 and LATE 1", got)
+		}
+		if _, err := orders.GetOrderLots(ctx, order.ID+"-missing"); !errors.Is(err, ErrOrderNotFound) {
+			t.Errorf("GetOrderLots(missing) error = %v, want ErrOrderNotFound", err)
+		}
+
+		if err := orders.CancelOrder(ctx, order.ID); err != nil {
+			t.Fatalf("CancelOrder: %v", err)
+		}
+		if got := quantities(); got["EARLY"] != 2 || got["LATE"] != 3 {
+			t.Errorf("lots after cancelling = %v, want EARLY 2 and LATE 3", got)
+		}
+
+		if _, err := inventory.GetExpiringLots(ctx, -1); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("GetExpiringLots(-1) error = %v, want ErrInvalidQuery", err)
+		}
+		if lots, err := inventory.GetExpiringLots(ctx, 7); err != nil || len(lots) != 1 || lots[0].ID != early.ID {
+			t.Errorf("GetExpiringLots(7) = %+v, %v, want EARLY", lots, err)
+		}
+
+		// A lot that has already expired cannot be added; one that expired
+		// in stock is quarantined by the sweep.
+		expired := models.Lot{ProductID: productID, WarehouseID: DefaultWarehouseID, LotNumber: "OLD", Quantity: 1, ExpiresAt: now.AddDate(0, 0, -1)}
+		if _, err := inventory.AddLot(ctx, expired); !errors.Is(err, ErrInvalidLot) {
+			t.Errorf("AddLot(expired) error = %v, want ErrInvalidLot", err)
+		}
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			if err := createLot(ctx, tx, &expired); err != nil {
+				return err
+			}
+			_, err := changeLevel(ctx, tx, DefaultWarehouseID, productID, expired.Quantity)
+			return err
+		})
+		quarantined, err := inventory.QuarantineExpiredLots(ctx)
+		if err != nil {
+			t.Fatalf("QuarantineExpiredLots: %v", err)
+		}
+		var found bool
+		for _, lot := range quarantined {
+			if lot.ID == expired.ID {
+				found = lot.Status == models.LotStatusQuarantined && lot.QuarantinedAt != nil && lot.Quantity == 1
+			}
+		}
+		if !found {
+			t.Errorf("QuarantineExpiredLots = %+v, want OLD quarantined", quarantined)
+		}
+		if got := stock(); got != 7 {
+			t.Errorf("stock after quarantining OLD = %d, want 7", got)
+		}
+		if _, err := inventory.QuarantineLot(ctx, expired.ID); !errors.Is(err, ErrInvalidLot) {
+			t.Errorf("QuarantineLot(quarantined) error = %v, want ErrInvalidLot", err)
+		}
+		if _, err := inventory.QuarantineLot(ctx, expired.ID+"0"); !errors.Is(err, ErrLotNotFound) {
+			t.Errorf("QuarantineLot(missing) error = %v, want ErrLotNotFound", err)
+		}
+
+		// Stock held by a reservation cannot be quarantined.
+		held, err := inventory.ReserveStock(ctx, "", productID, 7, 0)
+		if err != nil {
+			t.Fatalf("ReserveStock: %v", err)
+		}
+		if _, err := inventory.QuarantineLot(ctx, late.ID); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("QuarantineLot(reserved) error = %v, want ErrInsufficientStock", err)
+		}
+		if _, err := inventory.ReleaseReservation(ctx, held.ID); err != nil {
+			t.Fatalf("ReleaseReservation: %v", err)
+		}
+
+		if lot, err := inventory.QuarantineLot(ctx, late.ID); err != nil || lot.Status != models.LotStatusQuarantined {
+			t.Fatalf("QuarantineLot = %+v, %v", lot, err)
+		}
+		if got := stock(); got != 4 {
+			t.Errorf("stock after quarantining LATE = %d, want 4", got)
+		}
+		// Quarantined lots are never sold; what the lots cannot cover comes
+		// from the stock in no lot.
+		order, err = orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 4}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if allocations, err := orders.GetOrderLots(ctx, order.ID); err != nil || len(allocations) != 1 || allocations[0].LotID != early.ID {
+			t.Errorf("GetOrderLots = %+v, %v, want only EARLY", allocations, err)
+		}
+		if _, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 1}}}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("CreateOrder(quarantined stock) error = %v, want ErrInsufficientStock", err)
+		}
+	})
+}
+
+func TestReceiveLots(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		purchasing := NewPurchasing(h)
+
+		supplier := models.Supplier{ID: h.productID(), Name: "Dairy"}
+		if err := purchasing.AddSupplier(ctx, supplier); err != nil {
+			t.Fatalf("AddSupplier: %v", err)
+		}
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "cheese", Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		po, err := purchasing.CreatePurchaseOrder(ctx, models.PurchaseOrder{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLine{{ProductID: productID, Quantity: 5}}})
+		if err != nil {
+			t.Fatalf("CreatePurchaseOrder: %v", err)
+		}
+		if _, err := purchasing.SendPurchaseOrder(ctx, po.ID); err != nil {
+			t.Fatalf("SendPurchaseOrder: %v", err)
+		}
+
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: productID, Quantity: 2, LotNumber: "C1"}}); !errors.Is(err, ErrInvalidReceipt) {
+			t.Errorf("ReceivePurchaseOrder(lot without expiry) error = %v, want ErrInvalidReceipt", err)
+		}
+		past := time.Now().UTC().AddDate(0, 0, -1)
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: productID, Quantity: 2, LotNumber: "C0", ExpiresAt: &past}}); !errors.Is(err, ErrInvalidReceipt) {
+			t.Errorf("ReceivePurchaseOrder(expired lot) error = %v, want ErrInvalidReceipt", err)
+		}
+		expires := time.Now().UTC().AddDate(0, 1, 0)
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: productID, Quantity: 2, LotNumber: "C1", ExpiresAt: &expires}}); err != nil {
+			t.Fatalf("ReceivePurchaseOrder: %v", err)
+		}
+		lots, err := inventory.GetLots(ctx, models.LotFilter{ProductID: productID})
+		if err != nil || len(lots) != 1 || lots[0].LotNumber != "C1" || lots[0].Quantity != 2 || lots[0].WarehouseID != DefaultWarehouseID {
+			t.Fatalf("GetLots = %+v, %v, want lot C1 of 2", lots, err)
+		}
+		// A second delivery of the same lot adds to it, provided it expires
+		// at the same time.
+		later := expires.AddDate(0, 0, 1)
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: productID, Quantity: 1, LotNumber: "C1", ExpiresAt: &later}}); !errors.Is(err, ErrInvalidReceipt) {
+			t.Errorf("ReceivePurchaseOrder(lot with another expiry) error = %v, want ErrInvalidReceipt", err)
+		}
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: productID, Quantity: 3, LotNumber: "C1", ExpiresAt: &expires}}); err != nil {
+			t.Fatalf("ReceivePurchaseOrder(same lot again): %v", err)
+		}
+		lots, err = inventory.GetLots(ctx, models.LotFilter{ProductID: productID})
+		if err != nil || len(lots) != 1 || lots[0].Quantity != 5 {
+			t.Errorf("GetLots = %+v, %v, want lot C1 of 5", lots, err)
+		}
+		if stock, err := inventory.CheckStock(ctx, productID); err != nil || stock != 5 {
+			t.Errorf("CheckStock = %d, %v, want 5", stock, err)
+		}
+	})
+}
+
+func TestExpiredLots(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "yoghurt", Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		now := time.Now().UTC()
+		fresh, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "FRESH", Quantity: 2, ExpiresAt: now.AddDate(0, 0, 10)})
+		if err != nil {
+			t.Fatalf("AddLot: %v", err)
+		}
+		// A lot that expired before the sweep got to it.
+		old := models.Lot{ProductID: productID, WarehouseID: DefaultWarehouseID, LotNumber: "OLD", Quantity: 3, ExpiresAt: now.AddDate(0, 0, -1)}
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			if err := createLot(ctx, tx, &old); err != nil {
+				return err
+			}
+			_, err := changeLevel(ctx, tx, DefaultWarehouseID, productID, old.Quantity)
+			return err
+		})
+
+		report, err := inventory.CheckStockLevels(ctx, productID)
+		if err != nil || report.Total != 5 || report.Expired != 3 || report.Available != 2 ||
+			fmt.Sprint(report.Levels) != fmt.Sprintf("[{%s 5 0 3 2}]", DefaultWarehouseID) {
+			t.Errorf("CheckStockLevels = %+v, %v, want 2 of 5 available", report, err)
+		}
+
+		// Nothing can take or reserve the expired lot.
+		if _, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 3}}}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("CreateOrder(expired stock) error = %v, want ErrInsufficientStock", err)
+		}
+		if _, err := orders.CreateOrder(ctx, models.Order{WarehouseID: DefaultWarehouseID, Items: []models.OrderItem{{ProductID: productID, Quantity: 3}}}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("CreateOrder(expired stock at a warehouse) error = %v, want ErrInsufficientStock", err)
+		}
+		if _, err := inventory.ReserveStock(ctx, DefaultWarehouseID, productID, 3, 0); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("ReserveStock(expired stock) error = %v, want ErrInsufficientStock", err)
+		}
+		if err := inventory.UpdateStock(ctx, productID, -3); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("UpdateStock(expired stock) error = %v, want ErrInsufficientStock", err)
+		}
+
+		order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 2}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if allocations, err := orders.GetOrderLots(ctx, order.ID); err != nil || len(allocations) != 1 || allocations[0].LotID != fresh.ID || allocations[0].Quantity != 2 {
+			t.Errorf("GetOrderLots = %+v, %v, want 2 from FRESH", allocations, err)
+		}
+		if lot, err := inventory.GetLot(ctx, old.ID); err != nil || lot.Quantity != 3 || lot.Status != models.LotStatusActive {
+			t.Errorf("GetLot(OLD) = %+v, %v, want 3 left Active", lot, err)
+		}
+
+		quarantined, err := inventory.QuarantineExpiredLots(ctx)
+		if err != nil || len(quarantined) != 1 || quarantined[0].ID != old.ID {
+			t.Fatalf("QuarantineExpiredLots = %+v, %v, want OLD", quarantined, err)
+		}
+		if report, err := inventory.CheckStockLevels(ctx, productID); err != nil || report.Total != 0 || report.Expired != 0 {
+			t.Errorf("CheckStockLevels after quarantine = %+v, %v, want no stock", report, err)
+		}
+	})
+}
+
+func TestLotExpiringWhileReserved(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "cream", Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		lot, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "SOON", Quantity: 2, ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+		if err != nil {
+			t.Fatalf("AddLot: %v", err)
+		}
+		reservation, err := inventory.ReserveStock(ctx, DefaultWarehouseID, productID, 2, time.Minute)
+		if err != nil {
+			t.Fatalf("ReserveStock: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		// The reservation holds the expired lot, which can no longer be sold,
+		// and the lot is quarantined once the reservation ends.
+		if _, err := inventory.ConfirmReservation(ctx, reservation.ID); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("ConfirmReservation(expired lot) error = %v, want ErrInsufficientStock", err)
+		}
+		if report, err := inventory.CheckStockLevels(ctx, productID); err != nil || report.Available != 0 {
+			t.Errorf("CheckStockLevels = %+v, %v, want nothing available", report, err)
+		}
+		if quarantined, err := inventory.QuarantineExpiredLots(ctx); err != nil || len(quarantined) != 0 {
+			t.Errorf("QuarantineExpiredLots(reserved) = %+v, %v, want none", quarantined, err)
+		}
+		if _, err := inventory.ReleaseReservation(ctx, reservation.ID); err != nil {
+			t.Fatalf("ReleaseReservation: %v", err)
+		}
+		if quarantined, err := inventory.QuarantineExpiredLots(ctx); err != nil || len(quarantined) != 1 || quarantined[0].ID != lot.ID {
+			t.Errorf("QuarantineExpiredLots = %+v, %v, want SOON", quarantined, err)
+		}
+	})
+}
+
+func TestCancelOrderWithQuarantinedLot(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "butter", Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		lot, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "RECALLED", Quantity: 3, ExpiresAt: time.Now().AddDate(0, 0, 10)})
+		if err != nil {
+			t.Fatalf("AddLot: %v", err)
+		}
+		order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 2}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if _, err := inventory.QuarantineLot(ctx, lot.ID); err != nil {
+			t.Fatalf("QuarantineLot: %v", err)
+		}
+
+		// The returned units go back to the quarantined lot and straight
+		// out of stock again.
+		if err := orders.CancelOrder(ctx, order.ID); err != nil {
+			t.Fatalf("CancelOrder: %v", err)
+		}
+		if got, err := inventory.GetLot(ctx, lot.ID); err != nil || got.Quantity != 3 || got.Status != models.LotStatusQuarantined {
+			t.Errorf("GetLot = %+v, %v, want 3 Quarantined", got, err)
+		}
+		if report, err := inventory.CheckStockLevels(ctx, productID); err != nil || report.Total != 0 || report.Available != 0 {
+			t.Errorf("CheckStockLevels = %+v, %v, want no stock", report, err)
+		}
+		movements, err := inventory.GetStockMovements(ctx, models.MovementQuery{
+			MovementFilter: models.MovementFilter{ProductID: productID, Reason: models.MovementReasonQuarantine},
+		})
+		if err != nil || len(movements.Movements) != 2 || movements.Movements[1].Delta != -2 || movements.Movements[1].Reference != lot.ID {
+			t.Errorf("quarantine movements = %+v, %v, want the returned 2 taken out", movements, err)
+		}
+		if _, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 1}}}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("CreateOrder(quarantined stock) error = %v, want ErrInsufficientStock", err)
+		}
+	})
+}
+
+func TestTransferLots(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+
+		productID, destination := h.productID(), h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "cream", Stock: 1, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: destination, Name: "lots", Priority: 5000}); err != nil {
+			t.Fatalf("AddWarehouse: %v", err)
+		}
+		now := time.Now().UTC()
+		soon, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "SOON", Quantity: 3, ExpiresAt: now.AddDate(0, 0, 10)})
+		if err != nil {
+			t.Fatalf("AddLot: %v", err)
+		}
+		late, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, LotNumber: "LATE", Quantity: 2, ExpiresAt: now.AddDate(0, 0, 20)})
+		if err != nil {
+			t.Fatalf("AddLot: %v", err)
+		}
+		recalled, err := inventory.AddLot(ctx, models.Lot{ProductID: productID, WarehouseID: destination, LotNumber: "LATE", Quantity: 1, ExpiresAt: late.ExpiresAt})
+		if err != nil {
+			t.Fatalf("AddLot: %v", err)
+		}
+		if _, err := inventory.QuarantineLot(ctx, recalled.ID); err != nil {
+			t.Fatalf("QuarantineLot: %v", err)
+		}
+
+		transfer, err := inventory.CreateTransfer(ctx, models.Transfer{
+			SourceID:      DefaultWarehouseID,
+			DestinationID: destination,
+			Lines:         []models.TransferLine{{ProductID: productID, Quantity: 6}},
+		})
+		if err != nil {
+			t.Fatalf("CreateTransfer: %v", err)
+		}
+		if _, err := inventory.ShipTransfer(ctx, transfer.ID); err != nil {
+			t.Fatalf("ShipTransfer: %v", err)
+		}
+
+		// Both lots are emptied before the unit in no lot.
+		lots, err := inventory.GetTransferLots(ctx, transfer.ID)
+		if err != nil {
+			t.Fatalf("GetTransferLots: %v", err)
+		}
+		got := fmt.Sprint(lots)
+		want := fmt.Sprint([]models.TransferLot{
+			{TransferID: transfer.ID, LotID: soon.ID, LotNumber: "SOON", ProductID: productID, ExpiresAt: soon.ExpiresAt, Quantity: 3},
+			{TransferID: transfer.ID, LotID: late.ID, LotNumber: "LATE", ProductID: productID, ExpiresAt: late.ExpiresAt, Quantity: 2},
+		})
+		if got != want {
+			t.Errorf("GetTransferLots = %s, want %s", got, want)
+		}
+		if _, err := inventory.GetTransferLots(ctx, transfer.ID+"0"); !errors.Is(err, ErrTransferNotFound) {
+			t.Errorf("GetTransferLots(missing) error = %v, want ErrTransferNotFound", err)
+		}
+
+		// SOON is created at the destination; what goes to the quarantined
+		// LATE is taken straight out of stock again.
+		if _, err := inventory.ReceiveTransfer(ctx, transfer.ID); err != nil {
+			t.Fatalf("ReceiveTransfer: %v", err)
+		}
+		received, err := inventory.GetLots(ctx, models.LotFilter{ProductID: productID, WarehouseID: destination})
+		if err != nil || len(received) != 2 {
+			t.Fatalf("GetLots(destination) = %+v, %v, want 2 lots", received, err)
+		}
+		if lot := received[0]; lot.LotNumber != "SOON" || lot.Quantity != 3 || lot.Status != models.LotStatusActive || !lot.ExpiresAt.Equal(soon.ExpiresAt) {
+			t.Errorf("received lot = %+v, want 3 of SOON expiring at %v", lot, soon.ExpiresAt)
+		}
+		if lot := received[1]; lot.ID != recalled.ID || lot.Quantity != 3 || lot.Status != models.LotStatusQuarantined {
+			t.Errorf("received lot = %+v, want 3 in the quarantined LATE", lot)
+		}
+		report, err := inventory.CheckStockLevels(ctx, productID)
+		if err != nil {
+			t.Fatalf("CheckStockLevels: %v", err)
+		}
+		for _, level := range report.Levels {
+			if level.WarehouseID == destination && (level.Quantity != 4 || level.Available != 4) {
+				t.Errorf("destination level = %+v, want 4 available", level)
+			}
+		}
+	})
+}
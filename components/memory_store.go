@@ -0,0 +1,1005 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+// MemoryStore is a Store that keeps everything in memory. Transactions are
+// serialized and work on a copy of the data that replaces the original only
+// on commit, so it behaves like SQLStore without needing a database. The
+// copy is made table by table as a transaction first writes to each, so
+// what a transaction costs does not grow with the tables it leaves alone.
+type MemoryStore struct {
+	mu   sync.Mutex
+	data *memoryData
+}
+
+// NewMemoryStore initializes a new, empty MemoryStore instance.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: &memoryData{
+		products: make(map[string]models.Product),
+		warehouses: map[string]models.Warehouse{
+			DefaultWarehouseID: {ID: DefaultWarehouseID, Name: "Main warehouse"},
+		},
+		levels:         make(map[stockKey]int),
+		reserved:       make(map[stockKey]int),
+		transfers:      make(map[string]models.Transfer),
+		reservations:   make(map[string]models.Reservation),
+		alerts:         make(map[string]models.StockAlert),
+		suppliers:      make(map[string]models.Supplier),
+		purchaseOrders: make(map[string]models.PurchaseOrder),
+		lots:           make(map[string]models.Lot),
+		orders:         make(map[string]models.Order),
+	}}
+}
+
+// memoryData is the state of a MemoryStore.
+type memoryData struct {
+	products            map[string]models.Product
+	warehouses          map[string]models.Warehouse
+	levels              map[stockKey]int
+	reserved            map[stockKey]int
+	transfers           map[string]models.Transfer
+	reservations        map[string]models.Reservation
+	alerts              map[string]models.StockAlert
+	suppliers           map[string]models.Supplier
+	purchaseOrders      map[string]models.PurchaseOrder
+	lots                map[string]models.Lot
+	allocations         []models.LotAllocation
+	transferLots        []models.TransferLot
+	orders              map[string]models.Order
+	history             []models.OrderStatusChange
+	movements           []models.StockMovement
+	nextTransferID      int
+	nextReservationID   int
+	nextAlertID         int
+	nextPurchaseOrderID int
+	nextLotID           int
+	nextOrderID         int
+	nextItemID          int
+}
+
+func cloneOrder(order models.Order) models.Order {
+	order.Items = append([]models.OrderItem(nil), order.Items...)
+	return order
+}
+
+func cloneTransfer(transfer models.Transfer) models.Transfer {
+	transfer.Lines = append([]models.TransferLine(nil), transfer.Lines...)
+	return transfer
+}
+
+func clonePurchaseOrder(po models.PurchaseOrder) models.PurchaseOrder {
+	po.Lines = append([]models.PurchaseOrderLine(nil), po.Lines...)
+	return po
+}
+
+// InTx runs fn on a copy of the store's data, keeping the copy if fn
+// succeeds. The copy starts out sharing every table with the store's data;
+// see writable. The movements and status history are only ever appended to,
+// so they stay shared: what a failed transaction appends lies past the end
+// of the store's slices and is overwritten by the next.
+func (s *MemoryStore) InTx(ctx context.Context, fn func(ctx context.Context, tx StoreTx) error) error {
+	if tx, ok := txFromContext(ctx, s); ok {
+		return fn(ctx, tx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := *s.data
+	tx := &memoryStoreTx{data: &data, copied: make(map[any]bool)}
+	if err := fn(contextWithTx(ctx, s, tx), tx); err != nil {
+		return err
+	}
+	s.data = tx.data
+	return nil
+}
+
+// memoryStoreTx is the StoreTx of a MemoryStore.
+type memoryStoreTx struct {
+	data *memoryData
+	// copied holds the tables of data, by address, that the transaction
+	// has copied and may write to.
+	copied map[any]bool
+}
+
+// writable returns the table *m of the transaction's data for writing,
+// first replacing it with a copy if it is still shared with the store. The
+// values are copied shallowly, so slices in them must not be written to in
+// place.
+func writable[K comparable, V any](t *memoryStoreTx, m *map[K]V) map[K]V {
+	if !t.copied[m] {
+		*m = maps.Clone(*m)
+		t.copied[m] = true
+	}
+	return *m
+}
+
+// writableSlice is writable for a table kept in a slice whose elements are
+// written to in place.
+func writableSlice[V any](t *memoryStoreTx, s *[]V) []V {
+	if !t.copied[s] {
+		*s = slices.Clone(*s)
+		t.copied[s] = true
+	}
+	return *s
+}
+
+func (t *memoryStoreTx) InsertProduct(ctx context.Context, product models.Product) error {
+	if _, exists := t.data.products[product.ID]; exists {
+		return fmt.Errorf("product %s %w", product.ID, errDuplicate)
+	}
+	product.Tags = append([]string(nil), product.Tags...)
+	writable(t, &t.data.products)[product.ID] = product
+	if product.Stock > 0 {
+		writable(t, &t.data.levels)[stockKey{DefaultWarehouseID, product.ID}] = product.Stock
+	}
+	return nil
+}
+
+func (t *memoryStoreTx) GetProduct(ctx context.Context, productID string) (models.Product, error) {
+	product, ok := t.data.products[productID]
+	if !ok {
+		return models.Product{}, fmt.Errorf("product %s %w", productID, errNotFound)
+	}
+	return product, nil
+}
+
+func (t *memoryStoreTx) ListProducts(ctx context.Context, filter models.ProductFilter, page pageSpec) ([]models.Product, error) {
+	name := strings.ToLower(filter.NameContains)
+	var products []models.Product
+	for _, product := range t.data.products {
+		switch {
+		case !strings.Contains(strings.ToLower(product.Name), name),
+			filter.StockBelow != nil && product.Stock >= *filter.StockBelow,
+			filter.MinPrice != nil && product.Price < *filter.MinPrice,
+			filter.MaxPrice != nil && product.Price > *filter.MaxPrice,
+			filter.SupplierID != "" && product.SupplierID != filter.SupplierID:
+			continue
+		}
+		products = append(products, product)
+	}
+	return pageOf(products, productPager, page), nil
+}
+
+func (t *memoryStoreTx) SearchProducts(ctx context.Context, terms []string, limit int) ([]models.ProductMatch, error) {
+	var matches []models.ProductMatch
+	for _, product := range t.data.products {
+		if rank, ok := searchRank(product, terms); ok {
+			matches = append(matches, models.ProductMatch{Product: product, Rank: rank})
+		}
+	}
+	sortMatches(matches)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+func (t *memoryStoreTx) UpdateProduct(ctx context.Context, productID string, update models.ProductUpdate) (models.Product, error) {
+	product, err := t.GetProduct(ctx, productID)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if update.Name != nil {
+		product.Name = *update.Name
+	}
+	if update.Description != nil {
+		product.Description = *update.Description
+	}
+	if update.Tags != nil {
+		product.Tags = append([]string(nil), *update.Tags...)
+	}
+	if update.Price != nil {
+		product.Price = *update.Price
+	}
+	if update.ReorderPoint != nil {
+		product.ReorderPoint = *update.ReorderPoint
+	}
+	if update.ReorderQuantity != nil {
+		product.ReorderQuantity = *update.ReorderQuantity
+	}
+	if update.SupplierID != nil {
+		product.SupplierID = *update.SupplierID
+	}
+	if update.SafetyStock != nil {
+		product.SafetyStock = *update.SafetyStock
+	}
+	writable(t, &t.data.products)[productID] = product
+	return product, nil
+}
+
+func (t *memoryStoreTx) DeleteProduct(ctx context.Context, productID string) error {
+	if _, err := t.GetProduct(ctx, productID); err != nil {
+		return err
+	}
+	for _, order := range t.data.orders {
+		if hasProduct(order, productID) {
+			return fmt.Errorf("product %s %w", productID, errInUse)
+		}
+	}
+	for _, transfer := range t.data.transfers {
+		for _, line := range transfer.Lines {
+			if line.ProductID == productID {
+				return fmt.Errorf("product %s %w", productID, errInUse)
+			}
+		}
+	}
+	for _, po := range t.data.purchaseOrders {
+		for _, line := range po.Lines {
+			if line.ProductID == productID {
+				return fmt.Errorf("product %s %w", productID, errInUse)
+			}
+		}
+	}
+	delete(writable(t, &t.data.products), productID)
+	for key := range t.data.levels {
+		if key.productID == productID {
+			delete(writable(t, &t.data.levels), key)
+			delete(writable(t, &t.data.reserved), key)
+		}
+	}
+	for id, reservation := range t.data.reservations {
+		if reservation.ProductID == productID {
+			delete(writable(t, &t.data.reservations), id)
+		}
+	}
+	for id, alert := range t.data.alerts {
+		if alert.ProductID == productID {
+			delete(writable(t, &t.data.alerts), id)
+		}
+	}
+	for id, lot := range t.data.lots {
+		if lot.ProductID == productID {
+			delete(writable(t, &t.data.lots), id)
+		}
+	}
+	return nil
+}
+
+func (t *memoryStoreTx) AdjustStock(ctx context.Context, warehouseID, productID string, delta int) (models.Product, error) {
+	product, err := t.GetProduct(ctx, productID)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if _, err := t.GetWarehouse(ctx, warehouseID); err != nil {
+		return models.Product{}, err
+	}
+	key := stockKey{warehouseID, productID}
+	level, ok := t.data.levels[key]
+	if level+delta < t.data.reserved[key] {
+		return models.Product{}, fmt.Errorf("product %s at warehouse %s: %w", productID, warehouseID, errInsufficientStock)
+	}
+	if ok || delta != 0 {
+		writable(t, &t.data.levels)[key] = level + delta
+	}
+	product.Stock += delta
+	writable(t, &t.data.products)[productID] = product
+	return product, nil
+}
+
+func (t *memoryStoreTx) AdjustReserved(ctx context.Context, warehouseID, productID string, delta int) error {
+	if _, err := t.GetProduct(ctx, productID); err != nil {
+		return err
+	}
+	if _, err := t.GetWarehouse(ctx, warehouseID); err != nil {
+		return err
+	}
+	key := stockKey{warehouseID, productID}
+	reserved := t.data.reserved[key] + delta
+	if reserved < 0 || reserved > t.data.levels[key] {
+		return fmt.Errorf("product %s at warehouse %s: %w", productID, warehouseID, errInsufficientStock)
+	}
+	writable(t, &t.data.reserved)[key] = reserved
+	return nil
+}
+
+func (t *memoryStoreTx) ListStockLevels(ctx context.Context, productID string) ([]models.StockLevel, error) {
+	var levels []models.StockLevel
+	for _, warehouse := range t.sortedWarehouses() {
+		key := stockKey{warehouse.ID, productID}
+		if quantity, ok := t.data.levels[key]; ok {
+			levels = append(levels, models.StockLevel{WarehouseID: warehouse.ID, Quantity: quantity, Reserved: t.data.reserved[key]})
+		}
+	}
+	return levels, nil
+}
+
+func (t *memoryStoreTx) AppendMovement(ctx context.Context, movement *models.StockMovement) error {
+	movement.ID = strconv.Itoa(len(t.data.movements) + 1)
+	movement.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	t.data.movements = append(t.data.movements, *movement)
+	return nil
+}
+
+func (t *memoryStoreTx) ListMovements(ctx context.Context, filter models.MovementFilter, page pageSpec) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	for _, movement := range t.data.movements {
+		switch {
+		case filter.ProductID != "" && movement.ProductID != filter.ProductID,
+			filter.WarehouseID != "" && movement.WarehouseID != filter.WarehouseID,
+			filter.Reason != "" && movement.Reason != filter.Reason,
+			!filter.From.IsZero() && movement.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !movement.CreatedAt.Before(filter.To):
+			continue
+		}
+		movements = append(movements, movement)
+	}
+	return pageOf(movements, movementPager, page), nil
+}
+
+func (t *memoryStoreTx) StockAt(ctx context.Context, productID string, at time.Time) ([]models.StockLevel, int, error) {
+	quantities := make(map[string]int)
+	inTransit := 0
+	for _, movement := range t.data.movements {
+		if movement.ProductID != productID || movement.CreatedAt.After(at) {
+			continue
+		}
+		quantities[movement.WarehouseID] += movement.Delta
+		if isTransferMovement(movement.Reason) {
+			inTransit -= movement.Delta
+		}
+	}
+	var levels []models.StockLevel
+	for _, warehouse := range t.sortedWarehouses() {
+		if quantity, ok := quantities[warehouse.ID]; ok {
+			levels = append(levels, models.StockLevel{WarehouseID: warehouse.ID, Quantity: quantity})
+		}
+	}
+	return levels, inTransit, nil
+}
+
+func (t *memoryStoreTx) TotalStock(ctx context.Context) (int, error) {
+	total := 0
+	for _, product := range t.data.products {
+		total += product.Stock
+	}
+	return total, nil
+}
+
+func (t *memoryStoreTx) InsertWarehouse(ctx context.Context, warehouse models.Warehouse) error {
+	if _, exists := t.data.warehouses[warehouse.ID]; exists {
+		return fmt.Errorf("warehouse %s %w", warehouse.ID, errDuplicate)
+	}
+	writable(t, &t.data.warehouses)[warehouse.ID] = warehouse
+	return nil
+}
+
+func (t *memoryStoreTx) GetWarehouse(ctx context.Context, warehouseID string) (models.Warehouse, error) {
+	warehouse, ok := t.data.warehouses[warehouseID]
+	if !ok {
+		return models.Warehouse{}, fmt.Errorf("warehouse %s %w", warehouseID, errNotFound)
+	}
+	return warehouse, nil
+}
+
+func (t *memoryStoreTx) ListWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	return t.sortedWarehouses(), nil
+}
+
+// sortedWarehouses returns the warehouses in priority order, then by ID.
+func (t *memoryStoreTx) sortedWarehouses() []models.Warehouse {
+	warehouses := make([]models.Warehouse, 0, len(t.data.warehouses))
+	for _, warehouse := range t.data.warehouses {
+		warehouses = append(warehouses, warehouse)
+	}
+	sort.Slice(warehouses, func(i, j int) bool {
+		if warehouses[i].Priority != warehouses[j].Priority {
+			return warehouses[i].Priority < warehouses[j].Priority
+		}
+		return warehouses[i].ID < warehouses[j].ID
+	})
+	return warehouses
+}
+
+func (t *memoryStoreTx) InsertTransfer(ctx context.Context, transfer *models.Transfer) error {
+	t.data.nextTransferID++
+	transfer.ID = strconv.Itoa(t.data.nextTransferID)
+	transfer.CreatedAt = time.Now().UTC()
+	writable(t, &t.data.transfers)[transfer.ID] = cloneTransfer(*transfer)
+	return nil
+}
+
+func (t *memoryStoreTx) GetTransfer(ctx context.Context, transferID string) (models.Transfer, error) {
+	transfer, ok := t.data.transfers[transferID]
+	if !ok {
+		return models.Transfer{}, fmt.Errorf("transfer %s %w", transferID, errNotFound)
+	}
+	return cloneTransfer(transfer), nil
+}
+
+// LockTransfer is GetTransfer; transactions are already serialized.
+func (t *memoryStoreTx) LockTransfer(ctx context.Context, transferID string) (models.Transfer, error) {
+	return t.GetTransfer(ctx, transferID)
+}
+
+func (t *memoryStoreTx) ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error) {
+	var transfers []models.Transfer
+	for _, transfer := range t.data.transfers {
+		switch {
+		case filter.Status != "" && transfer.Status != filter.Status,
+			filter.WarehouseID != "" && transfer.SourceID != filter.WarehouseID && transfer.DestinationID != filter.WarehouseID:
+			continue
+		}
+		transfers = append(transfers, cloneTransfer(transfer))
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		a, _ := strconv.Atoi(transfers[i].ID)
+		b, _ := strconv.Atoi(transfers[j].ID)
+		return a > b
+	})
+	return transfers, nil
+}
+
+func (t *memoryStoreTx) SetTransferStatus(ctx context.Context, transferID string, status string) error {
+	transfer, ok := t.data.transfers[transferID]
+	if !ok {
+		return fmt.Errorf("transfer %s %w", transferID, errNotFound)
+	}
+	now := time.Now().UTC()
+	switch status {
+	case models.TransferStatusInTransit:
+		transfer.ShippedAt = &now
+	case models.TransferStatusReceived:
+		transfer.ReceivedAt = &now
+	}
+	transfer.Status = status
+	writable(t, &t.data.transfers)[transferID] = transfer
+	return nil
+}
+
+func (t *memoryStoreTx) InTransitStock(ctx context.Context, productID string) (int, error) {
+	total := 0
+	for _, transfer := range t.data.transfers {
+		if transfer.Status != models.TransferStatusInTransit {
+			continue
+		}
+		for _, line := range transfer.Lines {
+			if line.ProductID == productID {
+				total += line.Quantity
+			}
+		}
+	}
+	return total, nil
+}
+
+func (t *memoryStoreTx) InsertReservation(ctx context.Context, reservation *models.Reservation) error {
+	t.data.nextReservationID++
+	reservation.ID = strconv.Itoa(t.data.nextReservationID)
+	reservation.CreatedAt = time.Now().UTC()
+	writable(t, &t.data.reservations)[reservation.ID] = *reservation
+	return nil
+}
+
+func (t *memoryStoreTx) GetReservation(ctx context.Context, reservationID string) (models.Reservation, error) {
+	reservation, ok := t.data.reservations[reservationID]
+	if !ok {
+		return models.Reservation{}, fmt.Errorf("reservation %s %w", reservationID, errNotFound)
+	}
+	return reservation, nil
+}
+
+// LockReservation is GetReservation; transactions are already serialized.
+func (t *memoryStoreTx) LockReservation(ctx context.Context, reservationID string) (models.Reservation, error) {
+	return t.GetReservation(ctx, reservationID)
+}
+
+func (t *memoryStoreTx) ListReservations(ctx context.Context, filter models.ReservationFilter) ([]models.Reservation, error) {
+	var reservations []models.Reservation
+	for _, reservation := range t.data.reservations {
+		switch {
+		case filter.ProductID != "" && reservation.ProductID != filter.ProductID,
+			filter.Status != "" && reservation.Status != filter.Status,
+			!filter.ExpiredBy.IsZero() && reservation.ExpiresAt.After(filter.ExpiredBy):
+			continue
+		}
+		reservations = append(reservations, reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return orderSeq(reservations[i].ID) < orderSeq(reservations[j].ID)
+	})
+	return reservations, nil
+}
+
+func (t *memoryStoreTx) SetReservationStatus(ctx context.Context, reservationID string, status string) error {
+	reservation, ok := t.data.reservations[reservationID]
+	if !ok {
+		return fmt.Errorf("reservation %s %w", reservationID, errNotFound)
+	}
+	reservation.Status = status
+	writable(t, &t.data.reservations)[reservationID] = reservation
+	return nil
+}
+
+func (t *memoryStoreTx) OpenStockAlert(ctx context.Context, alert *models.StockAlert) (bool, error) {
+	for _, open := range t.data.alerts {
+		if open.ProductID == alert.ProductID && open.ResolvedAt == nil {
+			return false, nil
+		}
+	}
+	t.data.nextAlertID++
+	alert.ID = strconv.Itoa(t.data.nextAlertID)
+	alert.CreatedAt = time.Now().UTC()
+	writable(t, &t.data.alerts)[alert.ID] = *alert
+	return true, nil
+}
+
+func (t *memoryStoreTx) ResolveStockAlert(ctx context.Context, productID string) error {
+	for id, alert := range t.data.alerts {
+		if alert.ProductID == productID && alert.ResolvedAt == nil {
+			now := time.Now().UTC()
+			alert.ResolvedAt = &now
+			writable(t, &t.data.alerts)[id] = alert
+		}
+	}
+	return nil
+}
+
+func (t *memoryStoreTx) ListStockAlerts(ctx context.Context, filter models.StockAlertFilter) ([]models.StockAlert, error) {
+	var alerts []models.StockAlert
+	for _, alert := range t.data.alerts {
+		switch {
+		case filter.ProductID != "" && alert.ProductID != filter.ProductID,
+			filter.Open && alert.ResolvedAt != nil,
+			filter.Unnotified && alert.NotifiedAt != nil:
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return orderSeq(alerts[i].ID) < orderSeq(alerts[j].ID)
+	})
+	return alerts, nil
+}
+
+func (t *memoryStoreTx) MarkStockAlertNotified(ctx context.Context, alertID string) (bool, error) {
+	alert, ok := t.data.alerts[alertID]
+	if !ok || alert.NotifiedAt != nil {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	alert.NotifiedAt = &now
+	writable(t, &t.data.alerts)[alertID] = alert
+	return true, nil
+}
+
+func (t *memoryStoreTx) InsertSupplier(ctx context.Context, supplier models.Supplier) error {
+	if _, exists := t.data.suppliers[supplier.ID]; exists {
+		return fmt.Errorf("supplier %s %w", supplier.ID, errDuplicate)
+	}
+	writable(t, &t.data.suppliers)[supplier.ID] = supplier
+	return nil
+}
+
+func (t *memoryStoreTx) GetSupplier(ctx context.Context, supplierID string) (models.Supplier, error) {
+	supplier, ok := t.data.suppliers[supplierID]
+	if !ok {
+		return models.Supplier{}, fmt.Errorf("supplier %s %w", supplierID, errNotFound)
+	}
+	return supplier, nil
+}
+
+func (t *memoryStoreTx) ListSuppliers(ctx context.Context) ([]models.Supplier, error) {
+	suppliers := make([]models.Supplier, 0, len(t.data.suppliers))
+	for _, supplier := range t.data.suppliers {
+		suppliers = append(suppliers, supplier)
+	}
+	sort.Slice(suppliers, func(i, j int) bool { return suppliers[i].ID < suppliers[j].ID })
+	return suppliers, nil
+}
+
+func (t *memoryStoreTx) UpdateSupplier(ctx context.Context, supplier models.Supplier) error {
+	if _, err := t.GetSupplier(ctx, supplier.ID); err != nil {
+		return err
+	}
+	writable(t, &t.data.suppliers)[supplier.ID] = supplier
+	return nil
+}
+
+func (t *memoryStoreTx) DeleteSupplier(ctx context.Context, supplierID string) error {
+	if _, err := t.GetSupplier(ctx, supplierID); err != nil {
+		return err
+	}
+	for _, po := range t.data.purchaseOrders {
+		if po.SupplierID == supplierID {
+			return fmt.Errorf("supplier %s %w", supplierID, errInUse)
+		}
+	}
+	delete(writable(t, &t.data.suppliers), supplierID)
+	for id, product := range t.data.products {
+		if product.SupplierID == supplierID {
+			product.SupplierID = ""
+			writable(t, &t.data.products)[id] = product
+		}
+	}
+	return nil
+}
+
+func (t *memoryStoreTx) InsertPurchaseOrder(ctx context.Context, po *models.PurchaseOrder) error {
+	t.data.nextPurchaseOrderID++
+	po.ID = strconv.Itoa(t.data.nextPurchaseOrderID)
+	po.CreatedAt = time.Now().UTC()
+	writable(t, &t.data.purchaseOrders)[po.ID] = clonePurchaseOrder(*po)
+	return nil
+}
+
+func (t *memoryStoreTx) GetPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error) {
+	po, ok := t.data.purchaseOrders[poID]
+	if !ok {
+		return models.PurchaseOrder{}, fmt.Errorf("purchase order %s %w", poID, errNotFound)
+	}
+	return clonePurchaseOrder(po), nil
+}
+
+// LockPurchaseOrder is GetPurchaseOrder; transactions are already
+// serialized.
+func (t *memoryStoreTx) LockPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error) {
+	return t.GetPurchaseOrder(ctx, poID)
+}
+
+func (t *memoryStoreTx) ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	var pos []models.PurchaseOrder
+	for _, po := range t.data.purchaseOrders {
+		switch {
+		case filter.Status != "" && po.Status != filter.Status,
+			filter.SupplierID != "" && po.SupplierID != filter.SupplierID:
+			continue
+		}
+		pos = append(pos, clonePurchaseOrder(po))
+	}
+	sort.Slice(pos, func(i, j int) bool {
+		a, _ := strconv.Atoi(pos[i].ID)
+		b, _ := strconv.Atoi(pos[j].ID)
+		return a > b
+	})
+	return pos, nil
+}
+
+func (t *memoryStoreTx) UpdatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) error {
+	current, ok := t.data.purchaseOrders[po.ID]
+	if !ok {
+		return fmt.Errorf("purchase order %s %w", po.ID, errNotFound)
+	}
+	current.SupplierID = po.SupplierID
+	current.WarehouseID = po.WarehouseID
+	current.ExpectedAt = po.ExpectedAt
+	current.Lines = append([]models.PurchaseOrderLine(nil), po.Lines...)
+	writable(t, &t.data.purchaseOrders)[po.ID] = current
+	return nil
+}
+
+func (t *memoryStoreTx) SetPurchaseOrderStatus(ctx context.Context, poID string, status string) error {
+	po, ok := t.data.purchaseOrders[poID]
+	if !ok {
+		return fmt.Errorf("purchase order %s %w", poID, errNotFound)
+	}
+	now := time.Now().UTC()
+	switch status {
+	case models.PurchaseOrderStatusSent:
+		po.SentAt = &now
+	case models.PurchaseOrderStatusClosed:
+		po.ClosedAt = &now
+	}
+	po.Status = status
+	writable(t, &t.data.purchaseOrders)[poID] = po
+	return nil
+}
+
+func (t *memoryStoreTx) AddReceived(ctx context.Context, poID, productID string, quantity int) error {
+	po, ok := t.data.purchaseOrders[poID]
+	if ok {
+		for i := range po.Lines {
+			if po.Lines[i].ProductID == productID {
+				po = clonePurchaseOrder(po)
+				po.Lines[i].Received += quantity
+				po.Lines[i].OverReceived = max(po.Lines[i].Received-po.Lines[i].Quantity, 0)
+				writable(t, &t.data.purchaseOrders)[poID] = po
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("purchase order %s line %s %w", poID, productID, errNotFound)
+}
+
+func (t *memoryStoreTx) DeletePurchaseOrder(ctx context.Context, poID string) error {
+	if _, ok := t.data.purchaseOrders[poID]; !ok {
+		return fmt.Errorf("purchase order %s %w", poID, errNotFound)
+	}
+	delete(writable(t, &t.data.purchaseOrders), poID)
+	return nil
+}
+
+func (t *memoryStoreTx) OnOrder(ctx context.Context) (map[string]int, error) {
+	units := make(map[string]int)
+	for _, po := range t.data.purchaseOrders {
+		switch po.Status {
+		case models.PurchaseOrderStatusReceived, models.PurchaseOrderStatusClosed:
+			continue
+		}
+		for _, line := range po.Lines {
+			if line.Received < line.Quantity {
+				units[line.ProductID] += line.Quantity - line.Received
+			}
+		}
+	}
+	return units, nil
+}
+
+func (t *memoryStoreTx) InsertLot(ctx context.Context, lot *models.Lot) error {
+	for _, other := range t.data.lots {
+		if other.ProductID == lot.ProductID && other.WarehouseID == lot.WarehouseID && other.LotNumber == lot.LotNumber {
+			return fmt.Errorf("lot %s of product %s %w", lot.LotNumber, lot.ProductID, errDuplicate)
+		}
+	}
+	t.data.nextLotID++
+	lot.ID = strconv.Itoa(t.data.nextLotID)
+	lot.CreatedAt = time.Now().UTC()
+	writable(t, &t.data.lots)[lot.ID] = *lot
+	return nil
+}
+
+func (t *memoryStoreTx) GetLot(ctx context.Context, lotID string) (models.Lot, error) {
+	lot, ok := t.data.lots[lotID]
+	if !ok {
+		return models.Lot{}, fmt.Errorf("lot %s %w", lotID, errNotFound)
+	}
+	return lot, nil
+}
+
+func (t *memoryStoreTx) LockLot(ctx context.Context, lotID string) (models.Lot, error) {
+	return t.GetLot(ctx, lotID)
+}
+
+func (t *memoryStoreTx) ListLots(ctx context.Context, filter models.LotFilter) ([]models.Lot, error) {
+	var lots []models.Lot
+	for _, lot := range t.data.lots {
+		switch {
+		case filter.ProductID != "" && lot.ProductID != filter.ProductID,
+			filter.WarehouseID != "" && lot.WarehouseID != filter.WarehouseID,
+			filter.Status != "" && lot.Status != filter.Status,
+			filter.LotNumber != "" && lot.LotNumber != filter.LotNumber,
+			!filter.ExpiresBy.IsZero() && lot.ExpiresAt.After(filter.ExpiresBy):
+			continue
+		}
+		lots = append(lots, lot)
+	}
+	sortLots(lots)
+	return lots, nil
+}
+
+func (t *memoryStoreTx) LockActiveLots(ctx context.Context, warehouseID, productID string) ([]models.Lot, error) {
+	var lots []models.Lot
+	for _, lot := range t.data.lots {
+		if lot.WarehouseID == warehouseID && lot.ProductID == productID && lot.Status == models.LotStatusActive && lot.Quantity > 0 {
+			lots = append(lots, lot)
+		}
+	}
+	sortLots(lots)
+	return lots, nil
+}
+
+// sortLots sorts lots earliest expiry first and then by ID, the order
+// SQLStore reads them in.
+func sortLots(lots []models.Lot) {
+	sort.Slice(lots, func(i, j int) bool {
+		if !lots[i].ExpiresAt.Equal(lots[j].ExpiresAt) {
+			return lots[i].ExpiresAt.Before(lots[j].ExpiresAt)
+		}
+		return orderSeq(lots[i].ID) < orderSeq(lots[j].ID)
+	})
+}
+
+func (t *memoryStoreTx) AddLotQuantity(ctx context.Context, lotID string, delta int) error {
+	lot, err := t.GetLot(ctx, lotID)
+	if err != nil {
+		return err
+	}
+	if lot.Quantity+delta < 0 {
+		return fmt.Errorf("lot %s: %w", lotID, errInsufficientStock)
+	}
+	lot.Quantity += delta
+	writable(t, &t.data.lots)[lotID] = lot
+	return nil
+}
+
+func (t *memoryStoreTx) SetLotStatus(ctx context.Context, lotID string, status string) error {
+	lot, err := t.GetLot(ctx, lotID)
+	if err != nil {
+		return err
+	}
+	if status == models.LotStatusQuarantined {
+		now := time.Now().UTC()
+		lot.QuarantinedAt = &now
+	}
+	lot.Status = status
+	writable(t, &t.data.lots)[lotID] = lot
+	return nil
+}
+
+func (t *memoryStoreTx) InsertLotAllocation(ctx context.Context, allocation models.LotAllocation) error {
+	for i, other := range t.data.allocations {
+		if other.OrderItemID == allocation.OrderItemID && other.LotID == allocation.LotID {
+			writableSlice(t, &t.data.allocations)[i].Quantity += allocation.Quantity
+			return nil
+		}
+	}
+	t.data.allocations = append(t.data.allocations, models.LotAllocation{
+		OrderItemID: allocation.OrderItemID,
+		LotID:       allocation.LotID,
+		Quantity:    allocation.Quantity,
+	})
+	return nil
+}
+
+func (t *memoryStoreTx) ListLotAllocations(ctx context.Context, orderID string) ([]models.LotAllocation, error) {
+	items := make(map[string]bool)
+	for _, item := range t.data.orders[orderID].Items {
+		items[item.ID] = true
+	}
+	var allocations []models.LotAllocation
+	for _, allocation := range t.data.allocations {
+		if !items[allocation.OrderItemID] {
+			continue
+		}
+		lot := t.data.lots[allocation.LotID]
+		allocation.LotNumber, allocation.ProductID, allocation.WarehouseID, allocation.ExpiresAt = lot.LotNumber, lot.ProductID, lot.WarehouseID, lot.ExpiresAt
+		allocations = append(allocations, allocation)
+	}
+	sort.Slice(allocations, func(i, j int) bool {
+		a, b := allocations[i], allocations[j]
+		if a.OrderItemID != b.OrderItemID {
+			return orderSeq(a.OrderItemID) < orderSeq(b.OrderItemID)
+		}
+		if !a.ExpiresAt.Equal(b.ExpiresAt) {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		return orderSeq(a.LotID) < orderSeq(b.LotID)
+	})
+	return allocations, nil
+}
+
+func (t *memoryStoreTx) InsertTransferLot(ctx context.Context, lot models.TransferLot) error {
+	for i, other := range t.data.transferLots {
+		if other.TransferID == lot.TransferID && other.LotID == lot.LotID {
+			writableSlice(t, &t.data.transferLots)[i].Quantity += lot.Quantity
+			return nil
+		}
+	}
+	t.data.transferLots = append(t.data.transferLots, models.TransferLot{
+		TransferID: lot.TransferID,
+		LotID:      lot.LotID,
+		Quantity:   lot.Quantity,
+	})
+	return nil
+}
+
+func (t *memoryStoreTx) ListTransferLots(ctx context.Context, transferID string) ([]models.TransferLot, error) {
+	var lots []models.TransferLot
+	for _, lot := range t.data.transferLots {
+		if lot.TransferID != transferID {
+			continue
+		}
+		source := t.data.lots[lot.LotID]
+		lot.LotNumber, lot.ProductID, lot.ExpiresAt = source.LotNumber, source.ProductID, source.ExpiresAt
+		lots = append(lots, lot)
+	}
+	sort.Slice(lots, func(i, j int) bool {
+		a, b := lots[i], lots[j]
+		if a.ProductID != b.ProductID {
+			return a.ProductID < b.ProductID
+		}
+		if !a.ExpiresAt.Equal(b.ExpiresAt) {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		return orderSeq(a.LotID) < orderSeq(b.LotID)
+	})
+	return lots, nil
+}
+
+func (t *memoryStoreTx) InsertOrder(ctx context.Context, order *models.Order) error {
+	t.data.nextOrderID++
+	order.ID = strconv.Itoa(t.data.nextOrderID)
+	order.CreatedAt = time.Now().UTC()
+	for i := range order.Items {
+		t.data.nextItemID++
+		order.Items[i].ID = strconv.Itoa(t.data.nextItemID)
+	}
+	writable(t, &t.data.orders)[order.ID] = cloneOrder(*order)
+	return nil
+}
+
+func (t *memoryStoreTx) GetOrder(ctx context.Context, orderID string) (models.Order, error) {
+	order, ok := t.data.orders[orderID]
+	if !ok {
+		return models.Order{}, fmt.Errorf("order %s %w", orderID, errNotFound)
+	}
+	return cloneOrder(order), nil
+}
+
+// LockOrder is GetOrder; transactions are already serialized.
+func (t *memoryStoreTx) LockOrder(ctx context.Context, orderID string) (models.Order, error) {
+	return t.GetOrder(ctx, orderID)
+}
+
+func (t *memoryStoreTx) ListOrders(ctx context.Context, filter models.OrderFilter, page pageSpec) ([]models.Order, error) {
+	var orders []models.Order
+	for _, order := range t.data.orders {
+		switch {
+		case filter.Status != "" && order.Status != filter.Status,
+			filter.ProductID != "" && !hasProduct(order, filter.ProductID),
+			!filter.From.IsZero() && order.CreatedAt.Before(filter.From),
+			!filter.To.IsZero() && !order.CreatedAt.Before(filter.To):
+			continue
+		}
+		orders = append(orders, cloneOrder(order))
+	}
+	return pageOf(orders, orderPager, page), nil
+}
+
+// hasProduct reports whether order has a line for the product.
+func hasProduct(order models.Order, productID string) bool {
+	for _, item := range order.Items {
+		if item.ProductID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *memoryStoreTx) SetOrderStatus(ctx context.Context, orderID string, status string) error {
+	order, ok := t.data.orders[orderID]
+	if !ok {
+		return fmt.Errorf("order %s %w", orderID, errNotFound)
+	}
+	order.Status = status
+	writable(t, &t.data.orders)[orderID] = order
+	return nil
+}
+
+func (t *memoryStoreTx) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	counts := make(map[string]int)
+	for _, order := range t.data.orders {
+		counts[order.Status]++
+	}
+	return counts, nil
+}
+
+func (t *memoryStoreTx) UnitsOrdered(ctx context.Context, since time.Time) (map[string]int, error) {
+	units := make(map[string]int)
+	for _, order := range t.data.orders {
+		if order.CreatedAt.Before(since) || order.Status == models.OrderStatusCancelled || order.Status == models.OrderStatusReturned {
+			continue
+		}
+		for _, item := range order.Items {
+			units[item.ProductID] += item.Quantity
+		}
+	}
+	return units, nil
+}
+
+func (t *memoryStoreTx) AppendStatusChange(ctx context.Context, change models.OrderStatusChange) error {
+	change.ChangedAt = time.Now().UTC()
+	t.data.history = append(t.data.history, change)
+	return nil
+}
+
+func (t *memoryStoreTx) ListStatusChanges(ctx context.Context, orderID string) ([]models.OrderStatusChange, error) {
+	var timeline []models.OrderStatusChange
+	for _, change := range t.data.history {
+		if change.OrderID == orderID {
+			timeline = append(timeline, change)
+		}
+	}
+	return timeline, nil
+}
@@ -0,0 +1,97 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+// GetStockMovements retrieves one page of the stock ledger entries matching
+// the query. Movements of deleted products remain in the ledger.
+func (im *InventoryManagementImpl) GetStockMovements(ctx context.Context, q models.MovementQuery) (models.MovementPage, error) {
+	spec, err := movementPager.plan(q.Sort, q.Cursor, q.Limit)
+	if err != nil {
+		return models.MovementPage{}, err
+	}
+
+	var movements []models.StockMovement
+	err = im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		movements, err = tx.ListMovements(ctx, q.MovementFilter, spec)
+		return err
+	})
+	if err != nil {
+		return models.MovementPage{}, fmt.Errorf("could not fetch stock movements: %w", err)
+	}
+
+	var page models.MovementPage
+	page.Movements, page.NextCursor, page.PrevCursor = movementPager.page(movements, spec)
+	return page, nil
+}
+
+// CheckStockLevelsAt reconstructs a product's stock report as of a past
+// time from the stock ledger. Reservations are not part of the ledger, so
+// all of the reconstructed stock is reported as available.
+func (im *InventoryManagementImpl) CheckStockLevelsAt(ctx context.Context, productID string, at time.Time) (models.StockReport, error) {
+	report := models.StockReport{ProductID: productID, Levels: []models.StockLevel{}}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if _, err := tx.GetProduct(ctx, productID); errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+		} else if err != nil {
+			return fmt.Errorf("could not fetch product: %w", err)
+		}
+		levels, inTransit, err := tx.StockAt(ctx, productID, at)
+		if err != nil {
+			return fmt.Errorf("could not sum stock movements: %w", err)
+		}
+		for _, level := range levels {
+			level.Available = level.Quantity
+			report.Total += level.Quantity
+			report.Levels = append(report.Levels, level)
+		}
+		report.Available = report.Total
+		report.InTransit = inTransit
+		return nil
+	})
+	if err != nil {
+		return models.StockReport{}, err
+	}
+	return report, nil
+}
+
+// adjustStock applies a stock movement to its warehouse and records it in
+// the ledger, returning the updated product. Errors are translated to the
+// component errors.
+func adjustStock(ctx context.Context, tx StoreTx, movement models.StockMovement) (models.Product, error) {
+	product, _, err := changeStock(ctx, tx, movement.WarehouseID, movement.ProductID, movement.Delta)
+	if err != nil {
+		return models.Product{}, err
+	}
+	if err := recordMovement(ctx, tx, movement); err != nil {
+		return models.Product{}, err
+	}
+	return product, nil
+}
+
+// recordMovement appends a stock movement, attributed to the actor in ctx,
+// to the ledger. Movements that change nothing are not recorded.
+func recordMovement(ctx context.Context, tx StoreTx, movement models.StockMovement) error {
+	if movement.Delta == 0 {
+		return nil
+	}
+	movement.Actor = ActorFromContext(ctx)
+	if err := tx.AppendMovement(ctx, &movement); err != nil {
+		return fmt.Errorf("could not record stock movement: %w", err)
+	}
+	return nil
+}
+
+// isTransferMovement reports whether a movement with the reason moves stock
+// between warehouses, so that the sum of such movements is the stock in
+// transit.
+func isTransferMovement(reason string) bool {
+	return reason == models.MovementReasonTransferOut || reason == models.MovementReasonTransferIn
+}
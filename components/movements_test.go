@@ -0,0 +1,133 @@
+package components
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"service-weaver-app/database"
+	"service-weaver-app/models"
+)
+
+func TestStockMovements(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := WithActor(context.Background(), "alice")
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		east := h.productID()
+		if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: east, Name: "east", Priority: 3000}); err != nil {
+			t.Fatalf("AddWarehouse: %v", err)
+		}
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "ledger", Stock: 5, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		order, err := orders.CreateOrder(ctx, models.Order{WarehouseID: DefaultWarehouseID, Items: []models.OrderItem{{ProductID: productID, Quantity: 2}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if err := orders.CancelOrder(ctx, order.ID); err != nil {
+			t.Fatalf("CancelOrder: %v", err)
+		}
+		if err := inventory.UpdateStockAt(ctx, east, productID, 3); err != nil {
+			t.Fatalf("UpdateStockAt: %v", err)
+		}
+		if err := inventory.UpdateStock(ctx, productID, 0); err != nil {
+			t.Fatalf("UpdateStock(0): %v", err)
+		}
+		transfer, err := inventory.CreateTransfer(ctx, models.Transfer{SourceID: DefaultWarehouseID, DestinationID: east, Lines: []models.TransferLine{{ProductID: productID, Quantity: 4}}})
+		if err != nil {
+			t.Fatalf("CreateTransfer: %v", err)
+		}
+		if _, err := inventory.ShipTransfer(ctx, transfer.ID); err != nil {
+			t.Fatalf("ShipTransfer: %v", err)
+		}
+		if _, err := inventory.ReceiveTransfer(ctx, transfer.ID); err != nil {
+			t.Fatalf("ReceiveTransfer: %v", err)
+		}
+
+		page, err := inventory.GetStockMovements(ctx, models.MovementQuery{MovementFilter: models.MovementFilter{ProductID: productID}})
+		if err != nil {
+			t.Fatalf("GetStockMovements: %v", err)
+		}
+		var got []string
+		for _, m := range page.Movements {
+			if m.Actor != "alice" {
+				t.Errorf("movement %+v has actor %q, want alice", m, m.Actor)
+			}
+			got = append(got, fmt.Sprintf("%s %d %s %s", m.WarehouseID, m.Delta, m.Reason, m.Reference))
+		}
+		want := []string{
+			fmt.Sprintf("%s 5 initial ", DefaultWarehouseID),
+			fmt.Sprintf("%s -2 sale %s", DefaultWarehouseID, order.ID),
+			fmt.Sprintf("%s 2 cancellation %s", DefaultWarehouseID, order.ID),
+			fmt.Sprintf("%s 3 adjustment ", east),
+			fmt.Sprintf("%s -4 transfer_out %s", DefaultWarehouseID, transfer.ID),
+			fmt.Sprintf("%s 4 transfer_in %s", east, transfer.ID),
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("movements =\n%q\nwant\n%q", got, want)
+		}
+
+		// Replaying the ledger up to each movement reconstructs the stock
+		// after it.
+		reports := []string{
+			fmt.Sprintf("5 0 [{%s 5 0 0 5}]", DefaultWarehouseID),
+			fmt.Sprintf("3 0 [{%s 3 0 0 3}]", DefaultWarehouseID),
+			fmt.Sprintf("5 0 [{%s 5 0 0 5}]", DefaultWarehouseID),
+			fmt.Sprintf("8 0 [{%s 5 0 0 5} {%s 3 0 0 3}]", DefaultWarehouseID, east),
+			fmt.Sprintf("4 4 [{%s 1 0 0 1} {%s 3 0 0 3}]", DefaultWarehouseID, east),
+			fmt.Sprintf("8 0 [{%s 1 0 0 1} {%s 7 0 0 7}]", DefaultWarehouseID, east),
+		}
+		for i, m := range page.Movements {
+			report, err := inventory.CheckStockLevelsAt(ctx, productID, m.CreatedAt)
+			if err != nil {
+				t.Fatalf("CheckStockLevelsAt: %v", err)
+			}
+			if got := fmt.Sprint(report.Total, report.InTransit, report.Levels); got != reports[i] {
+				t.Errorf("stock after %s = %s, want %s", m.Reason, got, reports[i])
+			}
+		}
+		report, err := inventory.CheckStockLevelsAt(ctx, productID, page.Movements[0].CreatedAt.Add(-1))
+		if err != nil || report.Total != 0 || len(report.Levels) != 0 {
+			t.Errorf("stock before the product existed = %+v, %v", report, err)
+		}
+
+		sales, err := inventory.GetStockMovements(ctx, models.MovementQuery{
+			MovementFilter: models.MovementFilter{ProductID: productID, Reason: models.MovementReasonSale},
+		})
+		if err != nil || len(sales.Movements) != 1 || sales.Movements[0].Reference != order.ID {
+			t.Errorf("sales = %+v, %v", sales, err)
+		}
+		latest, err := inventory.GetStockMovements(ctx, models.MovementQuery{
+			MovementFilter: models.MovementFilter{ProductID: productID},
+			Sort:           "-created_at",
+			Limit:          2,
+		})
+		if err != nil || len(latest.Movements) != 2 || latest.Movements[0].Reason != models.MovementReasonTransferIn || latest.NextCursor == "" {
+			t.Errorf("latest movements = %+v, %v", latest, err)
+		}
+	})
+}
+
+func TestStockMovementsAreAppendOnly(t *testing.T) {
+	forEachDB(t, func(t *testing.T, db *sql.DB, dialect database.Dialect) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(NewSQLStore(db, dialect))
+		productID := fmt.Sprintf("append-only-%s", dialect)
+		t.Cleanup(func() { cleanupProducts(db, productID) })
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "ledger", Stock: 1, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+
+		rebind := dialect.Rebind
+		if _, err := db.Exec(rebind(`UPDATE stock_movements SET delta = 2 WHERE product_id = $1`), productID); err == nil {
+			t.Error("updating a stock movement succeeded")
+		}
+		if _, err := db.Exec(rebind(`DELETE FROM stock_movements WHERE product_id = $1`), productID); err == nil {
+			t.Error("deleting a stock movement succeeded")
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package components
+
+import (
+	"fmt"
+
+	"service-weaver-app/models"
+)
+
+// orderTransitions lists the statuses an order may move to from each status.
+// Delivered orders can only be returned; Cancelled and Returned are final.
+var orderTransitions = map[string][]string{
+	models.OrderStatusPending:   {models.OrderStatusConfirmed, models.OrderStatusCancelled},
+	models.OrderStatusConfirmed: {models.OrderStatusPicked, models.OrderStatusCancelled},
+	models.OrderStatusPicked:    {models.OrderStatusShipped, models.OrderStatusCancelled},
+	models.OrderStatusShipped:   {models.OrderStatusDelivered, models.OrderStatusReturned},
+	models.OrderStatusDelivered: {models.OrderStatusReturned},
+	models.OrderStatusCancelled: nil,
+	models.OrderStatusReturned:  nil,
+}
+
+// InvalidTransitionError is returned when an order cannot move from its
+// current status to the requested one. It matches ErrInvalidTransition.
+type InvalidTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *InvalidTransitionError) Error() string {
+	if _, known := orderTransitions[e.To]; !known {
+		return fmt.Sprintf("unknown order status %q", e.To)
+	}
+	return fmt.Sprintf("cannot change order status from %s to %s", e.From, e.To)
+}
+
+// Is reports whether target is ErrInvalidTransition.
+func (e *InvalidTransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// checkTransition returns an *InvalidTransitionError unless an order in
+// status from may move to status to.
+func checkTransition(from, to string) error {
+	for _, next := range orderTransitions[from] {
+		if next == to {
+			return nil
+		}
+	}
+	return &InvalidTransitionError{From: from, To: to}
+}
@@ -0,0 +1,40 @@
+package components
+
+import (
+	"errors"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		ok       bool
+	}{
+		{models.OrderStatusPending, models.OrderStatusConfirmed, true},
+		{models.OrderStatusConfirmed, models.OrderStatusPicked, true},
+		{models.OrderStatusPicked, models.OrderStatusShipped, true},
+		{models.OrderStatusShipped, models.OrderStatusDelivered, true},
+		{models.OrderStatusDelivered, models.OrderStatusReturned, true},
+		{models.OrderStatusPending, models.OrderStatusCancelled, true},
+		{models.OrderStatusShipped, models.OrderStatusPending, false},
+		{models.OrderStatusPending, models.OrderStatusShipped, false},
+		{models.OrderStatusShipped, models.OrderStatusCancelled, false},
+		{models.OrderStatusCancelled, models.OrderStatusPending, false},
+		{models.OrderStatusPending, models.OrderStatusPending, false},
+		{models.OrderStatusPending, "Shiped", false},
+	}
+	for _, tt := range tests {
+		err := checkTransition(tt.from, tt.to)
+		if tt.ok && err != nil {
+			t.Errorf("checkTransition(%q, %q) = %v, want nil", tt.from, tt.to, err)
+		}
+		if !tt.ok {
+			var invalid *InvalidTransitionError
+			if !errors.As(err, &invalid) {
+				t.Errorf("checkTransition(%q, %q) = %v, want *InvalidTransitionError", tt.from, tt.to, err)
+			}
+		}
+	}
+}
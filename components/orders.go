@@ -2,71 +2,326 @@ package components
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"sort"
+
 	"service-weaver-app/models"
 )
 
 type OrderProcessingImpl struct {
 	inventory InventoryManagement
-	db        *sql.DB
+	store     Store
 }
 
-func NewOrderProcessing(inventory InventoryManagement, db *sql.DB) *OrderProcessingImpl {
-	return &OrderProcessingImpl{inventory: inventory, db: db}
+func NewOrderProcessing(inventory InventoryManagement, store Store) *OrderProcessingImpl {
+	return &OrderProcessingImpl{inventory: inventory, store: store}
 }
 
+// CreateOrder allocates every line to a warehouse, reserves its stock there
+// and records the order, and the stock and lots it took, in a single
+// transaction. Each line takes stock from the product's lots at its
+// warehouse first-expired-first-out. Each stock decrement is
+// conditional on enough stock being available, so concurrent orders can
+// never drive a warehouse's stock below zero, and if any line fails the
+// whole order is rolled back.
 func (op *OrderProcessingImpl) CreateOrder(ctx context.Context, order models.Order) (models.Order, error) {
-	stock, err := op.inventory.CheckStock(ctx, order.ProductID)
-	if err != nil {
-		return models.Order{}, err
+	if len(order.Items) == 0 {
+		return models.Order{}, fmt.Errorf("%w: no items", ErrInvalidOrder)
 	}
-	if stock < order.Quantity {
-		return models.Order{}, fmt.Errorf("insufficient stock")
+	for _, item := range order.Items {
+		if item.Quantity <= 0 {
+			return models.Order{}, fmt.Errorf("%w: quantity must be positive", ErrInvalidOrder)
+		}
 	}
 
-	product, err := op.inventory.GetProduct(ctx, order.ProductID)
+	lines := byProduct(order.Items)
+	err := op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if err := allocate(ctx, tx, &order); err != nil {
+			return err
+		}
+		order.Total = 0
+		allocations := make([][]models.LotAllocation, len(order.Items))
+		for _, i := range lines {
+			item := &order.Items[i]
+			var err error
+			if allocations[i], err = reserveStock(ctx, tx, item); err != nil {
+				return err
+			}
+			order.Total += item.LineTotal
+		}
+		order.Status = models.OrderStatusPending
+
+		if err := tx.InsertOrder(ctx, &order); err != nil {
+			return fmt.Errorf("could not create order: %w", err)
+		}
+		for i, item := range order.Items {
+			for _, allocation := range allocations[i] {
+				allocation.OrderItemID = item.ID
+				if err := tx.InsertLotAllocation(ctx, allocation); err != nil {
+					return fmt.Errorf("could not record lot allocation: %w", err)
+				}
+			}
+			movement := models.StockMovement{
+				WarehouseID: item.WarehouseID,
+				ProductID:   item.ProductID,
+				Delta:       -item.Quantity,
+				Reason:      models.MovementReasonSale,
+				Reference:   order.ID,
+			}
+			if err := recordMovement(ctx, tx, movement); err != nil {
+				return err
+			}
+		}
+		return recordStatusChange(ctx, tx, order.ID, "", order.Status)
+	})
 	if err != nil {
 		return models.Order{}, err
 	}
+	return order, nil
+}
 
-	order.Total = float64(order.Quantity) * product.Price
-
-	err = op.inventory.UpdateStock(ctx, order.ProductID, -order.Quantity)
+// reserveStock decrements the stock for a single order line at its
+// warehouse, fills in its unit price and line total, and returns the lots
+// it took stock from. The change and the lots are recorded once the order
+// and its line have IDs to refer to.
+func reserveStock(ctx context.Context, tx StoreTx, item *models.OrderItem) ([]models.LotAllocation, error) {
+	product, allocations, err := changeStock(ctx, tx, item.WarehouseID, item.ProductID, -item.Quantity)
 	if err != nil {
-		return models.Order{}, err
+		return nil, err
 	}
+	item.UnitPrice = product.Price
+	item.LineTotal = float64(item.Quantity) * item.UnitPrice
+	return allocations, nil
+}
 
-	query := `INSERT INTO orders (product_id, quantity, total, status) VALUES ($1, $2, $3, $4) RETURNING id`
-	err = op.db.QueryRowContext(ctx, query, order.ProductID, order.Quantity, order.Total, "Pending").Scan(&order.ID)
+// GetOrder returns an order with its items.
+func (op *OrderProcessingImpl) GetOrder(ctx context.Context, orderID string) (models.Order, error) {
+	var order models.Order
+	err := op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		order, err = tx.GetOrder(ctx, orderID)
+		return err
+	})
+	if errors.Is(err, errNotFound) {
+		return models.Order{}, fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
 	if err != nil {
-		return models.Order{}, fmt.Errorf("could not create order: %w", err)
+		return models.Order{}, fmt.Errorf("could not fetch order: %w", err)
 	}
-
 	return order, nil
 }
 
-func (op *OrderProcessingImpl) GetOrders(ctx context.Context) ([]models.Order, error) {
-	query := `SELECT id, product_id, quantity, total, status FROM orders`
-	rows, err := op.db.QueryContext(ctx, query)
+// GetOrders retrieves one page of the orders matching the query, with their
+// items.
+func (op *OrderProcessingImpl) GetOrders(ctx context.Context, q models.OrderQuery) (models.OrderPage, error) {
+	spec, err := orderPager.plan(q.Sort, q.Cursor, q.Limit)
 	if err != nil {
-		return nil, fmt.Errorf("could not fetch orders: %w", err)
+		return models.OrderPage{}, err
 	}
-	defer rows.Close()
 
 	var orders []models.Order
-	for rows.Next() {
-		var order models.Order
-		if err := rows.Scan(&order.ID, &order.ProductID, &order.Quantity, &order.Total, &order.Status); err != nil {
-			return nil, fmt.Errorf("could not scan order: %w", err)
-		}
-		orders = append(orders, order)
+	err = op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		orders, err = tx.ListOrders(ctx, q.OrderFilter, spec)
+		return err
+	})
+	if err != nil {
+		return models.OrderPage{}, fmt.Errorf("could not fetch orders: %w", err)
 	}
-	return orders, nil
+
+	var page models.OrderPage
+	page.Orders, page.NextCursor, page.PrevCursor = orderPager.page(orders, spec)
+	return page, nil
 }
 
+// UpdateOrderStatus moves an order to a new status if the order lifecycle
+// allows it, and records the change in the order's timeline. Illegal
+// transitions return an *InvalidTransitionError. Cancelling an order goes
+// through CancelOrder so that its stock is restored.
 func (op *OrderProcessingImpl) UpdateOrderStatus(ctx context.Context, orderID string, status string) error {
-	query := `UPDATE orders SET status = $1 WHERE id = $2`
-	_, err := op.db.ExecContext(ctx, query, status, orderID)
-	return err
+	if status == models.OrderStatusCancelled {
+		return op.CancelOrder(ctx, orderID)
+	}
+
+	return op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		order, err := lockOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if err := checkTransition(order.Status, status); err != nil {
+			return err
+		}
+
+		if err := tx.SetOrderStatus(ctx, orderID, status); err != nil {
+			return fmt.Errorf("could not update order status: %w", err)
+		}
+		return recordStatusChange(ctx, tx, orderID, order.Status, status)
+	})
+}
+
+// CancelOrder marks an order Cancelled and returns the stock of every line to
+// the warehouse it came from in a single transaction. Stock taken from lots
+// goes back to them; what goes back to a lot that has since been quarantined
+// is taken straight out of stock again, recorded as a quarantine movement,
+// so that it cannot be sold. Cancelling an order that is already Cancelled
+// is a no-op, so stock is only ever restored once.
+func (op *OrderProcessingImpl) CancelOrder(ctx context.Context, orderID string) error {
+	return op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		order, err := lockOrder(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if order.Status == models.OrderStatusCancelled {
+			return nil
+		}
+		if err := checkTransition(order.Status, models.OrderStatusCancelled); err != nil {
+			return err
+		}
+
+		quarantined, err := restoreLots(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		for _, i := range byProduct(order.Items) {
+			item := order.Items[i]
+			movement := models.StockMovement{
+				WarehouseID: item.WarehouseID,
+				ProductID:   item.ProductID,
+				Delta:       item.Quantity,
+				Reason:      models.MovementReasonCancellation,
+				Reference:   orderID,
+			}
+			if _, err := adjustStock(ctx, tx, movement); err != nil {
+				return fmt.Errorf("could not restock order items: %w", err)
+			}
+		}
+		for _, allocation := range quarantined {
+			if err := quarantineStock(ctx, tx, allocation.WarehouseID, allocation.ProductID, allocation.LotID, allocation.Quantity); err != nil {
+				return fmt.Errorf("could not quarantine returned stock: %w", err)
+			}
+		}
+
+		if err := tx.SetOrderStatus(ctx, orderID, models.OrderStatusCancelled); err != nil {
+			return fmt.Errorf("could not update order status: %w", err)
+		}
+		return recordStatusChange(ctx, tx, orderID, order.Status, models.OrderStatusCancelled)
+	})
+}
+
+// restoreLots returns the stock an order took from lots to the lots, and
+// returns the allocations of the lots that have been quarantined since,
+// whose stock its caller must take out again once it is restocked.
+func restoreLots(ctx context.Context, tx StoreTx, orderID string) ([]models.LotAllocation, error) {
+	allocations, err := tx.ListLotAllocations(ctx, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch lot allocations: %w", err)
+	}
+	var quarantined []models.LotAllocation
+	for _, allocation := range allocations {
+		lot, err := tx.LockLot(ctx, allocation.LotID)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch lot: %w", err)
+		}
+		if err := tx.AddLotQuantity(ctx, lot.ID, allocation.Quantity); err != nil {
+			return nil, fmt.Errorf("could not update lot: %w", err)
+		}
+		if lot.Status == models.LotStatusQuarantined {
+			quarantined = append(quarantined, allocation)
+		}
+	}
+	return quarantined, nil
+}
+
+// GetOrderLots returns the lots an order's lines took stock from, by line.
+func (op *OrderProcessingImpl) GetOrderLots(ctx context.Context, orderID string) ([]models.LotAllocation, error) {
+	allocations := []models.LotAllocation{}
+	err := op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if _, err := tx.GetOrder(ctx, orderID); err != nil {
+			if errors.Is(err, errNotFound) {
+				return fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+			}
+			return fmt.Errorf("could not fetch order: %w", err)
+		}
+		found, err := tx.ListLotAllocations(ctx, orderID)
+		if err != nil {
+			return fmt.Errorf("could not fetch lot allocations: %w", err)
+		}
+		allocations = append(allocations, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// GetOrderTimeline returns every status change of an order, oldest first.
+func (op *OrderProcessingImpl) GetOrderTimeline(ctx context.Context, orderID string) ([]models.OrderStatusChange, error) {
+	var timeline []models.OrderStatusChange
+	err := op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if _, err := tx.GetOrder(ctx, orderID); err != nil {
+			if errors.Is(err, errNotFound) {
+				return fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+			}
+			return fmt.Errorf("could not fetch order: %w", err)
+		}
+		var err error
+		timeline, err = tx.ListStatusChanges(ctx, orderID)
+		if err != nil {
+			return fmt.Errorf("could not fetch order timeline: %w", err)
+		}
+		return nil
+	})
+	return timeline, err
+}
+
+// CountOrdersByStatus returns the number of orders in each status.
+func (op *OrderProcessingImpl) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	var counts map[string]int
+	err := op.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		counts, err = tx.CountOrdersByStatus(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not count orders: %w", err)
+	}
+	return counts, nil
+}
+
+// byProduct returns the indexes of items ordered by product ID. Stock is
+// always changed in this order so that concurrent orders lock products in the
+// same sequence and cannot deadlock each other.
+func byProduct(items []models.OrderItem) []int {
+	lines := make([]int, len(items))
+	for i := range lines {
+		lines[i] = i
+	}
+	sort.SliceStable(lines, func(a, b int) bool {
+		return items[lines[a]].ProductID < items[lines[b]].ProductID
+	})
+	return lines
+}
+
+// lockOrder locks an order for the rest of the transaction.
+func lockOrder(ctx context.Context, tx StoreTx, orderID string) (models.Order, error) {
+	order, err := tx.LockOrder(ctx, orderID)
+	if errors.Is(err, errNotFound) {
+		return models.Order{}, fmt.Errorf("%w: %s", ErrOrderNotFound, orderID)
+	}
+	if err != nil {
+		return models.Order{}, fmt.Errorf("could not fetch order: %w", err)
+	}
+	return order, nil
+}
+
+// recordStatusChange appends a status change, attributed to the actor in ctx,
+// to the order's timeline.
+func recordStatusChange(ctx context.Context, tx StoreTx, orderID, from, to string) error {
+	change := models.OrderStatusChange{OrderID: orderID, From: from, To: to, Actor: ActorFromContext(ctx)}
+	if err := tx.AppendStatusChange(ctx, change); err != nil {
+		return fmt.Errorf("could not record order status change: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,161 @@
+package components
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestCreateOrderConcurrentNoOversell(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+
+		const stock, attempts = 25, 100
+		productID := h.productID()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "concurrency", Stock: stock, Price: 2.5}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+
+		var (
+			wg        sync.WaitGroup
+			mu        sync.Mutex
+			succeeded int
+		)
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 1}}}); err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if succeeded != stock {
+			t.Errorf("succeeded orders = %d, want %d", succeeded, stock)
+		}
+		remaining, err := inventory.CheckStock(ctx, productID)
+		if err != nil {
+			t.Fatalf("CheckStock: %v", err)
+		}
+		if remaining != 0 {
+			t.Errorf("remaining stock = %d, want 0", remaining)
+		}
+	})
+}
+
+func TestCreateOrderMultiLineIsAllOrNothing(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+
+		first, second := h.productID(), h.productID()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		for _, p := range []models.Product{
+			{ID: first, Name: "first", Stock: 10, Price: 1.25},
+			{ID: second, Name: "second", Stock: 1, Price: 4},
+		} {
+			if err := inventory.AddProduct(ctx, p); err != nil {
+				t.Fatalf("AddProduct: %v", err)
+			}
+		}
+
+		_, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{
+			{ProductID: first, Quantity: 3},
+			{ProductID: second, Quantity: 2},
+		}})
+		if err == nil {
+			t.Fatal("CreateOrder succeeded with an unfulfillable line")
+		}
+		if stock, _ := inventory.CheckStock(ctx, first); stock != 10 {
+			t.Errorf("stock of first product = %d, want 10 after rollback", stock)
+		}
+
+		order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{
+			{ProductID: first, Quantity: 3},
+			{ProductID: second, Quantity: 1},
+		}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if len(order.Items) != 2 || order.Total != 3*1.25+4 {
+			t.Errorf("order = %+v, want two lines totalling %v", order, 3*1.25+4)
+		}
+	})
+}
+
+func TestCancelOrderRestocksOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+
+		productID := h.productID()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "cancel", Stock: 5, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+
+		order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 3}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if err := orders.CancelOrder(ctx, order.ID); err != nil {
+				t.Fatalf("CancelOrder #%d: %v", i+1, err)
+			}
+		}
+		if stock, _ := inventory.CheckStock(ctx, productID); stock != 5 {
+			t.Errorf("stock after cancelling = %d, want 5", stock)
+		}
+		timeline, err := orders.GetOrderTimeline(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("GetOrderTimeline: %v", err)
+		}
+		if len(timeline) != 2 || timeline[1].To != models.OrderStatusCancelled {
+			t.Errorf("timeline = %+v, want Pending then Cancelled", timeline)
+		}
+	})
+}
+
+func TestUpdateOrderStatusRejectsIllegalTransitions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := WithActor(context.Background(), "picker")
+
+		productID := h.productID()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "status", Stock: 5, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 1}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+
+		if err := orders.UpdateOrderStatus(ctx, order.ID, models.OrderStatusShipped); err == nil {
+			t.Error("UpdateOrderStatus(Pending -> Shipped) succeeded")
+		}
+		if err := orders.UpdateOrderStatus(ctx, order.ID, models.OrderStatusConfirmed); err != nil {
+			t.Fatalf("UpdateOrderStatus(Pending -> Confirmed): %v", err)
+		}
+
+		timeline, err := orders.GetOrderTimeline(ctx, order.ID)
+		if err != nil {
+			t.Fatalf("GetOrderTimeline: %v", err)
+		}
+		if len(timeline) != 2 || timeline[1].Actor != "picker" {
+			t.Errorf("timeline = %+v, want two entries by picker", timeline)
+		}
+	})
+}
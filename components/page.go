@@ -0,0 +1,250 @@
+package components
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+const (
+	// DefaultPageSize is the page size of queries that do not set a limit.
+	DefaultPageSize = 50
+	// MaxPageSize is the largest page size a query may request.
+	MaxPageSize = 500
+)
+
+// pageKey is the position of a row in a sorted listing: the value of the
+// sort field, and the row ID to break ties.
+type pageKey struct {
+	value interface{}
+	id    interface{}
+}
+
+// pageSpec asks a store for one page of rows in keyset order.
+type pageSpec struct {
+	// field is the sort field and desc the order to read rows in. When
+	// paging backward desc is the reverse of the requested order.
+	field string
+	desc  bool
+	// after, if set, limits the page to rows that come after it.
+	after *pageKey
+	// limit is the number of rows to read, one more than the page size so
+	// that the row after the page tells whether there is another page.
+	limit int
+
+	// sort is the requested sort and backward is set when reading the page
+	// before a cursor; they are used to build the cursors of the result.
+	sort     string
+	backward bool
+}
+
+// cursor is the decoded form of a page cursor. It records the key of the
+// row next to the page it leads to and which side of it the page is on.
+type cursor struct {
+	Sort   string          `json:"s"`
+	Value  json.RawMessage `json:"v"`
+	ID     json.RawMessage `json:"i"`
+	Before bool            `json:"b,omitempty"`
+}
+
+// pager pages through rows of type T sorted by one of a set of fields, with
+// the row ID as a tie-breaker so that every row has a distinct key.
+type pager[T any] struct {
+	fields map[string]func(T) interface{}
+	id     func(T) interface{}
+}
+
+var productPager = pager[models.Product]{
+	fields: map[string]func(models.Product) interface{}{
+		"id":    func(p models.Product) interface{} { return p.ID },
+		"name":  func(p models.Product) interface{} { return p.Name },
+		"stock": func(p models.Product) interface{} { return p.Stock },
+		"price": func(p models.Product) interface{} { return p.Price },
+	},
+	id: func(p models.Product) interface{} { return p.ID },
+}
+
+var orderPager = pager[models.Order]{
+	fields: map[string]func(models.Order) interface{}{
+		"id":         func(o models.Order) interface{} { return orderSeq(o.ID) },
+		"created_at": func(o models.Order) interface{} { return o.CreatedAt.UTC() },
+		"total":      func(o models.Order) interface{} { return o.Total },
+	},
+	id: func(o models.Order) interface{} { return orderSeq(o.ID) },
+}
+
+var movementPager = pager[models.StockMovement]{
+	fields: map[string]func(models.StockMovement) interface{}{
+		"id":         func(m models.StockMovement) interface{} { return orderSeq(m.ID) },
+		"created_at": func(m models.StockMovement) interface{} { return m.CreatedAt.UTC() },
+	},
+	id: func(m models.StockMovement) interface{} { return orderSeq(m.ID) },
+}
+
+// orderSeq returns the numeric value of a generated ID, such as an order or
+// movement ID, so that the IDs sort numerically.
+func orderSeq(id string) int64 {
+	n, _ := strconv.ParseInt(id, 10, 64)
+	return n
+}
+
+// plan validates the sort, cursor and limit of a query and returns the page
+// to read from the store.
+func (p pager[T]) plan(sortBy, cursorText string, limit int) (pageSpec, error) {
+	field, desc := strings.CutPrefix(sortBy, "-")
+	if field == "" {
+		field = "id"
+	}
+	if _, ok := p.fields[field]; !ok {
+		return pageSpec{}, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, sortBy)
+	}
+	switch {
+	case limit == 0:
+		limit = DefaultPageSize
+	case limit < 0 || limit > MaxPageSize:
+		return pageSpec{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxPageSize)
+	}
+
+	spec := pageSpec{field: field, desc: desc, limit: limit + 1, sort: field}
+	if desc {
+		spec.sort = "-" + field
+	}
+	if cursorText == "" {
+		return spec, nil
+	}
+
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(cursorText)
+	if err == nil {
+		err = json.Unmarshal(raw, &c)
+	}
+	if err != nil {
+		return pageSpec{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.Sort != spec.sort {
+		return pageSpec{}, fmt.Errorf("%w: cursor is for sort %q, not %q", ErrInvalidQuery, c.Sort, spec.sort)
+	}
+	var zero T
+	value, err := decodeLike(c.Value, p.fields[field](zero))
+	if err != nil {
+		return pageSpec{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	id, err := decodeLike(c.ID, p.id(zero))
+	if err != nil {
+		return pageSpec{}, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	spec.after = &pageKey{value: value, id: id}
+	if c.Before {
+		spec.desc = !spec.desc
+		spec.backward = true
+	}
+	return spec, nil
+}
+
+// decodeLike decodes raw into a value of the same type as sample.
+func decodeLike(raw json.RawMessage, sample interface{}) (interface{}, error) {
+	v := reflect.New(reflect.TypeOf(sample))
+	if err := json.Unmarshal(raw, v.Interface()); err != nil {
+		return nil, err
+	}
+	return v.Elem().Interface(), nil
+}
+
+// page turns the rows a store read for spec into a page in the requested
+// order and returns the cursors of the pages before and after it.
+func (p pager[T]) page(rows []T, spec pageSpec) (page []T, next, prev string) {
+	more := len(rows) == spec.limit
+	if more {
+		rows = rows[:len(rows)-1]
+	}
+	if spec.backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+	if len(rows) == 0 {
+		return []T{}, "", ""
+	}
+
+	first, last := rows[0], rows[len(rows)-1]
+	switch {
+	case spec.backward:
+		// The page was read backward from a cursor, so a page follows it.
+		next = p.cursor(last, spec.sort, false)
+		if more {
+			prev = p.cursor(first, spec.sort, true)
+		}
+	default:
+		if more {
+			next = p.cursor(last, spec.sort, false)
+		}
+		if spec.after != nil {
+			prev = p.cursor(first, spec.sort, true)
+		}
+	}
+	return rows, next, prev
+}
+
+// cursor returns the cursor of the page after row or, if before is set, of
+// the page before it.
+func (p pager[T]) cursor(row T, sortBy string, before bool) string {
+	value, _ := json.Marshal(p.fields[strings.TrimPrefix(sortBy, "-")](row))
+	id, _ := json.Marshal(p.id(row))
+	raw, _ := json.Marshal(cursor{Sort: sortBy, Value: value, ID: id, Before: before})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// pageOf sorts rows for spec and returns the rows of the page. It is the
+// in-memory equivalent of the keyset query SQLStore runs.
+func pageOf[T any](rows []T, p pager[T], spec pageSpec) []T {
+	key := func(row T) pageKey { return pageKey{value: p.fields[spec.field](row), id: p.id(row)} }
+	less := func(a, b pageKey) bool {
+		if spec.desc {
+			return compareKeys(a, b) > 0
+		}
+		return compareKeys(a, b) < 0
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return less(key(rows[i]), key(rows[j])) })
+	if spec.after != nil {
+		start := sort.Search(len(rows), func(i int) bool { return less(*spec.after, key(rows[i])) })
+		rows = rows[start:]
+	}
+	if len(rows) > spec.limit {
+		rows = rows[:spec.limit]
+	}
+	return rows
+}
+
+// compareKeys orders page keys by value and then by ID.
+func compareKeys(a, b pageKey) int {
+	if c := compareValues(a.value, b.value); c != 0 {
+		return c
+	}
+	return compareValues(a.id, b.id)
+}
+
+// compareValues orders two sort field values of the same type.
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		return strings.Compare(a, b.(string))
+	case int:
+		return cmp.Compare(a, b.(int))
+	case int64:
+		return cmp.Compare(a, b.(int64))
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	panic(fmt.Sprintf("cannot compare %T", a))
+}
@@ -0,0 +1,179 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestGetProductsPages(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+
+		// Names share a token so the test only sees its own products.
+		token := fmt.Sprintf("paging%d", rand.Int31())
+		prices := []float64{5, 3, 5, 1, 8, 3, 5}
+		var want []models.Product
+		for i, price := range prices {
+			product := models.Product{ID: h.productID(), Name: fmt.Sprintf("%s item %d", token, i), Stock: i, Price: price}
+			if err := inventory.AddProduct(ctx, product); err != nil {
+				t.Fatalf("AddProduct: %v", err)
+			}
+			want = append(want, product)
+		}
+		sort.Slice(want, func(i, j int) bool {
+			if want[i].Price != want[j].Price {
+				return want[i].Price > want[j].Price
+			}
+			return want[i].ID > want[j].ID
+		})
+
+		// Walk forward through every page, then back again.
+		q := models.ProductQuery{ProductFilter: models.ProductFilter{NameContains: token}, Sort: "-price", Limit: 3}
+		var pages []models.ProductPage
+		for {
+			page, err := inventory.GetProducts(ctx, q)
+			if err != nil {
+				t.Fatalf("GetProducts: %v", err)
+			}
+			pages = append(pages, page)
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		var got []models.Product
+		for _, page := range pages {
+			got = append(got, page.Products...)
+		}
+		if !equalIDs(got, want) {
+			t.Fatalf("forward pages = %v, want %v", got, want)
+		}
+		if len(pages) != 3 || pages[0].PrevCursor != "" {
+			t.Fatalf("got %d pages, first prev cursor %q; want 3 pages and none", len(pages), pages[0].PrevCursor)
+		}
+
+		for i := len(pages) - 1; i > 0; i-- {
+			q.Cursor = pages[i].PrevCursor
+			page, err := inventory.GetProducts(ctx, q)
+			if err != nil {
+				t.Fatalf("GetProducts: %v", err)
+			}
+			if !equalIDs(page.Products, pages[i-1].Products) {
+				t.Errorf("page before %d = %v, want %v", i, page.Products, pages[i-1].Products)
+			}
+		}
+
+		stockBelow, minPrice, maxPrice := 5, 3.0, 5.0
+		page, err := inventory.GetProducts(ctx, models.ProductQuery{ProductFilter: models.ProductFilter{
+			NameContains: token, StockBelow: &stockBelow, MinPrice: &minPrice, MaxPrice: &maxPrice,
+		}})
+		if err != nil {
+			t.Fatalf("GetProducts: %v", err)
+		}
+		for _, p := range page.Products {
+			if p.Stock >= 5 || p.Price < 3 || p.Price > 5 {
+				t.Errorf("filtered page has %+v", p)
+			}
+		}
+		if len(page.Products) != 3 {
+			t.Errorf("filtered page has %d products, want 3", len(page.Products))
+		}
+
+		for _, q := range []models.ProductQuery{{Sort: "colour"}, {Cursor: "nonsense"}, {Limit: MaxPageSize + 1}, {Sort: "price", Cursor: pages[0].NextCursor}} {
+			if _, err := inventory.GetProducts(ctx, q); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("GetProducts(%+v) error = %v, want ErrInvalidQuery", q, err)
+			}
+		}
+	})
+}
+
+func TestGetOrdersPages(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "paging", Stock: 100, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		var created []models.Order
+		for _, quantity := range []int{3, 1, 2, 1, 5} {
+			order, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: quantity}}})
+			if err != nil {
+				t.Fatalf("CreateOrder: %v", err)
+			}
+			created = append(created, order)
+		}
+		if err := orders.CancelOrder(ctx, created[1].ID); err != nil {
+			t.Fatalf("CancelOrder: %v", err)
+		}
+
+		q := models.OrderQuery{OrderFilter: models.OrderFilter{ProductID: productID}, Sort: "total", Limit: 2}
+		var totals []float64
+		for {
+			page, err := orders.GetOrders(ctx, q)
+			if err != nil {
+				t.Fatalf("GetOrders: %v", err)
+			}
+			for _, order := range page.Orders {
+				if len(order.Items) != 1 {
+					t.Errorf("order %s has %d items, want 1", order.ID, len(order.Items))
+				}
+				totals = append(totals, order.Total)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+		if fmt.Sprint(totals) != "[1 1 2 3 5]" {
+			t.Errorf("totals = %v, want [1 1 2 3 5]", totals)
+		}
+
+		page, err := orders.GetOrders(ctx, models.OrderQuery{OrderFilter: models.OrderFilter{ProductID: productID, Status: models.OrderStatusCancelled}})
+		if err != nil {
+			t.Fatalf("GetOrders: %v", err)
+		}
+		if len(page.Orders) != 1 || page.Orders[0].ID != created[1].ID {
+			t.Errorf("cancelled orders = %+v, want order %s", page.Orders, created[1].ID)
+		}
+
+		from := created[0].CreatedAt
+		page, err = orders.GetOrders(ctx, models.OrderQuery{OrderFilter: models.OrderFilter{ProductID: productID, From: from, To: from.Add(-1)}})
+		if err != nil {
+			t.Fatalf("GetOrders: %v", err)
+		}
+		if len(page.Orders) != 0 {
+			t.Errorf("empty date range matched %d orders", len(page.Orders))
+		}
+		page, err = orders.GetOrders(ctx, models.OrderQuery{OrderFilter: models.OrderFilter{ProductID: productID, From: from}, Sort: "-created_at"})
+		if err != nil {
+			t.Fatalf("GetOrders: %v", err)
+		}
+		if len(page.Orders) != len(created) {
+			t.Errorf("orders since the first = %d, want %d", len(page.Orders), len(created))
+		}
+	})
+}
+
+// equalIDs reports whether two product lists hold the same IDs in the same
+// order.
+func equalIDs(a, b []models.Product) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
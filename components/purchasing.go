@@ -0,0 +1,495 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+// PurchasingImpl is the implementation of Purchasing.
+type PurchasingImpl struct {
+	store Store
+}
+
+// NewPurchasing initializes a new PurchasingImpl instance.
+func NewPurchasing(store Store) *PurchasingImpl {
+	return &PurchasingImpl{store: store}
+}
+
+// AddSupplier adds a new supplier.
+func (p *PurchasingImpl) AddSupplier(ctx context.Context, supplier models.Supplier) error {
+	if supplier.ID == "" {
+		return fmt.Errorf("%w: id is required", ErrInvalidSupplier)
+	}
+	if err := validateSupplier(supplier); err != nil {
+		return err
+	}
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		return tx.InsertSupplier(ctx, supplier)
+	})
+	if errors.Is(err, errDuplicate) {
+		return fmt.Errorf("%w: %s", ErrDuplicateSupplier, supplier.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not add supplier: %w", err)
+	}
+	return nil
+}
+
+// validateSupplier checks the fields of a supplier other than its ID.
+func validateSupplier(supplier models.Supplier) error {
+	switch {
+	case supplier.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidSupplier)
+	case supplier.LeadTimeDays < 0:
+		return fmt.Errorf("%w: lead_time_days must not be negative", ErrInvalidSupplier)
+	}
+	return nil
+}
+
+// GetSupplier returns a supplier.
+func (p *PurchasingImpl) GetSupplier(ctx context.Context, supplierID string) (models.Supplier, error) {
+	var supplier models.Supplier
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		supplier, err = tx.GetSupplier(ctx, supplierID)
+		return err
+	})
+	if errors.Is(err, errNotFound) {
+		return models.Supplier{}, fmt.Errorf("%w: %s", ErrSupplierNotFound, supplierID)
+	}
+	if err != nil {
+		return models.Supplier{}, fmt.Errorf("could not fetch supplier: %w", err)
+	}
+	return supplier, nil
+}
+
+// GetSuppliers returns every supplier by ID.
+func (p *PurchasingImpl) GetSuppliers(ctx context.Context) ([]models.Supplier, error) {
+	suppliers := []models.Supplier{}
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		found, err := tx.ListSuppliers(ctx)
+		suppliers = append(suppliers, found...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch suppliers: %w", err)
+	}
+	return suppliers, nil
+}
+
+// UpdateSupplier replaces the fields of an existing supplier with those of
+// supplier, whose ID is ignored.
+func (p *PurchasingImpl) UpdateSupplier(ctx context.Context, supplierID string, supplier models.Supplier) (models.Supplier, error) {
+	if err := validateSupplier(supplier); err != nil {
+		return models.Supplier{}, err
+	}
+	supplier.ID = supplierID
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		return tx.UpdateSupplier(ctx, supplier)
+	})
+	if errors.Is(err, errNotFound) {
+		return models.Supplier{}, fmt.Errorf("%w: %s", ErrSupplierNotFound, supplierID)
+	}
+	if err != nil {
+		return models.Supplier{}, fmt.Errorf("could not update supplier: %w", err)
+	}
+	return supplier, nil
+}
+
+// DeleteSupplier removes a supplier that no purchase order refers to.
+func (p *PurchasingImpl) DeleteSupplier(ctx context.Context, supplierID string) error {
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		return tx.DeleteSupplier(ctx, supplierID)
+	})
+	if errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s", ErrSupplierNotFound, supplierID)
+	}
+	if errors.Is(err, errInUse) {
+		return fmt.Errorf("%w: %s", ErrSupplierInUse, supplierID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not delete supplier: %w", err)
+	}
+	return nil
+}
+
+// CreatePurchaseOrder records a Draft purchase order and returns it with its
+// ID. Orders that do not name a warehouse are delivered to the default one.
+// No stock changes until lines are received.
+func (p *PurchasingImpl) CreatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) (models.PurchaseOrder, error) {
+	po = draftPurchaseOrder(po)
+	if err := validatePurchaseOrder(po); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if err := checkPurchaseOrderRefs(ctx, tx, po); err != nil {
+			return err
+		}
+		if err := tx.InsertPurchaseOrder(ctx, &po); err != nil {
+			return fmt.Errorf("could not create purchase order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return po, nil
+}
+
+// draftPurchaseOrder returns po as a Draft delivered to its warehouse, or
+// the default one, with nothing received yet and its lines in product order,
+// the order stores return them in.
+func draftPurchaseOrder(po models.PurchaseOrder) models.PurchaseOrder {
+	if po.WarehouseID == "" {
+		po.WarehouseID = DefaultWarehouseID
+	}
+	po.Status = models.PurchaseOrderStatusDraft
+	po.SentAt, po.ClosedAt = nil, nil
+	po.Lines = append([]models.PurchaseOrderLine(nil), po.Lines...)
+	for i := range po.Lines {
+		po.Lines[i].Received = 0
+	}
+	sort.Slice(po.Lines, func(i, j int) bool { return po.Lines[i].ProductID < po.Lines[j].ProductID })
+	return po
+}
+
+// validatePurchaseOrder checks the fields of a new or edited purchase order.
+func validatePurchaseOrder(po models.PurchaseOrder) error {
+	switch {
+	case po.SupplierID == "":
+		return fmt.Errorf("%w: supplier_id is required", ErrInvalidPurchaseOrder)
+	case len(po.Lines) == 0:
+		return fmt.Errorf("%w: no lines", ErrInvalidPurchaseOrder)
+	}
+	seen := make(map[string]bool, len(po.Lines))
+	for _, line := range po.Lines {
+		switch {
+		case line.ProductID == "":
+			return fmt.Errorf("%w: product_id is required", ErrInvalidPurchaseOrder)
+		case line.Quantity <= 0:
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidPurchaseOrder)
+		case line.UnitCost < 0:
+			return fmt.Errorf("%w: unit_cost must not be negative", ErrInvalidPurchaseOrder)
+		case seen[line.ProductID]:
+			return fmt.Errorf("%w: product %s has more than one line", ErrInvalidPurchaseOrder, line.ProductID)
+		}
+		seen[line.ProductID] = true
+	}
+	return nil
+}
+
+// checkPurchaseOrderRefs checks that the supplier, warehouse and products of
+// a purchase order exist.
+func checkPurchaseOrderRefs(ctx context.Context, tx StoreTx, po models.PurchaseOrder) error {
+	if _, err := tx.GetSupplier(ctx, po.SupplierID); errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s", ErrSupplierNotFound, po.SupplierID)
+	} else if err != nil {
+		return fmt.Errorf("could not fetch supplier: %w", err)
+	}
+	if _, err := tx.GetWarehouse(ctx, po.WarehouseID); errors.Is(err, errNotFound) {
+		return fmt.Errorf("%w: %s", ErrWarehouseNotFound, po.WarehouseID)
+	} else if err != nil {
+		return fmt.Errorf("could not fetch warehouse: %w", err)
+	}
+	for _, line := range po.Lines {
+		if _, err := tx.GetProduct(ctx, line.ProductID); errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrProductNotFound, line.ProductID)
+		} else if err != nil {
+			return fmt.Errorf("could not fetch product: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetPurchaseOrder returns a purchase order with its lines.
+func (p *PurchasingImpl) GetPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		po, err = tx.GetPurchaseOrder(ctx, poID)
+		return err
+	})
+	if errors.Is(err, errNotFound) {
+		return models.PurchaseOrder{}, fmt.Errorf("%w: %s", ErrPurchaseOrderNotFound, poID)
+	}
+	if err != nil {
+		return models.PurchaseOrder{}, fmt.Errorf("could not fetch purchase order: %w", err)
+	}
+	return po, nil
+}
+
+// GetPurchaseOrders returns the purchase orders matching filter, newest
+// first.
+func (p *PurchasingImpl) GetPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	pos := []models.PurchaseOrder{}
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		found, err := tx.ListPurchaseOrders(ctx, filter)
+		pos = append(pos, found...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch purchase orders: %w", err)
+	}
+	return pos, nil
+}
+
+// UpdatePurchaseOrder replaces the supplier, warehouse, expected date and
+// lines of a Draft purchase order with those of po.
+func (p *PurchasingImpl) UpdatePurchaseOrder(ctx context.Context, poID string, po models.PurchaseOrder) (models.PurchaseOrder, error) {
+	po = draftPurchaseOrder(po)
+	po.ID = poID
+	if err := validatePurchaseOrder(po); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		current, err := lockPurchaseOrder(ctx, tx, poID)
+		if err != nil {
+			return err
+		}
+		if current.Status != models.PurchaseOrderStatusDraft {
+			return fmt.Errorf("%w: purchase order %s is %s, not Draft", ErrInvalidPurchaseOrder, poID, current.Status)
+		}
+		if err := checkPurchaseOrderRefs(ctx, tx, po); err != nil {
+			return err
+		}
+		if err := tx.UpdatePurchaseOrder(ctx, po); err != nil {
+			return fmt.Errorf("could not update purchase order: %w", err)
+		}
+		po, err = tx.GetPurchaseOrder(ctx, poID)
+		if err != nil {
+			return fmt.Errorf("could not fetch purchase order: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return po, nil
+}
+
+// DeletePurchaseOrder removes a Draft purchase order. Orders that have been
+// sent are closed instead, so that their receipts stay on record.
+func (p *PurchasingImpl) DeletePurchaseOrder(ctx context.Context, poID string) error {
+	return p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		po, err := lockPurchaseOrder(ctx, tx, poID)
+		if err != nil {
+			return err
+		}
+		if po.Status != models.PurchaseOrderStatusDraft {
+			return fmt.Errorf("%w: purchase order %s is %s, not Draft", ErrInvalidPurchaseOrder, poID, po.Status)
+		}
+		if err := tx.DeletePurchaseOrder(ctx, poID); err != nil {
+			return fmt.Errorf("could not delete purchase order: %w", err)
+		}
+		return nil
+	})
+}
+
+// SendPurchaseOrder marks a Draft purchase order Sent, after which it can
+// no longer be edited and its lines can be received.
+func (p *PurchasingImpl) SendPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error) {
+	return p.setPurchaseOrderStatus(ctx, poID, models.PurchaseOrderStatusSent, models.PurchaseOrderStatusDraft)
+}
+
+// ClosePurchaseOrder ends a purchase order that is not already Closed. A
+// Draft is closed without being sent, and a PartiallyReceived order is
+// closed short, accepting that its outstanding quantities will not arrive.
+func (p *PurchasingImpl) ClosePurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error) {
+	return p.setPurchaseOrderStatus(ctx, poID, models.PurchaseOrderStatusClosed,
+		models.PurchaseOrderStatusDraft, models.PurchaseOrderStatusSent,
+		models.PurchaseOrderStatusPartiallyReceived, models.PurchaseOrderStatusReceived)
+}
+
+// setPurchaseOrderStatus moves a purchase order in one of the from statuses
+// to status.
+func (p *PurchasingImpl) setPurchaseOrderStatus(ctx context.Context, poID, status string, from ...string) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		po, err = lockPurchaseOrder(ctx, tx, poID)
+		if err != nil {
+			return err
+		}
+		if !containsStatus(from, po.Status) {
+			return fmt.Errorf("%w: purchase order %s is %s and cannot become %s", ErrInvalidPurchaseOrder, poID, po.Status, status)
+		}
+		return setPurchaseOrderStatus(ctx, tx, &po, status)
+	})
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return po, nil
+}
+
+// ReceivePurchaseOrder records the receipt of lines against a Sent or
+// PartiallyReceived purchase order. Each line adds its quantity to the
+// order's warehouse, recording the movement in the stock ledger, and to the
+// quantity received on the order's line for that product. The order becomes
+// Received once every line has arrived in full and PartiallyReceived until
+// then; close it to accept a short delivery. Receiving more than is
+// outstanding on a line is accepted, since the goods have arrived, and
+// flagged by the line's OverReceived. A line with a lot number and expiry
+// date receives its quantity into the lot of that number at the warehouse,
+// creating it if there is none; what goes to a Quarantined lot is taken
+// straight out of stock again. A receipt for a product the order does not
+// name, of a lot that has already expired or of a lot whose expiry differs
+// from the one at the warehouse is rejected with ErrInvalidReceipt and
+// receives nothing.
+func (p *PurchasingImpl) ReceivePurchaseOrder(ctx context.Context, poID string, lines []models.ReceiptLine) (models.PurchaseOrder, error) {
+	if len(lines) == 0 {
+		return models.PurchaseOrder{}, fmt.Errorf("%w: no lines", ErrInvalidReceipt)
+	}
+	seen := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		switch {
+		case line.Quantity <= 0:
+			return models.PurchaseOrder{}, fmt.Errorf("%w: quantity must be positive", ErrInvalidReceipt)
+		case seen[line.ProductID]:
+			return models.PurchaseOrder{}, fmt.Errorf("%w: product %s has more than one line", ErrInvalidReceipt, line.ProductID)
+		case (line.LotNumber == "") != (line.ExpiresAt == nil):
+			return models.PurchaseOrder{}, fmt.Errorf("%w: a lot needs both lot_number and expires_at", ErrInvalidReceipt)
+		case line.ExpiresAt != nil && !line.ExpiresAt.After(time.Now()):
+			return models.PurchaseOrder{}, fmt.Errorf("%w: lot %s has already expired", ErrInvalidReceipt, line.LotNumber)
+		}
+		seen[line.ProductID] = true
+	}
+	lines = append([]models.ReceiptLine(nil), lines...)
+	sort.Slice(lines, func(i, j int) bool { return lines[i].ProductID < lines[j].ProductID })
+
+	var po models.PurchaseOrder
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		po, err = lockPurchaseOrder(ctx, tx, poID)
+		if err != nil {
+			return err
+		}
+		if po.Status != models.PurchaseOrderStatusSent && po.Status != models.PurchaseOrderStatusPartiallyReceived {
+			return fmt.Errorf("%w: purchase order %s is %s and cannot be received", ErrInvalidPurchaseOrder, poID, po.Status)
+		}
+
+		ordered := make(map[string]*models.PurchaseOrderLine, len(po.Lines))
+		for i := range po.Lines {
+			ordered[po.Lines[i].ProductID] = &po.Lines[i]
+		}
+		for _, line := range lines {
+			poLine, ok := ordered[line.ProductID]
+			if !ok {
+				return fmt.Errorf("%w: purchase order %s has no line for product %s", ErrInvalidReceipt, poID, line.ProductID)
+			}
+
+			if err := tx.AddReceived(ctx, poID, line.ProductID, line.Quantity); err != nil {
+				return fmt.Errorf("could not record receipt: %w", err)
+			}
+			poLine.Received += line.Quantity
+			poLine.OverReceived = max(poLine.Received-poLine.Quantity, 0)
+			var lot models.Lot
+			if line.LotNumber != "" {
+				if lot, err = receiveLotLine(ctx, tx, po.WarehouseID, line); err != nil {
+					return err
+				}
+			}
+			movement := models.StockMovement{
+				WarehouseID: po.WarehouseID,
+				ProductID:   line.ProductID,
+				Delta:       line.Quantity,
+				Reason:      models.MovementReasonReceipt,
+				Reference:   poID,
+			}
+			if _, err := adjustStock(ctx, tx, movement); err != nil {
+				return err
+			}
+			if lot.Status == models.LotStatusQuarantined {
+				if err := quarantineStock(ctx, tx, po.WarehouseID, line.ProductID, lot.ID, line.Quantity); err != nil {
+					return fmt.Errorf("could not quarantine received stock: %w", err)
+				}
+			}
+		}
+
+		status := models.PurchaseOrderStatusReceived
+		for _, line := range po.Lines {
+			if line.Received < line.Quantity {
+				status = models.PurchaseOrderStatusPartiallyReceived
+			}
+		}
+		if status == po.Status {
+			return nil
+		}
+		return setPurchaseOrderStatus(ctx, tx, &po, status)
+	})
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return po, nil
+}
+
+// receiveLotLine adds a receipt line to the lot of its number at the
+// warehouse, creating the lot if there is none, and returns the lot as it
+// was before. A lot that already exists must have the line's expiry date.
+func receiveLotLine(ctx context.Context, tx StoreTx, warehouseID string, line models.ReceiptLine) (models.Lot, error) {
+	lot, found, err := lockLotByNumber(ctx, tx, line.ProductID, warehouseID, line.LotNumber)
+	if err != nil {
+		return models.Lot{}, err
+	}
+	if !found {
+		lot = models.Lot{
+			ProductID:   line.ProductID,
+			WarehouseID: warehouseID,
+			LotNumber:   line.LotNumber,
+			Quantity:    line.Quantity,
+			ExpiresAt:   *line.ExpiresAt,
+		}
+		if err := createLot(ctx, tx, &lot); err != nil {
+			return models.Lot{}, err
+		}
+		return lot, nil
+	}
+	if expiresAt := line.ExpiresAt.UTC().Truncate(time.Microsecond); !lot.ExpiresAt.Equal(expiresAt) {
+		return models.Lot{}, fmt.Errorf("%w: lot %s of product %s expires at %s, not %s", ErrInvalidReceipt,
+			line.LotNumber, line.ProductID, lot.ExpiresAt.UTC().Format(time.RFC3339), expiresAt.Format(time.RFC3339))
+	}
+	if err := tx.AddLotQuantity(ctx, lot.ID, line.Quantity); err != nil {
+		return models.Lot{}, fmt.Errorf("could not update lot: %w", err)
+	}
+	return lot, nil
+}
+
+// lockPurchaseOrder locks a purchase order for the rest of the transaction.
+func lockPurchaseOrder(ctx context.Context, tx StoreTx, poID string) (models.PurchaseOrder, error) {
+	po, err := tx.LockPurchaseOrder(ctx, poID)
+	if errors.Is(err, errNotFound) {
+		return models.PurchaseOrder{}, fmt.Errorf("%w: %s", ErrPurchaseOrderNotFound, poID)
+	}
+	if err != nil {
+		return models.PurchaseOrder{}, fmt.Errorf("could not fetch purchase order: %w", err)
+	}
+	return po, nil
+}
+
+// setPurchaseOrderStatus changes the status of a purchase order and reloads
+// it, picking up the time the change was stamped with.
+func setPurchaseOrderStatus(ctx context.Context, tx StoreTx, po *models.PurchaseOrder, status string) error {
+	if err := tx.SetPurchaseOrderStatus(ctx, po.ID, status); err != nil {
+		return fmt.Errorf("could not update purchase order status: %w", err)
+	}
+	updated, err := tx.GetPurchaseOrder(ctx, po.ID)
+	if err != nil {
+		return fmt.Errorf("could not fetch purchase order: %w", err)
+	}
+	*po = updated
+	return nil
+}
+
+// containsStatus reports whether statuses holds status.
+func containsStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
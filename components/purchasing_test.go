@@ -0,0 +1,198 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestPurchaseOrders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		purchasing := NewPurchasing(h)
+
+		supplier := models.Supplier{ID: h.productID(), Name: "Acme", LeadTimeDays: 5}
+		if err := purchasing.AddSupplier(ctx, supplier); err != nil {
+			t.Fatalf("AddSupplier: %v", err)
+		}
+		if err := purchasing.AddSupplier(ctx, supplier); !errors.Is(err, ErrDuplicateSupplier) {
+			t.Errorf("AddSupplier(duplicate) error = %v, want ErrDuplicateSupplier", err)
+		}
+		if err := purchasing.AddSupplier(ctx, models.Supplier{ID: h.productID(), Name: "Late", LeadTimeDays: -1}); !errors.Is(err, ErrInvalidSupplier) {
+			t.Errorf("AddSupplier(negative lead time) error = %v, want ErrInvalidSupplier", err)
+		}
+
+		// Lines come back in product order.
+		a, b := h.productID(), h.productID()
+		if b < a {
+			a, b = b, a
+		}
+		for _, id := range []string{a, b} {
+			if err := inventory.AddProduct(ctx, models.Product{ID: id, Name: "bought", Stock: 1, Price: 3}); err != nil {
+				t.Fatalf("AddProduct: %v", err)
+			}
+		}
+
+		invalid := []models.PurchaseOrder{
+			{Lines: []models.PurchaseOrderLine{{ProductID: a, Quantity: 1}}},
+			{SupplierID: supplier.ID},
+			{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLine{{ProductID: a, Quantity: 0}}},
+			{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLine{{ProductID: a, Quantity: 1, UnitCost: -1}}},
+			{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLine{{ProductID: a, Quantity: 1}, {ProductID: a, Quantity: 2}}},
+		}
+		for _, po := range invalid {
+			if _, err := purchasing.CreatePurchaseOrder(ctx, po); !errors.Is(err, ErrInvalidPurchaseOrder) {
+				t.Errorf("CreatePurchaseOrder(%+v) error = %v, want ErrInvalidPurchaseOrder", po, err)
+			}
+		}
+		missing := models.PurchaseOrder{SupplierID: supplier.ID + "-missing", Lines: []models.PurchaseOrderLine{{ProductID: a, Quantity: 1}}}
+		if _, err := purchasing.CreatePurchaseOrder(ctx, missing); !errors.Is(err, ErrSupplierNotFound) {
+			t.Errorf("CreatePurchaseOrder(missing supplier) error = %v, want ErrSupplierNotFound", err)
+		}
+
+		po, err := purchasing.CreatePurchaseOrder(ctx, models.PurchaseOrder{
+			SupplierID: supplier.ID,
+			Lines:      []models.PurchaseOrderLine{{ProductID: a, Quantity: 4, UnitCost: 1.5, Received: 4}},
+		})
+		if err != nil || po.ID == "" || po.Status != models.PurchaseOrderStatusDraft || po.WarehouseID != DefaultWarehouseID || po.Lines[0].Received != 0 {
+			t.Fatalf("CreatePurchaseOrder = %+v, %v", po, err)
+		}
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: a, Quantity: 1}}); !errors.Is(err, ErrInvalidPurchaseOrder) {
+			t.Errorf("ReceivePurchaseOrder(draft) error = %v, want ErrInvalidPurchaseOrder", err)
+		}
+
+		// Drafts can still be edited.
+		po, err = purchasing.UpdatePurchaseOrder(ctx, po.ID, models.PurchaseOrder{
+			SupplierID: supplier.ID,
+			Lines:      []models.PurchaseOrderLine{{ProductID: b, Quantity: 2, UnitCost: 2}, {ProductID: a, Quantity: 5, UnitCost: 1.5}},
+		})
+		if err != nil || fmt.Sprint(po.Lines) != fmt.Sprintf("[{%s 5 0 0 1.5} {%s 2 0 0 2}]", a, b) {
+			t.Fatalf("UpdatePurchaseOrder = %+v, %v", po, err)
+		}
+
+		sent, err := purchasing.SendPurchaseOrder(ctx, po.ID)
+		if err != nil || sent.Status != models.PurchaseOrderStatusSent || sent.SentAt == nil {
+			t.Fatalf("SendPurchaseOrder = %+v, %v", sent, err)
+		}
+		if _, err := purchasing.UpdatePurchaseOrder(ctx, po.ID, models.PurchaseOrder{SupplierID: supplier.ID, Lines: po.Lines}); !errors.Is(err, ErrInvalidPurchaseOrder) {
+			t.Errorf("UpdatePurchaseOrder(sent) error = %v, want ErrInvalidPurchaseOrder", err)
+		}
+		if err := purchasing.DeletePurchaseOrder(ctx, po.ID); !errors.Is(err, ErrInvalidPurchaseOrder) {
+			t.Errorf("DeletePurchaseOrder(sent) error = %v, want ErrInvalidPurchaseOrder", err)
+		}
+		if err := purchasing.DeleteSupplier(ctx, supplier.ID); !errors.Is(err, ErrSupplierInUse) {
+			t.Errorf("DeleteSupplier(in use) error = %v, want ErrSupplierInUse", err)
+		}
+
+		// Bad receipts receive nothing.
+		badReceipts := [][]models.ReceiptLine{
+			{{ProductID: a, Quantity: 1}, {ProductID: supplier.ID, Quantity: 1}},
+			{{ProductID: a, Quantity: 1}, {ProductID: a, Quantity: 1}},
+			{{ProductID: a, Quantity: 0}},
+		}
+		for _, lines := range badReceipts {
+			if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, lines); !errors.Is(err, ErrInvalidReceipt) {
+				t.Errorf("ReceivePurchaseOrder(%+v) error = %v, want ErrInvalidReceipt", lines, err)
+			}
+		}
+
+		partial, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: a, Quantity: 3}, {ProductID: b, Quantity: 2}})
+		if err != nil || partial.Status != models.PurchaseOrderStatusPartiallyReceived ||
+			fmt.Sprint(partial.Lines) != fmt.Sprintf("[{%s 5 3 0 1.5} {%s 2 2 0 2}]", a, b) {
+			t.Fatalf("ReceivePurchaseOrder(partial) = %+v, %v", partial, err)
+		}
+		if stock, err := inventory.CheckStock(ctx, a); err != nil || stock != 4 {
+			t.Errorf("stock of a after partial receipt = %d, %v, want 4", stock, err)
+		}
+		// The supplier sends one more than is outstanding; it is received
+		// and flagged on the line.
+		received, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: a, Quantity: 3}})
+		if err != nil || received.Status != models.PurchaseOrderStatusReceived ||
+			fmt.Sprint(received.Lines) != fmt.Sprintf("[{%s 5 6 1 1.5} {%s 2 2 0 2}]", a, b) {
+			t.Fatalf("ReceivePurchaseOrder(over outstanding) = %+v, %v", received, err)
+		}
+		if got, err := purchasing.GetPurchaseOrder(ctx, po.ID); err != nil || fmt.Sprint(got.Lines) != fmt.Sprint(received.Lines) {
+			t.Errorf("GetPurchaseOrder = %+v, %v, want lines %v", got, err, received.Lines)
+		}
+		if stock, err := inventory.CheckStock(ctx, a); err != nil || stock != 7 {
+			t.Errorf("stock of a after receipt = %d, %v, want 7", stock, err)
+		}
+		receipts, err := inventory.GetStockMovements(ctx, models.MovementQuery{
+			MovementFilter: models.MovementFilter{ProductID: a, Reason: models.MovementReasonReceipt},
+		})
+		if err != nil || len(receipts.Movements) != 2 || receipts.Movements[0].Reference != po.ID || receipts.Movements[1].Delta != 3 {
+			t.Errorf("receipt movements = %+v, %v", receipts, err)
+		}
+
+		closed, err := purchasing.ClosePurchaseOrder(ctx, po.ID)
+		if err != nil || closed.Status != models.PurchaseOrderStatusClosed || closed.ClosedAt == nil {
+			t.Fatalf("ClosePurchaseOrder = %+v, %v", closed, err)
+		}
+		if _, err := purchasing.ClosePurchaseOrder(ctx, po.ID); !errors.Is(err, ErrInvalidPurchaseOrder) {
+			t.Errorf("ClosePurchaseOrder(again) error = %v, want ErrInvalidPurchaseOrder", err)
+		}
+
+		pos, err := purchasing.GetPurchaseOrders(ctx, models.PurchaseOrderFilter{SupplierID: supplier.ID, Status: models.PurchaseOrderStatusClosed})
+		if err != nil || len(pos) != 1 || pos[0].ID != po.ID || len(pos[0].Lines) != 2 {
+			t.Errorf("GetPurchaseOrders = %+v, %v", pos, err)
+		}
+		if err := inventory.DeleteProduct(ctx, b); !errors.Is(err, ErrProductInUse) {
+			t.Errorf("DeleteProduct(on purchase order) error = %v, want ErrProductInUse", err)
+		}
+	})
+}
+
+func TestPurchaseOrderShortDelivery(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		purchasing := NewPurchasing(h)
+
+		supplier := models.Supplier{ID: h.productID(), Name: "Short"}
+		if err := purchasing.AddSupplier(ctx, supplier); err != nil {
+			t.Fatalf("AddSupplier: %v", err)
+		}
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "short", Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+
+		draft, err := purchasing.CreatePurchaseOrder(ctx, models.PurchaseOrder{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLine{{ProductID: productID, Quantity: 10}}})
+		if err != nil {
+			t.Fatalf("CreatePurchaseOrder: %v", err)
+		}
+		if err := purchasing.DeletePurchaseOrder(ctx, draft.ID); err != nil {
+			t.Fatalf("DeletePurchaseOrder(draft): %v", err)
+		}
+		if _, err := purchasing.GetPurchaseOrder(ctx, draft.ID); !errors.Is(err, ErrPurchaseOrderNotFound) {
+			t.Errorf("GetPurchaseOrder(deleted) error = %v, want ErrPurchaseOrderNotFound", err)
+		}
+
+		po, err := purchasing.CreatePurchaseOrder(ctx, models.PurchaseOrder{SupplierID: supplier.ID, Lines: []models.PurchaseOrderLine{{ProductID: productID, Quantity: 10}}})
+		if err != nil {
+			t.Fatalf("CreatePurchaseOrder: %v", err)
+		}
+		if _, err := purchasing.SendPurchaseOrder(ctx, po.ID); err != nil {
+			t.Fatalf("SendPurchaseOrder: %v", err)
+		}
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: productID, Quantity: 7}}); err != nil {
+			t.Fatalf("ReceivePurchaseOrder: %v", err)
+		}
+
+		// Closing accepts the three that never came.
+		closed, err := purchasing.ClosePurchaseOrder(ctx, po.ID)
+		if err != nil || closed.Status != models.PurchaseOrderStatusClosed || closed.Lines[0].Received != 7 {
+			t.Fatalf("ClosePurchaseOrder = %+v, %v", closed, err)
+		}
+		if _, err := purchasing.ReceivePurchaseOrder(ctx, po.ID, []models.ReceiptLine{{ProductID: productID, Quantity: 3}}); !errors.Is(err, ErrInvalidPurchaseOrder) {
+			t.Errorf("ReceivePurchaseOrder(closed) error = %v, want ErrInvalidPurchaseOrder", err)
+		}
+		if stock, err := inventory.CheckStock(ctx, productID); err != nil || stock != 7 {
+			t.Errorf("stock = %d, %v, want 7", stock, err)
+		}
+	})
+}
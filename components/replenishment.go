@@ -0,0 +1,154 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+const (
+	// DefaultDemandWindowDays is the number of days of order history that
+	// demand is averaged over when a replenishment query does not say.
+	DefaultDemandWindowDays = 30
+	// MaxDemandWindowDays is the longest window a replenishment query may
+	// ask for.
+	MaxDemandWindowDays = 365
+)
+
+// SuggestReplenishment suggests purchases, by product ID, for the products
+// whose stock, in warehouses, in transit and on order, will not cover the
+// demand expected until a new purchase order arrives. See
+// models.ReplenishmentSuggestion for how the quantities are computed. Draft
+// purchase orders count as on order, so products already on a suggested
+// Draft are not suggested again.
+func (p *PurchasingImpl) SuggestReplenishment(ctx context.Context, q models.ReplenishmentQuery) ([]models.ReplenishmentSuggestion, error) {
+	var suggestions []models.ReplenishmentSuggestion
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		suggestions, err = suggestReplenishment(ctx, tx, q)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// CreateSuggestedPurchaseOrders creates a Draft purchase order for each
+// supplier with suggested purchases, delivered to the default warehouse,
+// and returns them by supplier ID. Products without a supplier are left
+// out; their suggestions must be ordered by hand.
+func (p *PurchasingImpl) CreateSuggestedPurchaseOrders(ctx context.Context, q models.ReplenishmentQuery) ([]models.PurchaseOrder, error) {
+	created := []models.PurchaseOrder{}
+	err := p.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		suggestions, err := suggestReplenishment(ctx, tx, q)
+		if err != nil {
+			return err
+		}
+		lines := make(map[string][]models.PurchaseOrderLine)
+		for _, s := range suggestions {
+			if s.SupplierID != "" {
+				lines[s.SupplierID] = append(lines[s.SupplierID], models.PurchaseOrderLine{ProductID: s.ProductID, Quantity: s.SuggestedQuantity})
+			}
+		}
+		supplierIDs := make([]string, 0, len(lines))
+		for supplierID := range lines {
+			supplierIDs = append(supplierIDs, supplierID)
+		}
+		sort.Strings(supplierIDs)
+
+		for _, supplierID := range supplierIDs {
+			po := draftPurchaseOrder(models.PurchaseOrder{SupplierID: supplierID, Lines: lines[supplierID]})
+			if err := tx.InsertPurchaseOrder(ctx, &po); err != nil {
+				return fmt.Errorf("could not create purchase order: %w", err)
+			}
+			created = append(created, po)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// suggestReplenishment computes the suggestions for q within tx.
+func suggestReplenishment(ctx context.Context, tx StoreTx, q models.ReplenishmentQuery) ([]models.ReplenishmentSuggestion, error) {
+	windowDays := q.WindowDays
+	if windowDays == 0 {
+		windowDays = DefaultDemandWindowDays
+	}
+	if windowDays < 1 || windowDays > MaxDemandWindowDays {
+		return nil, fmt.Errorf("%w: window_days must be between 1 and %d", ErrInvalidQuery, MaxDemandWindowDays)
+	}
+	if q.SupplierID != "" {
+		_, err := tx.GetSupplier(ctx, q.SupplierID)
+		if errors.Is(err, errNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrSupplierNotFound, q.SupplierID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch supplier: %w", err)
+		}
+	}
+
+	suppliers, err := tx.ListSuppliers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch suppliers: %w", err)
+	}
+	leadTimes := make(map[string]int, len(suppliers))
+	for _, supplier := range suppliers {
+		leadTimes[supplier.ID] = supplier.LeadTimeDays
+	}
+	ordered, err := tx.UnitsOrdered(ctx, time.Now().UTC().AddDate(0, 0, -windowDays))
+	if err != nil {
+		return nil, fmt.Errorf("could not sum orders: %w", err)
+	}
+	onOrder, err := tx.OnOrder(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not sum purchase orders: %w", err)
+	}
+
+	suggestions := []models.ReplenishmentSuggestion{}
+	spec := pageSpec{field: "id", limit: MaxPageSize}
+	for {
+		products, err := tx.ListProducts(ctx, models.ProductFilter{SupplierID: q.SupplierID}, spec)
+		if err != nil {
+			return nil, fmt.Errorf("could not fetch products: %w", err)
+		}
+		for _, product := range products {
+			inTransit, err := tx.InTransitStock(ctx, product.ID)
+			if err != nil {
+				return nil, fmt.Errorf("could not fetch in-transit stock: %w", err)
+			}
+			s := models.ReplenishmentSuggestion{
+				ProductID:    product.ID,
+				SupplierID:   product.SupplierID,
+				UnitsOrdered: ordered[product.ID],
+				DailyDemand:  float64(ordered[product.ID]) / float64(windowDays),
+				LeadTimeDays: leadTimes[product.SupplierID],
+				SafetyStock:  product.SafetyStock,
+				Stock:        product.Stock,
+				InTransit:    inTransit,
+				OnOrder:      onOrder[product.ID],
+			}
+			// Rounding up in integers keeps whole-unit demand exact.
+			s.LeadTimeDemand = (s.UnitsOrdered*s.LeadTimeDays + windowDays - 1) / windowDays
+			s.ReorderLevel = s.LeadTimeDemand + s.SafetyStock
+			position := s.Stock + s.InTransit + s.OnOrder
+			if position >= s.ReorderLevel {
+				continue
+			}
+			s.SuggestedQuantity = s.ReorderLevel - position + s.LeadTimeDemand
+			suggestions = append(suggestions, s)
+		}
+		if len(products) < spec.limit {
+			return suggestions, nil
+		}
+		last := products[len(products)-1]
+		spec.after = &pageKey{value: last.ID, id: last.ID}
+	}
+}
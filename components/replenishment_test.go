@@ -0,0 +1,121 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestReplenishment(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+		purchasing := NewPurchasing(h)
+
+		supplier := models.Supplier{ID: h.productID(), Name: "Steady", LeadTimeDays: 10}
+		if err := purchasing.AddSupplier(ctx, supplier); err != nil {
+			t.Fatalf("AddSupplier: %v", err)
+		}
+		if err := inventory.AddProduct(ctx, models.Product{ID: h.productID(), Name: "orphan", Price: 1, SupplierID: supplier.ID + "-missing"}); !errors.Is(err, ErrSupplierNotFound) {
+			t.Errorf("AddProduct(missing supplier) error = %v, want ErrSupplierNotFound", err)
+		}
+
+		selling, stocked, idle, moving := h.productID(), h.productID(), h.productID(), h.productID()
+		products := []models.Product{
+			{ID: selling, Name: "selling", Stock: 8, Price: 1, ReorderQuantity: 4, SupplierID: supplier.ID, SafetyStock: 3},
+			{ID: stocked, Name: "stocked", Stock: 100, Price: 1, SupplierID: supplier.ID, SafetyStock: 3},
+			{ID: idle, Name: "idle", Price: 1, SupplierID: supplier.ID, SafetyStock: 2},
+			{ID: moving, Name: "moving", Stock: 2, Price: 1, SupplierID: supplier.ID, SafetyStock: 3},
+		}
+		for _, product := range products {
+			if err := inventory.AddProduct(ctx, product); err != nil {
+				t.Fatalf("AddProduct: %v", err)
+			}
+		}
+		negative := -1
+		if _, err := inventory.UpdateProduct(ctx, idle, models.ProductUpdate{SafetyStock: &negative}); !errors.Is(err, ErrInvalidProduct) {
+			t.Errorf("UpdateProduct(negative safety stock) error = %v, want ErrInvalidProduct", err)
+		}
+
+		// Stock in transit between warehouses still counts.
+		destination := h.productID()
+		if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: destination, Name: "replenishment", Priority: 4000}); err != nil {
+			t.Fatalf("AddWarehouse: %v", err)
+		}
+		transfer, err := inventory.CreateTransfer(ctx, models.Transfer{
+			SourceID:      DefaultWarehouseID,
+			DestinationID: destination,
+			Lines:         []models.TransferLine{{ProductID: moving, Quantity: 2}},
+		})
+		if err != nil {
+			t.Fatalf("CreateTransfer: %v", err)
+		}
+		if _, err := inventory.ShipTransfer(ctx, transfer.ID); err != nil {
+			t.Fatalf("ShipTransfer: %v", err)
+		}
+
+		if _, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: selling, Quantity: 6}}}); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		// Cancelled orders are not demand.
+		cancelled, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: selling, Quantity: 1}}})
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if err := orders.CancelOrder(ctx, cancelled.ID); err != nil {
+			t.Fatalf("CancelOrder: %v", err)
+		}
+
+		for _, q := range []models.ReplenishmentQuery{{WindowDays: -1}, {WindowDays: MaxDemandWindowDays + 1}} {
+			if _, err := purchasing.SuggestReplenishment(ctx, q); !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("SuggestReplenishment(%+v) error = %v, want ErrInvalidQuery", q, err)
+			}
+		}
+		if _, err := purchasing.SuggestReplenishment(ctx, models.ReplenishmentQuery{SupplierID: supplier.ID + "-missing"}); !errors.Is(err, ErrSupplierNotFound) {
+			t.Errorf("SuggestReplenishment(missing supplier) error = %v, want ErrSupplierNotFound", err)
+		}
+
+		q := models.ReplenishmentQuery{SupplierID: supplier.ID}
+		suggestions, err := purchasing.SuggestReplenishment(ctx, q)
+		if err != nil || len(suggestions) != 3 {
+			t.Fatalf("SuggestReplenishment = %+v, %v", suggestions, err)
+		}
+		byProduct := make(map[string]models.ReplenishmentSuggestion)
+		for _, s := range suggestions {
+			byProduct[s.ProductID] = s
+		}
+		// 6 units over 30 days is 2 during the 10 day lead time, plus the
+		// safety stock of 3; the 3 missing are ordered with the 2 the next
+		// lead time needs.
+		want := models.ReplenishmentSuggestion{
+			ProductID: selling, SupplierID: supplier.ID, UnitsOrdered: 6, DailyDemand: 0.2, LeadTimeDays: 10,
+			LeadTimeDemand: 2, SafetyStock: 3, Stock: 2, ReorderLevel: 5, SuggestedQuantity: 5,
+		}
+		if got := byProduct[selling]; got != want {
+			t.Errorf("suggestion for selling = %+v, want %+v", got, want)
+		}
+		if got := byProduct[idle]; got.ReorderLevel != 2 || got.SuggestedQuantity != 2 {
+			t.Errorf("suggestion for idle = %+v, want 2 to reach its safety stock", got)
+		}
+		if got := byProduct[moving]; got.Stock != 0 || got.InTransit != 2 || got.SuggestedQuantity != 1 {
+			t.Errorf("suggestion for moving = %+v, want 1 with 2 in transit", got)
+		}
+
+		created, err := purchasing.CreateSuggestedPurchaseOrders(ctx, q)
+		if err != nil || len(created) != 1 || created[0].SupplierID != supplier.ID ||
+			created[0].Status != models.PurchaseOrderStatusDraft || len(created[0].Lines) != 3 {
+			t.Fatalf("CreateSuggestedPurchaseOrders = %+v, %v", created, err)
+		}
+
+		// The drafts are on order, so nothing is suggested twice.
+		if suggestions, err := purchasing.SuggestReplenishment(ctx, q); err != nil || len(suggestions) != 0 {
+			t.Errorf("SuggestReplenishment after drafts = %+v, %v", suggestions, err)
+		}
+		if created, err := purchasing.CreateSuggestedPurchaseOrders(ctx, q); err != nil || len(created) != 0 {
+			t.Errorf("CreateSuggestedPurchaseOrders again = %+v, %v", created, err)
+		}
+	})
+}
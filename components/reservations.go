@@ -0,0 +1,275 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+const (
+	// DefaultReservationTTL is how long a reservation holds stock when no
+	// TTL is given.
+	DefaultReservationTTL = 15 * time.Minute
+	// MaxReservationTTL is the longest TTL a reservation may have.
+	MaxReservationTTL = 24 * time.Hour
+	// ReservationSweepInterval is how often SweepReservations releases
+	// expired reservations.
+	ReservationSweepInterval = 30 * time.Second
+)
+
+// ReserveStock holds quantity units of a product at a warehouse for ttl,
+// or DefaultReservationTTL if ttl is zero. If warehouseID is empty the
+// first warehouse, in priority order, with enough available stock is used.
+// The held stock stays on hand but is no longer available until the
+// reservation is confirmed, released or expires. Stock in expired lots
+// cannot be reserved.
+func (im *InventoryManagementImpl) ReserveStock(ctx context.Context, warehouseID, productID string, quantity int, ttl time.Duration) (models.Reservation, error) {
+	if ttl == 0 {
+		ttl = DefaultReservationTTL
+	}
+	switch {
+	case productID == "":
+		return models.Reservation{}, fmt.Errorf("%w: product_id is required", ErrInvalidReservation)
+	case quantity <= 0:
+		return models.Reservation{}, fmt.Errorf("%w: quantity must be positive", ErrInvalidReservation)
+	case ttl < 0 || ttl > MaxReservationTTL:
+		return models.Reservation{}, fmt.Errorf("%w: ttl must be between 0 and %s", ErrInvalidReservation, MaxReservationTTL)
+	}
+
+	reservation := models.Reservation{
+		ProductID:   productID,
+		WarehouseID: warehouseID,
+		Quantity:    quantity,
+		Status:      models.ReservationStatusActive,
+		ExpiresAt:   time.Now().UTC().Add(ttl).Truncate(time.Microsecond),
+	}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if reservation.WarehouseID == "" {
+			var err error
+			if reservation.WarehouseID, err = availableWarehouse(ctx, tx, productID, quantity); err != nil {
+				return err
+			}
+		}
+		lots, err := tx.LockActiveLots(ctx, reservation.WarehouseID, productID)
+		if err != nil {
+			return fmt.Errorf("could not fetch lots: %w", err)
+		}
+		_, expired := splitExpired(lots)
+		if err := tx.AdjustReserved(ctx, reservation.WarehouseID, productID, quantity); err != nil {
+			return stockError(ctx, tx, reservation.WarehouseID, productID, err)
+		}
+		if err := keepExpired(ctx, tx, reservation.WarehouseID, productID, expired); err != nil {
+			return err
+		}
+		if err := tx.InsertReservation(ctx, &reservation); err != nil {
+			return fmt.Errorf("could not create reservation: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// availableWarehouse returns the first warehouse, in priority order, with
+// quantity units of a product available.
+func availableWarehouse(ctx context.Context, tx StoreTx, productID string, quantity int) (string, error) {
+	if _, err := tx.GetProduct(ctx, productID); errors.Is(err, errNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+	} else if err != nil {
+		return "", fmt.Errorf("could not fetch product: %w", err)
+	}
+	levels, err := tx.ListStockLevels(ctx, productID)
+	if err != nil {
+		return "", fmt.Errorf("could not fetch stock levels: %w", err)
+	}
+	expired, err := expiredStock(ctx, tx, productID)
+	if err != nil {
+		return "", err
+	}
+	for _, level := range levels {
+		level.Expired = expired[level.WarehouseID]
+		if availableStock(level) >= quantity {
+			return level.WarehouseID, nil
+		}
+	}
+	return "", fmt.Errorf("%w for product %s", ErrInsufficientStock, productID)
+}
+
+// GetReservation returns a reservation.
+func (im *InventoryManagementImpl) GetReservation(ctx context.Context, reservationID string) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		reservation, err = tx.GetReservation(ctx, reservationID)
+		return err
+	})
+	if errors.Is(err, errNotFound) {
+		return models.Reservation{}, fmt.Errorf("%w: %s", ErrReservationNotFound, reservationID)
+	}
+	if err != nil {
+		return models.Reservation{}, fmt.Errorf("could not fetch reservation: %w", err)
+	}
+	return reservation, nil
+}
+
+// ConfirmReservation takes the stock an Active reservation holds, recording
+// it in the stock ledger, and marks the reservation Confirmed. Confirming a
+// Confirmed reservation is a no-op. A reservation that has passed its
+// expiry is released as Expired instead, and ErrReservationExpired is
+// returned.
+func (im *InventoryManagementImpl) ConfirmReservation(ctx context.Context, reservationID string) (models.Reservation, error) {
+	var reservation models.Reservation
+	expired := false
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		reservation, err = lockReservation(ctx, tx, reservationID)
+		if err != nil {
+			return err
+		}
+		switch reservation.Status {
+		case models.ReservationStatusConfirmed:
+			return nil
+		case models.ReservationStatusActive:
+		default:
+			return fmt.Errorf("%w: reservation %s is %s", ErrInvalidReservation, reservationID, reservation.Status)
+		}
+		if !time.Now().Before(reservation.ExpiresAt) {
+			expired = true
+			return releaseReservation(ctx, tx, &reservation, models.ReservationStatusExpired)
+		}
+
+		if err := tx.AdjustReserved(ctx, reservation.WarehouseID, reservation.ProductID, -reservation.Quantity); err != nil {
+			return stockError(ctx, tx, reservation.WarehouseID, reservation.ProductID, err)
+		}
+		movement := models.StockMovement{
+			WarehouseID: reservation.WarehouseID,
+			ProductID:   reservation.ProductID,
+			Delta:       -reservation.Quantity,
+			Reason:      models.MovementReasonReservation,
+			Reference:   reservationID,
+		}
+		if _, err := adjustStock(ctx, tx, movement); err != nil {
+			return err
+		}
+		return setReservationStatus(ctx, tx, &reservation, models.ReservationStatusConfirmed)
+	})
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	if expired {
+		return reservation, fmt.Errorf("%w: reservation %s expired at %s", ErrReservationExpired, reservationID, reservation.ExpiresAt.Format(time.RFC3339))
+	}
+	return reservation, nil
+}
+
+// ReleaseReservation frees the stock an Active reservation holds and marks
+// it Released. Releasing a reservation that is already Released or Expired
+// is a no-op, so its stock is only ever freed once.
+func (im *InventoryManagementImpl) ReleaseReservation(ctx context.Context, reservationID string) (models.Reservation, error) {
+	var reservation models.Reservation
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		reservation, err = lockReservation(ctx, tx, reservationID)
+		if err != nil {
+			return err
+		}
+		switch reservation.Status {
+		case models.ReservationStatusReleased, models.ReservationStatusExpired:
+			return nil
+		case models.ReservationStatusActive:
+			return releaseReservation(ctx, tx, &reservation, models.ReservationStatusReleased)
+		default:
+			return fmt.Errorf("%w: reservation %s is %s", ErrInvalidReservation, reservationID, reservation.Status)
+		}
+	})
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return reservation, nil
+}
+
+// ReleaseExpiredReservations releases every Active reservation that has
+// passed its expiry as Expired, and returns how many it released.
+func (im *InventoryManagementImpl) ReleaseExpiredReservations(ctx context.Context) (int, error) {
+	released := 0
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		filter := models.ReservationFilter{Status: models.ReservationStatusActive, ExpiredBy: time.Now().UTC()}
+		expired, err := tx.ListReservations(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("could not fetch reservations: %w", err)
+		}
+		for _, r := range expired {
+			// A concurrent confirmation or release may have got there
+			// first.
+			reservation, err := lockReservation(ctx, tx, r.ID)
+			if err != nil {
+				return err
+			}
+			if reservation.Status != models.ReservationStatusActive {
+				continue
+			}
+			if err := releaseReservation(ctx, tx, &reservation, models.ReservationStatusExpired); err != nil {
+				return err
+			}
+			released++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return released, nil
+}
+
+// SweepReservations calls ReleaseExpiredReservations every interval until
+// ctx is done, logging failures.
+func SweepReservations(ctx context.Context, inventory InventoryManagement, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := inventory.ReleaseExpiredReservations(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to release expired reservations: %v", err)
+			}
+		}
+	}
+}
+
+// lockReservation locks a reservation for the rest of the transaction.
+func lockReservation(ctx context.Context, tx StoreTx, reservationID string) (models.Reservation, error) {
+	reservation, err := tx.LockReservation(ctx, reservationID)
+	if errors.Is(err, errNotFound) {
+		return models.Reservation{}, fmt.Errorf("%w: %s", ErrReservationNotFound, reservationID)
+	}
+	if err != nil {
+		return models.Reservation{}, fmt.Errorf("could not fetch reservation: %w", err)
+	}
+	return reservation, nil
+}
+
+// releaseReservation frees the stock an Active reservation holds and moves
+// it to status.
+func releaseReservation(ctx context.Context, tx StoreTx, reservation *models.Reservation, status string) error {
+	if err := tx.AdjustReserved(ctx, reservation.WarehouseID, reservation.ProductID, -reservation.Quantity); err != nil {
+		return stockError(ctx, tx, reservation.WarehouseID, reservation.ProductID, err)
+	}
+	return setReservationStatus(ctx, tx, reservation, status)
+}
+
+// setReservationStatus changes the status of a reservation and of its copy.
+func setReservationStatus(ctx context.Context, tx StoreTx, reservation *models.Reservation, status string) error {
+	if err := tx.SetReservationStatus(ctx, reservation.ID, status); err != nil {
+		return fmt.Errorf("could not update reservation status: %w", err)
+	}
+	reservation.Status = status
+	return nil
+}
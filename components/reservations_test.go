@@ -0,0 +1,116 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+func TestReservations(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "held", Stock: 5, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		available := func() int {
+			t.Helper()
+			report, err := inventory.CheckStockLevels(ctx, productID)
+			if err != nil {
+				t.Fatalf("CheckStockLevels: %v", err)
+			}
+			return report.Available
+		}
+
+		for _, ttl := range []time.Duration{-time.Second, MaxReservationTTL + time.Second} {
+			if _, err := inventory.ReserveStock(ctx, "", productID, 1, ttl); !errors.Is(err, ErrInvalidReservation) {
+				t.Errorf("ReserveStock(ttl %s) error = %v, want ErrInvalidReservation", ttl, err)
+			}
+		}
+		if _, err := inventory.ReserveStock(ctx, "", productID, 6, 0); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("ReserveStock(6) error = %v, want ErrInsufficientStock", err)
+		}
+
+		held, err := inventory.ReserveStock(ctx, "", productID, 3, 0)
+		if err != nil || held.WarehouseID != DefaultWarehouseID || held.Status != models.ReservationStatusActive {
+			t.Fatalf("ReserveStock = %+v, %v", held, err)
+		}
+		if got := available(); got != 2 {
+			t.Errorf("available after reserving 3 = %d, want 2", got)
+		}
+		if _, err := orders.CreateOrder(ctx, models.Order{Items: []models.OrderItem{{ProductID: productID, Quantity: 3}}}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("CreateOrder(held stock) error = %v, want ErrInsufficientStock", err)
+		}
+		if err := inventory.UpdateStock(ctx, productID, -3); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("UpdateStock(held stock) error = %v, want ErrInsufficientStock", err)
+		}
+
+		confirmed, err := inventory.ConfirmReservation(ctx, held.ID)
+		if err != nil || confirmed.Status != models.ReservationStatusConfirmed {
+			t.Fatalf("ConfirmReservation = %+v, %v", confirmed, err)
+		}
+		if _, err := inventory.ConfirmReservation(ctx, held.ID); err != nil {
+			t.Errorf("ConfirmReservation(again) error = %v", err)
+		}
+		if stock, _ := inventory.CheckStock(ctx, productID); stock != 2 || available() != 2 {
+			t.Errorf("stock after confirming = %d, available %d, want 2 and 2", stock, available())
+		}
+		if _, err := inventory.ReleaseReservation(ctx, held.ID); !errors.Is(err, ErrInvalidReservation) {
+			t.Errorf("ReleaseReservation(confirmed) error = %v, want ErrInvalidReservation", err)
+		}
+
+		released, err := inventory.ReserveStock(ctx, "", productID, 2, 0)
+		if err != nil {
+			t.Fatalf("ReserveStock: %v", err)
+		}
+		for i := 0; i < 2; i++ {
+			if r, err := inventory.ReleaseReservation(ctx, released.ID); err != nil || r.Status != models.ReservationStatusReleased {
+				t.Errorf("ReleaseReservation = %+v, %v", r, err)
+			}
+		}
+		if got := available(); got != 2 {
+			t.Errorf("available after releasing = %d, want 2", got)
+		}
+		if _, err := inventory.ConfirmReservation(ctx, released.ID); !errors.Is(err, ErrInvalidReservation) {
+			t.Errorf("ConfirmReservation(released) error = %v, want ErrInvalidReservation", err)
+		}
+
+		short, err := inventory.ReserveStock(ctx, DefaultWarehouseID, productID, 1, time.Millisecond)
+		if err != nil {
+			t.Fatalf("ReserveStock: %v", err)
+		}
+		lapsed, err := inventory.ReserveStock(ctx, DefaultWarehouseID, productID, 1, time.Millisecond)
+		if err != nil {
+			t.Fatalf("ReserveStock: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if r, err := inventory.ConfirmReservation(ctx, lapsed.ID); !errors.Is(err, ErrReservationExpired) || r.Status != models.ReservationStatusExpired {
+			t.Errorf("ConfirmReservation(expired) = %+v, %v, want ErrReservationExpired", r, err)
+		}
+		if n, err := inventory.ReleaseExpiredReservations(ctx); err != nil || n < 1 {
+			t.Errorf("ReleaseExpiredReservations = %d, %v, want at least 1", n, err)
+		}
+		if r, err := inventory.GetReservation(ctx, short.ID); err != nil || r.Status != models.ReservationStatusExpired {
+			t.Errorf("GetReservation(expired) = %+v, %v", r, err)
+		}
+		if got := available(); got != 2 {
+			t.Errorf("available after expiry = %d, want 2", got)
+		}
+
+		page, err := inventory.GetStockMovements(ctx, models.MovementQuery{
+			MovementFilter: models.MovementFilter{ProductID: productID, Reason: models.MovementReasonReservation},
+		})
+		if err != nil || len(page.Movements) != 1 || page.Movements[0].Delta != -3 || page.Movements[0].Reference != held.ID {
+			t.Errorf("reservation movements = %+v, %v", page.Movements, err)
+		}
+		if _, err := inventory.GetReservation(ctx, "999999"); !errors.Is(err, ErrReservationNotFound) {
+			t.Errorf("GetReservation(missing) error = %v, want ErrReservationNotFound", err)
+		}
+	})
+}
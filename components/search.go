@@ -0,0 +1,127 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"service-weaver-app/models"
+)
+
+const (
+	// DefaultSuggestions is the number of autocomplete suggestions returned
+	// when the request does not set a limit.
+	DefaultSuggestions = 10
+	// maxSearchTerms bounds the number of words of a search that are used.
+	maxSearchTerms = 8
+)
+
+// searchWeights are the weights of the product fields a search term can
+// match, from best to worst. They are ts_rank's default weights for the
+// A, B and C labels the Postgres search vector gives the same fields.
+var searchWeights = []struct {
+	column string
+	weight float64
+	text   func(models.Product) string
+}{
+	{"name", 1.0, func(p models.Product) string { return p.Name }},
+	{"tags", 0.4, func(p models.Product) string { return strings.Join(p.Tags, " ") }},
+	{"description", 0.2, func(p models.Product) string { return p.Description }},
+}
+
+// searchTerms splits search text into lower-case words of letters and
+// digits. Everything else separates words, so the terms are safe to use in
+// full-text query syntax.
+func searchTerms(text string) []string {
+	terms := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(terms) > maxSearchTerms {
+		terms = terms[:maxSearchTerms]
+	}
+	return terms
+}
+
+// searchRank returns the rank of product for terms, and whether every term
+// is a prefix of a word in one of its searchWeights fields.
+func searchRank(product models.Product, terms []string) (float64, bool) {
+	rank := 0.0
+	for _, term := range terms {
+		matched := false
+		for _, field := range searchWeights {
+			for _, word := range searchTerms(field.text(product)) {
+				if strings.HasPrefix(word, term) {
+					rank += field.weight
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			return 0, false
+		}
+	}
+	return rank, true
+}
+
+// sortMatches orders matches by descending rank, breaking ties by product
+// ID.
+func sortMatches(matches []models.ProductMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+		return matches[i].Product.ID < matches[j].Product.ID
+	})
+}
+
+// SearchProducts returns up to limit products whose name, tags or
+// description contain a word starting with each word of text, best matches
+// first. Matches in the name rank above matches in the tags, which rank
+// above matches in the description. A limit of zero means DefaultPageSize.
+func (im *InventoryManagementImpl) SearchProducts(ctx context.Context, text string, limit int) ([]models.ProductMatch, error) {
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit < 0 || limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidQuery, MaxPageSize)
+	}
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return []models.ProductMatch{}, nil
+	}
+
+	var matches []models.ProductMatch
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		matches, err = tx.SearchProducts(ctx, terms, limit)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not search products: %w", err)
+	}
+	if matches == nil {
+		matches = []models.ProductMatch{}
+	}
+	return matches, nil
+}
+
+// AutocompleteProducts suggests up to limit products for the text typed so
+// far, using the same matching and ranking as SearchProducts. A limit of
+// zero means DefaultSuggestions.
+func (im *InventoryManagementImpl) AutocompleteProducts(ctx context.Context, text string, limit int) ([]models.ProductSuggestion, error) {
+	if limit == 0 {
+		limit = DefaultSuggestions
+	}
+	matches, err := im.SearchProducts(ctx, text, limit)
+	if err != nil {
+		return nil, err
+	}
+	suggestions := make([]models.ProductSuggestion, len(matches))
+	for i, match := range matches {
+		suggestions[i] = models.ProductSuggestion{ID: match.Product.ID, Name: match.Product.Name}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,116 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"service-weaver-app/database"
+	"service-weaver-app/models"
+)
+
+func TestSearchProducts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+
+		// Every product mentions a word unique to this run, in a different
+		// field, so the search ranks them by the field it matches.
+		word := fmt.Sprintf("zq%dalpha", rand.Int31())
+		inName := models.Product{ID: h.productID(), Name: word + " gizmo", Tags: []string{"kitchen"}, Stock: 1, Price: 1}
+		inTags := models.Product{ID: h.productID(), Name: "plain thing", Tags: []string{"steel", word}, Stock: 1, Price: 1}
+		inDescription := models.Product{ID: h.productID(), Name: "other", Description: "Fits every " + word + " gizmo.", Stock: 1, Price: 1}
+		for _, p := range []models.Product{inName, inTags, inDescription, {ID: h.productID(), Name: "unrelated", Stock: 1, Price: 1}} {
+			if err := inventory.AddProduct(ctx, p); err != nil {
+				t.Fatalf("AddProduct: %v", err)
+			}
+		}
+
+		search := func(text string, limit int) []string {
+			t.Helper()
+			matches, err := inventory.SearchProducts(ctx, text, limit)
+			if err != nil {
+				t.Fatalf("SearchProducts(%q): %v", text, err)
+			}
+			var ids []string
+			for i, match := range matches {
+				if i > 0 && match.Rank > matches[i-1].Rank {
+					t.Errorf("SearchProducts(%q) is not sorted by rank: %+v", text, matches)
+				}
+				ids = append(ids, match.Product.ID)
+			}
+			return ids
+		}
+
+		prefix := word[:len(word)-3]
+		if got, want := fmt.Sprint(search(prefix, 0)), fmt.Sprint([]string{inName.ID, inTags.ID, inDescription.ID}); got != want {
+			t.Errorf("search by prefix = %s, want %s", got, want)
+		}
+		if got, want := fmt.Sprint(search(word+" GIZ", 0)), fmt.Sprint([]string{inName.ID, inDescription.ID}); got != want {
+			t.Errorf("search by two words = %s, want %s", got, want)
+		}
+		if got := search(word, 1); len(got) != 1 || got[0] != inName.ID {
+			t.Errorf("search with limit 1 = %v, want [%s]", got, inName.ID)
+		}
+		if got := search("  !? ", 0); len(got) != 0 {
+			t.Errorf("search without words = %v, want none", got)
+		}
+
+		// The index follows updates and deletes.
+		name := "renamed"
+		if _, err := inventory.UpdateProduct(ctx, inName.ID, models.ProductUpdate{Name: &name}); err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+		if err := inventory.DeleteProduct(ctx, inTags.ID); err != nil {
+			t.Fatalf("DeleteProduct: %v", err)
+		}
+		if got, want := fmt.Sprint(search(word, 0)), fmt.Sprint([]string{inDescription.ID}); got != want {
+			t.Errorf("search after changes = %s, want %s", got, want)
+		}
+
+		suggestions, err := inventory.AutocompleteProducts(ctx, prefix, 0)
+		if err != nil {
+			t.Fatalf("AutocompleteProducts: %v", err)
+		}
+		if len(suggestions) != 1 || suggestions[0] != (models.ProductSuggestion{ID: inDescription.ID, Name: "other"}) {
+			t.Errorf("AutocompleteProducts = %+v", suggestions)
+		}
+	})
+}
+
+func TestSearchProductsAfterVacuum(t *testing.T) {
+	ctx := context.Background()
+	db := testDB(t, database.SQLite)
+	inventory := NewInventoryManagement(NewSQLStore(db, database.SQLite))
+
+	// Deleting the first product and vacuuming renumbers the rowids of the
+	// rest, which the index must not depend on.
+	word := fmt.Sprintf("zq%dvacuum", rand.Int31())
+	for _, p := range []models.Product{{ID: "first", Name: "first", Price: 1}, {ID: "second", Name: word, Price: 1}} {
+		if err := inventory.AddProduct(ctx, p); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+	}
+	if err := inventory.DeleteProduct(ctx, "first"); err != nil {
+		t.Fatalf("DeleteProduct: %v", err)
+	}
+	if _, err := db.Exec(`VACUUM`); err != nil {
+		t.Fatalf("VACUUM: %v", err)
+	}
+
+	var indexed string
+	if err := db.QueryRow(`SELECT product_id FROM products_search WHERE products_search MATCH $1`, word).Scan(&indexed); err != nil || indexed != "second" {
+		t.Errorf("index holds %q, %v, want second", indexed, err)
+	}
+	if matches, err := inventory.SearchProducts(ctx, word, 0); err != nil || len(matches) != 1 || matches[0].Product.ID != "second" {
+		t.Errorf("SearchProducts after VACUUM = %+v, %v, want second", matches, err)
+	}
+	name := "renamed " + word
+	if _, err := inventory.UpdateProduct(ctx, "second", models.ProductUpdate{Name: &name}); err != nil {
+		t.Fatalf("UpdateProduct: %v", err)
+	}
+	if matches, err := inventory.SearchProducts(ctx, "renamed", 0); err != nil || len(matches) != 1 || matches[0].Product.ID != "second" {
+		t.Errorf("SearchProducts after update = %+v, %v, want second", matches, err)
+	}
+}
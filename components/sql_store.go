@@ -0,0 +1,1629 @@
+package components
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"service-weaver-app/database"
+	"service-weaver-app/models"
+)
+
+// SQLStore is a Store backed by the database schema in database/migrations.
+type SQLStore struct {
+	db      *sql.DB
+	dialect database.Dialect
+}
+
+// NewSQLStore initializes a new SQLStore instance for a database of the
+// given dialect.
+func NewSQLStore(db *sql.DB, dialect database.Dialect) *SQLStore {
+	return &SQLStore{db: db, dialect: dialect}
+}
+
+// InTx runs fn in a database transaction.
+func (s *SQLStore) InTx(ctx context.Context, fn func(ctx context.Context, tx StoreTx) error) error {
+	if tx, ok := txFromContext(ctx, s); ok {
+		return fn(ctx, tx)
+	}
+
+	sqlTx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer sqlTx.Rollback()
+
+	tx := &sqlStoreTx{tx: sqlTx, dialect: s.dialect}
+	if err := fn(contextWithTx(ctx, s, tx), tx); err != nil {
+		return err
+	}
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
+
+// sqlStoreTx is the StoreTx of a SQLStore. Its queries are written for
+// Postgres and rebound for the store's dialect.
+type sqlStoreTx struct {
+	tx      *sql.Tx
+	dialect database.Dialect
+}
+
+func (t *sqlStoreTx) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *sqlStoreTx) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.tx.QueryContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *sqlStoreTx) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return t.tx.QueryRowContext(ctx, t.dialect.Rebind(query), args...)
+}
+
+func (t *sqlStoreTx) InsertProduct(ctx context.Context, product models.Product) error {
+	query := `INSERT INTO products (id, name, description, tags, stock, price, reorder_point, reorder_quantity, supplier_id, safety_stock)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NULLIF($9, ''), $10)`
+	_, err := t.exec(ctx, query, product.ID, product.Name, product.Description, tagsArg(product.Tags), product.Stock, product.Price,
+		product.ReorderPoint, product.ReorderQuantity, product.SupplierID, product.SafetyStock)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("product %s %w", product.ID, errDuplicate)
+	}
+	if err != nil || product.Stock <= 0 {
+		return err
+	}
+	query = `INSERT INTO stock_levels (warehouse_id, product_id, quantity) VALUES ($1, $2, $3)`
+	_, err = t.exec(ctx, query, DefaultWarehouseID, product.ID, product.Stock)
+	return err
+}
+
+func (t *sqlStoreTx) GetProduct(ctx context.Context, productID string) (models.Product, error) {
+	query := `SELECT ` + productColumns + ` FROM products WHERE id = $1`
+	product, err := scanProduct(t.queryRow(ctx, query, productID))
+	if err == sql.ErrNoRows {
+		return models.Product{}, fmt.Errorf("product %s %w", productID, errNotFound)
+	}
+	return product, err
+}
+
+func (t *sqlStoreTx) ListProducts(ctx context.Context, filter models.ProductFilter, page pageSpec) ([]models.Product, error) {
+	var where conditions
+	if filter.NameContains != "" {
+		where.add(`LOWER(name) LIKE $? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(filter.NameContains))+"%")
+	}
+	if filter.StockBelow != nil {
+		where.add(`stock < $?`, *filter.StockBelow)
+	}
+	if filter.MinPrice != nil {
+		where.add(`price >= $?`, *filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		where.add(`price <= $?`, *filter.MaxPrice)
+	}
+	if filter.SupplierID != "" {
+		where.add(`supplier_id = $?`, filter.SupplierID)
+	}
+
+	query := `SELECT ` + productColumns + ` FROM products` + t.pageClauses(&where, page)
+	rows, err := t.query(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []models.Product
+	for rows.Next() {
+		product, err := scanProduct(rows)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+	return products, rows.Err()
+}
+
+func (t *sqlStoreTx) UpdateProduct(ctx context.Context, productID string, update models.ProductUpdate) (models.Product, error) {
+	var tags interface{}
+	if update.Tags != nil {
+		tags = tagsArg(*update.Tags)
+	}
+	// supplier_id is nullable, so whether to change it is passed separately
+	// from its new value.
+	var supplierID string
+	if update.SupplierID != nil {
+		supplierID = *update.SupplierID
+	}
+	query := `UPDATE products SET name = COALESCE($2, name), description = COALESCE($3, description),
+		tags = COALESCE($4, tags), price = COALESCE($5, price),
+		reorder_point = COALESCE($6, reorder_point), reorder_quantity = COALESCE($7, reorder_quantity),
+		supplier_id = CASE WHEN $8 THEN NULLIF($9, '') ELSE supplier_id END, safety_stock = COALESCE($10, safety_stock)
+		WHERE id = $1 RETURNING ` + productColumns
+	product, err := scanProduct(t.queryRow(ctx, query, productID, update.Name, update.Description, tags, update.Price,
+		update.ReorderPoint, update.ReorderQuantity, update.SupplierID != nil, supplierID, update.SafetyStock))
+	if err == sql.ErrNoRows {
+		return models.Product{}, fmt.Errorf("product %s %w", productID, errNotFound)
+	}
+	return product, err
+}
+
+func (t *sqlStoreTx) DeleteProduct(ctx context.Context, productID string) error {
+	result, err := t.exec(ctx, `DELETE FROM products WHERE id = $1`, productID)
+	if isForeignKeyViolation(err) {
+		return fmt.Errorf("product %s %w", productID, errInUse)
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("product %s %w", productID, errNotFound)
+	}
+	return nil
+}
+
+// AdjustStock changes the warehouse's stock_levels row, creating it when
+// stock first arrives, and then the product's total. A decrease only
+// applies while the level covers it and its reserved stock, so concurrent
+// transactions can never take a level below zero or below its holds.
+func (t *sqlStoreTx) AdjustStock(ctx context.Context, warehouseID, productID string, delta int) (models.Product, error) {
+	var result sql.Result
+	var err error
+	if delta >= 0 {
+		// Selecting the keys inserts nothing, rather than failing a foreign
+		// key and with it a Postgres transaction, when either is missing.
+		query := `INSERT INTO stock_levels (warehouse_id, product_id, quantity)
+			SELECT w.id, p.id, CAST($3 AS INTEGER) FROM warehouses w, products p WHERE w.id = $1 AND p.id = $2
+			ON CONFLICT (warehouse_id, product_id) DO UPDATE SET quantity = stock_levels.quantity + excluded.quantity`
+		result, err = t.exec(ctx, query, warehouseID, productID, delta)
+	} else {
+		query := `UPDATE stock_levels SET quantity = quantity + $1
+			WHERE warehouse_id = $2 AND product_id = $3 AND quantity + $1 >= reserved`
+		result, err = t.exec(ctx, query, delta, warehouseID, productID)
+	}
+	if err != nil {
+		return models.Product{}, err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		// Tell a missing product or warehouse apart from a level without
+		// enough stock.
+		if err := t.missingProductOrWarehouse(ctx, warehouseID, productID); err != nil {
+			return models.Product{}, err
+		}
+		return models.Product{}, fmt.Errorf("product %s at warehouse %s: %w", productID, warehouseID, errInsufficientStock)
+	}
+
+	query := `UPDATE products SET stock = stock + $1 WHERE id = $2 RETURNING ` + productColumns
+	return scanProduct(t.queryRow(ctx, query, delta, productID))
+}
+
+// AdjustReserved is a conditional update of the warehouse's stock_levels
+// row, like a decrease in AdjustStock. A level that does not exist holds no
+// stock to reserve.
+func (t *sqlStoreTx) AdjustReserved(ctx context.Context, warehouseID, productID string, delta int) error {
+	query := `UPDATE stock_levels SET reserved = reserved + $1
+		WHERE warehouse_id = $2 AND product_id = $3 AND reserved + $1 >= 0 AND reserved + $1 <= quantity`
+	result, err := t.exec(ctx, query, delta, warehouseID, productID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		if err := t.missingProductOrWarehouse(ctx, warehouseID, productID); err != nil {
+			return err
+		}
+		return fmt.Errorf("product %s at warehouse %s: %w", productID, warehouseID, errInsufficientStock)
+	}
+	return nil
+}
+
+// missingProductOrWarehouse returns an error wrapping errNotFound if the
+// product or the warehouse does not exist, and nil if both do.
+func (t *sqlStoreTx) missingProductOrWarehouse(ctx context.Context, warehouseID, productID string) error {
+	if _, err := t.GetProduct(ctx, productID); err != nil {
+		return err
+	}
+	_, err := t.GetWarehouse(ctx, warehouseID)
+	return err
+}
+
+func (t *sqlStoreTx) ListStockLevels(ctx context.Context, productID string) ([]models.StockLevel, error) {
+	query := `SELECT l.warehouse_id, l.quantity, l.reserved FROM stock_levels l
+		JOIN warehouses w ON w.id = l.warehouse_id
+		WHERE l.product_id = $1 ORDER BY w.priority, w.id`
+	rows, err := t.query(ctx, query, productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var levels []models.StockLevel
+	for rows.Next() {
+		var level models.StockLevel
+		if err := rows.Scan(&level.WarehouseID, &level.Quantity, &level.Reserved); err != nil {
+			return nil, err
+		}
+		levels = append(levels, level)
+	}
+	return levels, rows.Err()
+}
+
+// SearchProducts uses the products.search_vector index on Postgres, ranked
+// by ts_rank, and the products_search FTS5 table on SQLite, ranked by the
+// searchWeights of the fields each term matches.
+func (t *sqlStoreTx) SearchProducts(ctx context.Context, terms []string, limit int) ([]models.ProductMatch, error) {
+	var query string
+	var args []interface{}
+	if t.dialect == database.SQLite {
+		query, args = sqliteSearchQuery(terms, limit)
+	} else {
+		prefixes := make([]string, len(terms))
+		for i, term := range terms {
+			prefixes[i] = term + ":*"
+		}
+		query = `SELECT ` + productColumns + `, ts_rank(search_vector, query) AS rank
+			FROM products, to_tsquery('simple', $1) AS query
+			WHERE search_vector @@ query
+			ORDER BY rank DESC, id LIMIT $2`
+		args = []interface{}{strings.Join(prefixes, " & "), limit}
+	}
+
+	rows, err := t.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []models.ProductMatch
+	for rows.Next() {
+		var match models.ProductMatch
+		if err := scanProductInto(rows, &match.Product, &match.Rank); err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+	return matches, rows.Err()
+}
+
+// sqliteSearchQuery returns the SQLite query for SearchProducts. Rather
+// than bm25, each term adds the weight of every field it matches, found
+// with a column-filtered MATCH per field, so that ranks agree with the
+// in-memory store.
+func sqliteSearchQuery(terms []string, limit int) (string, []interface{}) {
+	prefixes := make([]string, len(terms))
+	args := []interface{}{nil}
+	var rank []string
+	for i, term := range terms {
+		prefixes[i] = term + "*"
+		for _, field := range searchWeights {
+			args = append(args, field.column+":"+term+"*")
+			rank = append(rank, fmt.Sprintf(`%g * (id IN (SELECT product_id FROM products_search WHERE products_search MATCH $%d))`, field.weight, len(args)))
+		}
+	}
+	args[0] = strings.Join(prefixes, " ")
+	args = append(args, limit)
+
+	query := `SELECT ` + productColumns + `, ` + strings.Join(rank, " + ") + ` AS rank
+		FROM products
+		WHERE id IN (SELECT product_id FROM products_search WHERE products_search MATCH $1)
+		ORDER BY rank DESC, id LIMIT $` + fmt.Sprint(len(args))
+	return query, args
+}
+
+// AppendMovement sets created_at from the application clock, truncated to
+// the microsecond precision of Postgres, so that the returned movement
+// matches what is read back.
+func (t *sqlStoreTx) AppendMovement(ctx context.Context, movement *models.StockMovement) error {
+	var reference sql.NullString
+	if movement.Reference != "" {
+		reference = sql.NullString{String: movement.Reference, Valid: true}
+	}
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	query := `INSERT INTO stock_movements (product_id, warehouse_id, delta, reason, reference, actor, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+	err := t.queryRow(ctx, query, movement.ProductID, movement.WarehouseID, movement.Delta, movement.Reason, reference, movement.Actor, t.timeArg(createdAt)).Scan(&movement.ID)
+	if err != nil {
+		return err
+	}
+	movement.CreatedAt = createdAt
+	return nil
+}
+
+func (t *sqlStoreTx) ListMovements(ctx context.Context, filter models.MovementFilter, page pageSpec) ([]models.StockMovement, error) {
+	var where conditions
+	if filter.ProductID != "" {
+		where.add(`product_id = $?`, filter.ProductID)
+	}
+	if filter.WarehouseID != "" {
+		where.add(`warehouse_id = $?`, filter.WarehouseID)
+	}
+	if filter.Reason != "" {
+		where.add(`reason = $?`, filter.Reason)
+	}
+	if !filter.From.IsZero() {
+		where.add(`created_at >= $?`, t.timeArg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where.add(`created_at < $?`, t.timeArg(filter.To))
+	}
+
+	query := `SELECT id, product_id, warehouse_id, delta, reason, COALESCE(reference, ''), actor, created_at
+		FROM stock_movements` + t.pageClauses(&where, page)
+	rows, err := t.query(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var movements []models.StockMovement
+	for rows.Next() {
+		var m models.StockMovement
+		if err := rows.Scan(&m.ID, &m.ProductID, &m.WarehouseID, &m.Delta, &m.Reason, &m.Reference, &m.Actor, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
+
+// StockAt groups the movements by warehouse, treating the sum of the
+// transfer movements, which is the stock that left a source but has not
+// reached its destination, as the stock in transit.
+func (t *sqlStoreTx) StockAt(ctx context.Context, productID string, at time.Time) ([]models.StockLevel, int, error) {
+	query := `SELECT m.warehouse_id, SUM(m.delta), SUM(CASE WHEN m.reason IN ($3, $4) THEN m.delta ELSE 0 END)
+		FROM stock_movements m
+		LEFT JOIN warehouses w ON w.id = m.warehouse_id
+		WHERE m.product_id = $1 AND m.created_at <= $2
+		GROUP BY m.warehouse_id, w.priority
+		ORDER BY COALESCE(w.priority, 0), m.warehouse_id`
+	rows, err := t.query(ctx, query, productID, t.timeArg(at), models.MovementReasonTransferOut, models.MovementReasonTransferIn)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var levels []models.StockLevel
+	inTransit := 0
+	for rows.Next() {
+		var level models.StockLevel
+		var transferred int
+		if err := rows.Scan(&level.WarehouseID, &level.Quantity, &transferred); err != nil {
+			return nil, 0, err
+		}
+		levels = append(levels, level)
+		inTransit -= transferred
+	}
+	return levels, inTransit, rows.Err()
+}
+
+func (t *sqlStoreTx) TotalStock(ctx context.Context) (int, error) {
+	var total int
+	err := t.queryRow(ctx, `SELECT COALESCE(SUM(stock), 0) FROM products`).Scan(&total)
+	return total, err
+}
+
+func (t *sqlStoreTx) InsertWarehouse(ctx context.Context, warehouse models.Warehouse) error {
+	query := `INSERT INTO warehouses (id, name, priority) VALUES ($1, $2, $3)`
+	_, err := t.exec(ctx, query, warehouse.ID, warehouse.Name, warehouse.Priority)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("warehouse %s %w", warehouse.ID, errDuplicate)
+	}
+	return err
+}
+
+func (t *sqlStoreTx) GetWarehouse(ctx context.Context, warehouseID string) (models.Warehouse, error) {
+	var warehouse models.Warehouse
+	query := `SELECT id, name, priority FROM warehouses WHERE id = $1`
+	err := t.queryRow(ctx, query, warehouseID).Scan(&warehouse.ID, &warehouse.Name, &warehouse.Priority)
+	if err == sql.ErrNoRows {
+		return models.Warehouse{}, fmt.Errorf("warehouse %s %w", warehouseID, errNotFound)
+	}
+	return warehouse, err
+}
+
+func (t *sqlStoreTx) ListWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	rows, err := t.query(ctx, `SELECT id, name, priority FROM warehouses ORDER BY priority, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var warehouses []models.Warehouse
+	for rows.Next() {
+		var warehouse models.Warehouse
+		if err := rows.Scan(&warehouse.ID, &warehouse.Name, &warehouse.Priority); err != nil {
+			return nil, err
+		}
+		warehouses = append(warehouses, warehouse)
+	}
+	return warehouses, rows.Err()
+}
+
+func (t *sqlStoreTx) InsertTransfer(ctx context.Context, transfer *models.Transfer) error {
+	query := `INSERT INTO transfers (source_id, destination_id, status) VALUES ($1, $2, $3) RETURNING id, created_at`
+	err := t.queryRow(ctx, query, transfer.SourceID, transfer.DestinationID, transfer.Status).Scan(&transfer.ID, &transfer.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	query = `INSERT INTO transfer_lines (transfer_id, product_id, quantity) VALUES ($1, $2, $3)`
+	for _, line := range transfer.Lines {
+		if _, err := t.exec(ctx, query, transfer.ID, line.ProductID, line.Quantity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) GetTransfer(ctx context.Context, transferID string) (models.Transfer, error) {
+	return t.getTransfer(ctx, transferID, "")
+}
+
+// LockTransfer locks the transfer's row on Postgres, like LockOrder.
+func (t *sqlStoreTx) LockTransfer(ctx context.Context, transferID string) (models.Transfer, error) {
+	if t.dialect == database.SQLite {
+		return t.getTransfer(ctx, transferID, "")
+	}
+	return t.getTransfer(ctx, transferID, " FOR UPDATE")
+}
+
+func (t *sqlStoreTx) getTransfer(ctx context.Context, transferID string, lock string) (models.Transfer, error) {
+	id, ok := parseID(transferID)
+	if !ok {
+		return models.Transfer{}, fmt.Errorf("transfer %s %w", transferID, errNotFound)
+	}
+	query := `SELECT ` + transferColumns + ` FROM transfers WHERE id = $1` + lock
+	transfer, err := scanTransfer(t.queryRow(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return models.Transfer{}, fmt.Errorf("transfer %s %w", transferID, errNotFound)
+	}
+	if err != nil {
+		return models.Transfer{}, err
+	}
+
+	transfers := []models.Transfer{transfer}
+	if err := t.loadLines(ctx, transfers, `WHERE transfer_id = $1`, transferID); err != nil {
+		return models.Transfer{}, err
+	}
+	return transfers[0], nil
+}
+
+func (t *sqlStoreTx) ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error) {
+	var where conditions
+	if filter.Status != "" {
+		where.add(`status = $?`, filter.Status)
+	}
+	if filter.WarehouseID != "" {
+		where.add(`(source_id = $? OR destination_id = $?)`, filter.WarehouseID, filter.WarehouseID)
+	}
+
+	query := `SELECT ` + transferColumns + ` FROM transfers` + where.clause() + ` ORDER BY id DESC`
+	rows, err := t.query(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.Transfer
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, transfer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(transfers) == 0 {
+		return transfers, nil
+	}
+	placeholders := make([]string, len(transfers))
+	ids := make([]interface{}, len(transfers))
+	for i, transfer := range transfers {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		ids[i] = transfer.ID
+	}
+	if err := t.loadLines(ctx, transfers, `WHERE transfer_id IN (`+strings.Join(placeholders, ", ")+`)`, ids...); err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// loadLines fills in the lines of transfers from the transfer_lines rows
+// selected by where.
+func (t *sqlStoreTx) loadLines(ctx context.Context, transfers []models.Transfer, where string, args ...interface{}) error {
+	byID := make(map[string]int, len(transfers))
+	for i, transfer := range transfers {
+		byID[transfer.ID] = i
+	}
+
+	query := `SELECT transfer_id, product_id, quantity FROM transfer_lines ` + where + ` ORDER BY transfer_id, product_id`
+	rows, err := t.query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transferID string
+		var line models.TransferLine
+		if err := rows.Scan(&transferID, &line.ProductID, &line.Quantity); err != nil {
+			return err
+		}
+		if i, ok := byID[transferID]; ok {
+			transfers[i].Lines = append(transfers[i].Lines, line)
+		}
+	}
+	return rows.Err()
+}
+
+func (t *sqlStoreTx) SetTransferStatus(ctx context.Context, transferID string, status string) error {
+	query := `UPDATE transfers SET status = $1 WHERE id = $2`
+	switch status {
+	case models.TransferStatusInTransit:
+		query = `UPDATE transfers SET status = $1, shipped_at = CURRENT_TIMESTAMP WHERE id = $2`
+	case models.TransferStatusReceived:
+		query = `UPDATE transfers SET status = $1, received_at = CURRENT_TIMESTAMP WHERE id = $2`
+	}
+	result, err := t.exec(ctx, query, status, transferID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("transfer %s %w", transferID, errNotFound)
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) InTransitStock(ctx context.Context, productID string) (int, error) {
+	query := `SELECT COALESCE(SUM(l.quantity), 0) FROM transfer_lines l
+		JOIN transfers t ON t.id = l.transfer_id
+		WHERE l.product_id = $1 AND t.status = $2`
+	var total int
+	err := t.queryRow(ctx, query, productID, models.TransferStatusInTransit).Scan(&total)
+	return total, err
+}
+
+func (t *sqlStoreTx) InsertReservation(ctx context.Context, reservation *models.Reservation) error {
+	query := `INSERT INTO reservations (product_id, warehouse_id, quantity, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	return t.queryRow(ctx, query, reservation.ProductID, reservation.WarehouseID, reservation.Quantity, reservation.Status, t.timeArg(reservation.ExpiresAt)).
+		Scan(&reservation.ID, &reservation.CreatedAt)
+}
+
+func (t *sqlStoreTx) GetReservation(ctx context.Context, reservationID string) (models.Reservation, error) {
+	return t.getReservation(ctx, reservationID, "")
+}
+
+// LockReservation locks the reservation's row on Postgres, like LockOrder.
+func (t *sqlStoreTx) LockReservation(ctx context.Context, reservationID string) (models.Reservation, error) {
+	if t.dialect == database.SQLite {
+		return t.getReservation(ctx, reservationID, "")
+	}
+	return t.getReservation(ctx, reservationID, " FOR UPDATE")
+}
+
+func (t *sqlStoreTx) getReservation(ctx context.Context, reservationID string, lock string) (models.Reservation, error) {
+	id, ok := parseID(reservationID)
+	if !ok {
+		return models.Reservation{}, fmt.Errorf("reservation %s %w", reservationID, errNotFound)
+	}
+	query := `SELECT ` + reservationColumns + ` FROM reservations WHERE id = $1` + lock
+	reservation, err := scanReservation(t.queryRow(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return models.Reservation{}, fmt.Errorf("reservation %s %w", reservationID, errNotFound)
+	}
+	return reservation, err
+}
+
+func (t *sqlStoreTx) ListReservations(ctx context.Context, filter models.ReservationFilter) ([]models.Reservation, error) {
+	var where conditions
+	if filter.ProductID != "" {
+		where.add(`product_id = $?`, filter.ProductID)
+	}
+	if filter.Status != "" {
+		where.add(`status = $?`, filter.Status)
+	}
+	if !filter.ExpiredBy.IsZero() {
+		where.add(`expires_at <= $?`, t.timeArg(filter.ExpiredBy))
+	}
+
+	query := `SELECT ` + reservationColumns + ` FROM reservations` + where.clause() + ` ORDER BY id`
+	rows, err := t.query(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []models.Reservation
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, rows.Err()
+}
+
+func (t *sqlStoreTx) SetReservationStatus(ctx context.Context, reservationID string, status string) error {
+	result, err := t.exec(ctx, `UPDATE reservations SET status = $1 WHERE id = $2`, status, reservationID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("reservation %s %w", reservationID, errNotFound)
+	}
+	return nil
+}
+
+// OpenStockAlert relies on the partial unique index on open alerts, so that
+// a concurrent transaction opening the same product's alert inserts nothing
+// rather than failing.
+func (t *sqlStoreTx) OpenStockAlert(ctx context.Context, alert *models.StockAlert) (bool, error) {
+	query := `INSERT INTO stock_alerts (product_id, stock, reorder_point, reorder_quantity) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (product_id) WHERE resolved_at IS NULL DO NOTHING
+		RETURNING id, created_at`
+	err := t.queryRow(ctx, query, alert.ProductID, alert.Stock, alert.ReorderPoint, alert.ReorderQuantity).Scan(&alert.ID, &alert.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (t *sqlStoreTx) ResolveStockAlert(ctx context.Context, productID string) error {
+	_, err := t.exec(ctx, `UPDATE stock_alerts SET resolved_at = CURRENT_TIMESTAMP WHERE product_id = $1 AND resolved_at IS NULL`, productID)
+	return err
+}
+
+func (t *sqlStoreTx) ListStockAlerts(ctx context.Context, filter models.StockAlertFilter) ([]models.StockAlert, error) {
+	var where conditions
+	if filter.ProductID != "" {
+		where.add(`product_id = $?`, filter.ProductID)
+	}
+	if filter.Open {
+		where.add(`resolved_at IS NULL`)
+	}
+	if filter.Unnotified {
+		where.add(`notified_at IS NULL`)
+	}
+
+	query := `SELECT ` + stockAlertColumns + ` FROM stock_alerts` + where.clause() + ` ORDER BY id`
+	rows, err := t.query(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.StockAlert
+	for rows.Next() {
+		alert, err := scanStockAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+func (t *sqlStoreTx) MarkStockAlertNotified(ctx context.Context, alertID string) (bool, error) {
+	result, err := t.exec(ctx, `UPDATE stock_alerts SET notified_at = CURRENT_TIMESTAMP WHERE id = $1 AND notified_at IS NULL`, alertID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (t *sqlStoreTx) InsertSupplier(ctx context.Context, supplier models.Supplier) error {
+	query := `INSERT INTO suppliers (id, name, email, phone, lead_time_days) VALUES ($1, $2, $3, $4, $5)`
+	_, err := t.exec(ctx, query, supplier.ID, supplier.Name, supplier.Email, supplier.Phone, supplier.LeadTimeDays)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("supplier %s %w", supplier.ID, errDuplicate)
+	}
+	return err
+}
+
+func (t *sqlStoreTx) GetSupplier(ctx context.Context, supplierID string) (models.Supplier, error) {
+	query := `SELECT ` + supplierColumns + ` FROM suppliers WHERE id = $1`
+	supplier, err := scanSupplier(t.queryRow(ctx, query, supplierID))
+	if err == sql.ErrNoRows {
+		return models.Supplier{}, fmt.Errorf("supplier %s %w", supplierID, errNotFound)
+	}
+	return supplier, err
+}
+
+func (t *sqlStoreTx) ListSuppliers(ctx context.Context) ([]models.Supplier, error) {
+	rows, err := t.query(ctx, `SELECT `+supplierColumns+` FROM suppliers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var suppliers []models.Supplier
+	for rows.Next() {
+		supplier, err := scanSupplier(rows)
+		if err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, rows.Err()
+}
+
+func (t *sqlStoreTx) UpdateSupplier(ctx context.Context, supplier models.Supplier) error {
+	query := `UPDATE suppliers SET name = $1, email = $2, phone = $3, lead_time_days = $4 WHERE id = $5`
+	result, err := t.exec(ctx, query, supplier.Name, supplier.Email, supplier.Phone, supplier.LeadTimeDays, supplier.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("supplier %s %w", supplier.ID, errNotFound)
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) DeleteSupplier(ctx context.Context, supplierID string) error {
+	result, err := t.exec(ctx, `DELETE FROM suppliers WHERE id = $1`, supplierID)
+	if isForeignKeyViolation(err) {
+		return fmt.Errorf("supplier %s %w", supplierID, errInUse)
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("supplier %s %w", supplierID, errNotFound)
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) InsertPurchaseOrder(ctx context.Context, po *models.PurchaseOrder) error {
+	query := `INSERT INTO purchase_orders (supplier_id, warehouse_id, status, expected_at)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at`
+	err := t.queryRow(ctx, query, po.SupplierID, po.WarehouseID, po.Status, t.nullTimeArg(po.ExpectedAt)).Scan(&po.ID, &po.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return t.insertPurchaseOrderLines(ctx, po.ID, po.Lines)
+}
+
+// insertPurchaseOrderLines adds the lines of a purchase order.
+func (t *sqlStoreTx) insertPurchaseOrderLines(ctx context.Context, poID string, lines []models.PurchaseOrderLine) error {
+	query := `INSERT INTO purchase_order_lines (purchase_order_id, product_id, quantity, received, unit_cost)
+		VALUES ($1, $2, $3, $4, $5)`
+	for _, line := range lines {
+		if _, err := t.exec(ctx, query, poID, line.ProductID, line.Quantity, line.Received, line.UnitCost); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) GetPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error) {
+	return t.getPurchaseOrder(ctx, poID, "")
+}
+
+// LockPurchaseOrder locks the purchase order's row on Postgres, like
+// LockOrder.
+func (t *sqlStoreTx) LockPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error) {
+	if t.dialect == database.SQLite {
+		return t.getPurchaseOrder(ctx, poID, "")
+	}
+	return t.getPurchaseOrder(ctx, poID, " FOR UPDATE")
+}
+
+func (t *sqlStoreTx) getPurchaseOrder(ctx context.Context, poID string, lock string) (models.PurchaseOrder, error) {
+	id, ok := parseID(poID)
+	if !ok {
+		return models.PurchaseOrder{}, fmt.Errorf("purchase order %s %w", poID, errNotFound)
+	}
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders WHERE id = $1` + lock
+	po, err := scanPurchaseOrder(t.queryRow(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return models.PurchaseOrder{}, fmt.Errorf("purchase order %s %w", poID, errNotFound)
+	}
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+
+	pos := []models.PurchaseOrder{po}
+	if err := t.loadPurchaseOrderLines(ctx, pos, `WHERE purchase_order_id = $1`, poID); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return pos[0], nil
+}
+
+func (t *sqlStoreTx) ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error) {
+	var where conditions
+	if filter.Status != "" {
+		where.add(`status = $?`, filter.Status)
+	}
+	if filter.SupplierID != "" {
+		where.add(`supplier_id = $?`, filter.SupplierID)
+	}
+
+	query := `SELECT ` + purchaseOrderColumns + ` FROM purchase_orders` + where.clause() + ` ORDER BY id DESC`
+	rows, err := t.query(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pos []models.PurchaseOrder
+	for rows.Next() {
+		po, err := scanPurchaseOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		pos = append(pos, po)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(pos) == 0 {
+		return pos, nil
+	}
+	placeholders := make([]string, len(pos))
+	ids := make([]interface{}, len(pos))
+	for i, po := range pos {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		ids[i] = po.ID
+	}
+	if err := t.loadPurchaseOrderLines(ctx, pos, `WHERE purchase_order_id IN (`+strings.Join(placeholders, ", ")+`)`, ids...); err != nil {
+		return nil, err
+	}
+	return pos, nil
+}
+
+// loadPurchaseOrderLines fills in the lines of pos from the
+// purchase_order_lines rows selected by where.
+func (t *sqlStoreTx) loadPurchaseOrderLines(ctx context.Context, pos []models.PurchaseOrder, where string, args ...interface{}) error {
+	byID := make(map[string]int, len(pos))
+	for i, po := range pos {
+		byID[po.ID] = i
+	}
+
+	query := `SELECT purchase_order_id, product_id, quantity, received, unit_cost FROM purchase_order_lines ` +
+		where + ` ORDER BY purchase_order_id, product_id`
+	rows, err := t.query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var poID string
+		var line models.PurchaseOrderLine
+		if err := rows.Scan(&poID, &line.ProductID, &line.Quantity, &line.Received, &line.UnitCost); err != nil {
+			return err
+		}
+		line.OverReceived = max(line.Received-line.Quantity, 0)
+		if i, ok := byID[poID]; ok {
+			pos[i].Lines = append(pos[i].Lines, line)
+		}
+	}
+	return rows.Err()
+}
+
+// UpdatePurchaseOrder rewrites the purchase order's row and replaces its
+// lines.
+func (t *sqlStoreTx) UpdatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) error {
+	query := `UPDATE purchase_orders SET supplier_id = $1, warehouse_id = $2, expected_at = $3 WHERE id = $4`
+	result, err := t.exec(ctx, query, po.SupplierID, po.WarehouseID, t.nullTimeArg(po.ExpectedAt), po.ID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("purchase order %s %w", po.ID, errNotFound)
+	}
+	if _, err := t.exec(ctx, `DELETE FROM purchase_order_lines WHERE purchase_order_id = $1`, po.ID); err != nil {
+		return err
+	}
+	return t.insertPurchaseOrderLines(ctx, po.ID, po.Lines)
+}
+
+func (t *sqlStoreTx) SetPurchaseOrderStatus(ctx context.Context, poID string, status string) error {
+	query := `UPDATE purchase_orders SET status = $1 WHERE id = $2`
+	switch status {
+	case models.PurchaseOrderStatusSent:
+		query = `UPDATE purchase_orders SET status = $1, sent_at = CURRENT_TIMESTAMP WHERE id = $2`
+	case models.PurchaseOrderStatusClosed:
+		query = `UPDATE purchase_orders SET status = $1, closed_at = CURRENT_TIMESTAMP WHERE id = $2`
+	}
+	result, err := t.exec(ctx, query, status, poID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("purchase order %s %w", poID, errNotFound)
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) AddReceived(ctx context.Context, poID, productID string, quantity int) error {
+	query := `UPDATE purchase_order_lines SET received = received + $1 WHERE purchase_order_id = $2 AND product_id = $3`
+	result, err := t.exec(ctx, query, quantity, poID, productID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("purchase order %s line %s %w", poID, productID, errNotFound)
+	}
+	return nil
+}
+
+// DeletePurchaseOrder deletes the purchase order's row; its lines cascade.
+func (t *sqlStoreTx) DeletePurchaseOrder(ctx context.Context, poID string) error {
+	result, err := t.exec(ctx, `DELETE FROM purchase_orders WHERE id = $1`, poID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("purchase order %s %w", poID, errNotFound)
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) OnOrder(ctx context.Context) (map[string]int, error) {
+	query := `SELECT l.product_id, SUM(l.quantity - l.received)
+		FROM purchase_order_lines l JOIN purchase_orders p ON p.id = l.purchase_order_id
+		WHERE p.status IN ($1, $2, $3) AND l.received < l.quantity
+		GROUP BY l.product_id`
+	return t.unitsByProduct(ctx, query, models.PurchaseOrderStatusDraft, models.PurchaseOrderStatusSent, models.PurchaseOrderStatusPartiallyReceived)
+}
+
+func (t *sqlStoreTx) InsertLot(ctx context.Context, lot *models.Lot) error {
+	query := `INSERT INTO lots (product_id, warehouse_id, lot_number, quantity, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, created_at`
+	err := t.queryRow(ctx, query, lot.ProductID, lot.WarehouseID, lot.LotNumber, lot.Quantity, lot.Status, t.timeArg(lot.ExpiresAt)).
+		Scan(&lot.ID, &lot.CreatedAt)
+	if isUniqueViolation(err) {
+		return fmt.Errorf("lot %s of product %s %w", lot.LotNumber, lot.ProductID, errDuplicate)
+	}
+	return err
+}
+
+func (t *sqlStoreTx) GetLot(ctx context.Context, lotID string) (models.Lot, error) {
+	return t.getLot(ctx, lotID, "")
+}
+
+// LockLot locks the lot's row on Postgres, like LockOrder.
+func (t *sqlStoreTx) LockLot(ctx context.Context, lotID string) (models.Lot, error) {
+	if t.dialect == database.SQLite {
+		return t.getLot(ctx, lotID, "")
+	}
+	return t.getLot(ctx, lotID, " FOR UPDATE")
+}
+
+func (t *sqlStoreTx) getLot(ctx context.Context, lotID string, lock string) (models.Lot, error) {
+	id, ok := parseID(lotID)
+	if !ok {
+		return models.Lot{}, fmt.Errorf("lot %s %w", lotID, errNotFound)
+	}
+	query := `SELECT ` + lotColumns + ` FROM lots WHERE id = $1` + lock
+	lot, err := scanLot(t.queryRow(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return models.Lot{}, fmt.Errorf("lot %s %w", lotID, errNotFound)
+	}
+	return lot, err
+}
+
+func (t *sqlStoreTx) ListLots(ctx context.Context, filter models.LotFilter) ([]models.Lot, error) {
+	var where conditions
+	if filter.ProductID != "" {
+		where.add(`product_id = $?`, filter.ProductID)
+	}
+	if filter.WarehouseID != "" {
+		where.add(`warehouse_id = $?`, filter.WarehouseID)
+	}
+	if filter.Status != "" {
+		where.add(`status = $?`, filter.Status)
+	}
+	if filter.LotNumber != "" {
+		where.add(`lot_number = $?`, filter.LotNumber)
+	}
+	if !filter.ExpiresBy.IsZero() {
+		where.add(`expires_at <= $?`, t.timeArg(filter.ExpiresBy))
+	}
+	return t.queryLots(ctx, `SELECT `+lotColumns+` FROM lots`+where.clause()+` ORDER BY expires_at, id`, where.args...)
+}
+
+// LockActiveLots locks the lots' rows on Postgres, like LockOrder.
+func (t *sqlStoreTx) LockActiveLots(ctx context.Context, warehouseID, productID string) ([]models.Lot, error) {
+	query := `SELECT ` + lotColumns + ` FROM lots
+		WHERE warehouse_id = $1 AND product_id = $2 AND status = $3 AND quantity > 0
+		ORDER BY expires_at, id`
+	if t.dialect != database.SQLite {
+		query += ` FOR UPDATE`
+	}
+	return t.queryLots(ctx, query, warehouseID, productID, models.LotStatusActive)
+}
+
+func (t *sqlStoreTx) queryLots(ctx context.Context, query string, args ...interface{}) ([]models.Lot, error) {
+	rows, err := t.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.Lot
+	for rows.Next() {
+		lot, err := scanLot(rows)
+		if err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+// AddLotQuantity is a conditional update, like a decrease in AdjustStock.
+func (t *sqlStoreTx) AddLotQuantity(ctx context.Context, lotID string, delta int) error {
+	result, err := t.exec(ctx, `UPDATE lots SET quantity = quantity + $1 WHERE id = $2 AND quantity + $1 >= 0`, delta, lotID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		if _, err := t.GetLot(ctx, lotID); err != nil {
+			return err
+		}
+		return fmt.Errorf("lot %s: %w", lotID, errInsufficientStock)
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) SetLotStatus(ctx context.Context, lotID string, status string) error {
+	query := `UPDATE lots SET status = $1 WHERE id = $2`
+	if status == models.LotStatusQuarantined {
+		query = `UPDATE lots SET status = $1, quarantined_at = CURRENT_TIMESTAMP WHERE id = $2`
+	}
+	result, err := t.exec(ctx, query, status, lotID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("lot %s %w", lotID, errNotFound)
+	}
+	return nil
+}
+
+// InsertLotAllocation adds to the allocation of the line from the lot if
+// there already is one.
+func (t *sqlStoreTx) InsertLotAllocation(ctx context.Context, allocation models.LotAllocation) error {
+	query := `INSERT INTO order_item_lots (order_item_id, lot_id, quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (order_item_id, lot_id) DO UPDATE SET quantity = order_item_lots.quantity + excluded.quantity`
+	_, err := t.exec(ctx, query, allocation.OrderItemID, allocation.LotID, allocation.Quantity)
+	return err
+}
+
+func (t *sqlStoreTx) ListLotAllocations(ctx context.Context, orderID string) ([]models.LotAllocation, error) {
+	query := `SELECT a.order_item_id, l.id, l.lot_number, l.product_id, l.warehouse_id, l.expires_at, a.quantity
+		FROM order_item_lots a
+		JOIN order_items i ON i.id = a.order_item_id
+		JOIN lots l ON l.id = a.lot_id
+		WHERE i.order_id = $1
+		ORDER BY a.order_item_id, l.expires_at, l.id`
+	rows, err := t.query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var allocations []models.LotAllocation
+	for rows.Next() {
+		var a models.LotAllocation
+		if err := rows.Scan(&a.OrderItemID, &a.LotID, &a.LotNumber, &a.ProductID, &a.WarehouseID, &a.ExpiresAt, &a.Quantity); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, rows.Err()
+}
+
+// InsertTransferLot adds to the quantity the line took from the lot if
+// there already is one, like InsertLotAllocation.
+func (t *sqlStoreTx) InsertTransferLot(ctx context.Context, lot models.TransferLot) error {
+	query := `INSERT INTO transfer_lots (transfer_id, lot_id, quantity) VALUES ($1, $2, $3)
+		ON CONFLICT (transfer_id, lot_id) DO UPDATE SET quantity = transfer_lots.quantity + excluded.quantity`
+	_, err := t.exec(ctx, query, lot.TransferID, lot.LotID, lot.Quantity)
+	return err
+}
+
+func (t *sqlStoreTx) ListTransferLots(ctx context.Context, transferID string) ([]models.TransferLot, error) {
+	query := `SELECT a.transfer_id, l.id, l.lot_number, l.product_id, l.expires_at, a.quantity
+		FROM transfer_lots a
+		JOIN lots l ON l.id = a.lot_id
+		WHERE a.transfer_id = $1
+		ORDER BY l.product_id, l.expires_at, l.id`
+	rows, err := t.query(ctx, query, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lots []models.TransferLot
+	for rows.Next() {
+		var lot models.TransferLot
+		if err := rows.Scan(&lot.TransferID, &lot.LotID, &lot.LotNumber, &lot.ProductID, &lot.ExpiresAt, &lot.Quantity); err != nil {
+			return nil, err
+		}
+		lots = append(lots, lot)
+	}
+	return lots, rows.Err()
+}
+
+func (t *sqlStoreTx) InsertOrder(ctx context.Context, order *models.Order) error {
+	var warehouseID sql.NullString
+	if order.WarehouseID != "" {
+		warehouseID = sql.NullString{String: order.WarehouseID, Valid: true}
+	}
+	query := `INSERT INTO orders (warehouse_id, total, status) VALUES ($1, $2, $3) RETURNING id, created_at`
+	if err := t.queryRow(ctx, query, warehouseID, order.Total, order.Status).Scan(&order.ID, &order.CreatedAt); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO order_items (order_id, product_id, warehouse_id, quantity, unit_price, line_total)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	for i := range order.Items {
+		item := &order.Items[i]
+		err := t.queryRow(ctx, query, order.ID, item.ProductID, item.WarehouseID, item.Quantity, item.UnitPrice, item.LineTotal).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) GetOrder(ctx context.Context, orderID string) (models.Order, error) {
+	return t.getOrder(ctx, orderID, "")
+}
+
+// LockOrder locks the order's row on Postgres. SQLite transactions take the
+// database write lock when they begin, so they are already serialized.
+func (t *sqlStoreTx) LockOrder(ctx context.Context, orderID string) (models.Order, error) {
+	if t.dialect == database.SQLite {
+		return t.getOrder(ctx, orderID, "")
+	}
+	return t.getOrder(ctx, orderID, " FOR UPDATE")
+}
+
+func (t *sqlStoreTx) getOrder(ctx context.Context, orderID string, lock string) (models.Order, error) {
+	id, ok := parseID(orderID)
+	if !ok {
+		return models.Order{}, fmt.Errorf("order %s %w", orderID, errNotFound)
+	}
+	var order models.Order
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = $1` + lock
+	err := t.queryRow(ctx, query, id).Scan(&order.ID, &order.WarehouseID, &order.Total, &order.Status, &order.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.Order{}, fmt.Errorf("order %s %w", orderID, errNotFound)
+	}
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	orders := []models.Order{order}
+	if err := t.loadItems(ctx, orders, `WHERE order_id = $1`, orderID); err != nil {
+		return models.Order{}, err
+	}
+	return orders[0], nil
+}
+
+func (t *sqlStoreTx) ListOrders(ctx context.Context, filter models.OrderFilter, page pageSpec) ([]models.Order, error) {
+	var where conditions
+	if filter.Status != "" {
+		where.add(`status = $?`, filter.Status)
+	}
+	if filter.ProductID != "" {
+		where.add(`EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id AND order_items.product_id = $?)`, filter.ProductID)
+	}
+	if !filter.From.IsZero() {
+		where.add(`created_at >= $?`, t.timeArg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		where.add(`created_at < $?`, t.timeArg(filter.To))
+	}
+
+	query := `SELECT ` + orderColumns + ` FROM orders` + t.pageClauses(&where, page)
+	rows, err := t.query(ctx, query, where.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		if err := rows.Scan(&order.ID, &order.WarehouseID, &order.Total, &order.Status, &order.CreatedAt); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if len(orders) == 0 {
+		return orders, nil
+	}
+	placeholders := make([]string, len(orders))
+	ids := make([]interface{}, len(orders))
+	for i, order := range orders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		ids[i] = order.ID
+	}
+	if err := t.loadItems(ctx, orders, `WHERE order_id IN (`+strings.Join(placeholders, ", ")+`)`, ids...); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// pageClauses adds the keyset condition of page to where and returns the
+// WHERE, ORDER BY and LIMIT clauses that read the page. page.field is one of
+// the fields of productPager, orderPager or movementPager, which are also
+// column names.
+func (t *sqlStoreTx) pageClauses(where *conditions, page pageSpec) string {
+	direction, after := "ASC", ">"
+	if page.desc {
+		direction, after = "DESC", "<"
+	}
+	if page.after != nil {
+		where.add(fmt.Sprintf(`(%s, id) %s ($?, $?)`, page.field, after), t.arg(page.after.value), t.arg(page.after.id))
+	}
+	return where.clause() + fmt.Sprintf(` ORDER BY %s %s, id %s LIMIT %d`, page.field, direction, direction, page.limit)
+}
+
+// arg converts a value for use as a query argument.
+func (t *sqlStoreTx) arg(v interface{}) interface{} {
+	if tm, ok := v.(time.Time); ok {
+		return t.timeArg(tm)
+	}
+	return v
+}
+
+// timeArg converts a time for comparison with a TIMESTAMP column. SQLite
+// compares timestamps as text, so times are formatted like the
+// CURRENT_TIMESTAMP defaults they are compared with.
+func (t *sqlStoreTx) timeArg(tm time.Time) interface{} {
+	if t.dialect == database.SQLite {
+		return tm.UTC().Format("2006-01-02 15:04:05.999999999")
+	}
+	return tm.UTC()
+}
+
+// nullTimeArg is timeArg for a nullable TIMESTAMP column.
+func (t *sqlStoreTx) nullTimeArg(tm *time.Time) interface{} {
+	if tm == nil {
+		return nil
+	}
+	return t.timeArg(*tm)
+}
+
+// conditions collects the conditions of a WHERE clause and their arguments.
+type conditions struct {
+	clauses []string
+	args    []interface{}
+}
+
+// add adds a condition, numbering its $? placeholders after those of the
+// conditions before it and binding them to args in order.
+func (c *conditions) add(condition string, args ...interface{}) {
+	for _, arg := range args {
+		c.args = append(c.args, arg)
+		condition = strings.Replace(condition, "$?", fmt.Sprintf("$%d", len(c.args)), 1)
+	}
+	c.clauses = append(c.clauses, condition)
+}
+
+// clause returns the WHERE clause, or an empty string if there are no
+// conditions.
+func (c *conditions) clause() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return ` WHERE ` + strings.Join(c.clauses, " AND ")
+}
+
+// escapeLike escapes the LIKE wildcards in s with backslashes.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// loadItems fills in the items of orders from the order_items rows selected
+// by where.
+func (t *sqlStoreTx) loadItems(ctx context.Context, orders []models.Order, where string, args ...interface{}) error {
+	byID := make(map[string]int, len(orders))
+	for i, order := range orders {
+		byID[order.ID] = i
+	}
+
+	query := `SELECT id, order_id, product_id, warehouse_id, quantity, unit_price, line_total FROM order_items ` + where + ` ORDER BY order_id, id`
+	rows, err := t.query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderID string
+		var item models.OrderItem
+		if err := rows.Scan(&item.ID, &orderID, &item.ProductID, &item.WarehouseID, &item.Quantity, &item.UnitPrice, &item.LineTotal); err != nil {
+			return err
+		}
+		if i, ok := byID[orderID]; ok {
+			orders[i].Items = append(orders[i].Items, item)
+		}
+	}
+	return rows.Err()
+}
+
+func (t *sqlStoreTx) SetOrderStatus(ctx context.Context, orderID string, status string) error {
+	result, err := t.exec(ctx, `UPDATE orders SET status = $1 WHERE id = $2`, status, orderID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("order %s %w", orderID, errNotFound)
+	}
+	return nil
+}
+
+func (t *sqlStoreTx) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	rows, err := t.query(ctx, `SELECT status, COUNT(*) FROM orders GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+	}
+	return counts, rows.Err()
+}
+
+func (t *sqlStoreTx) UnitsOrdered(ctx context.Context, since time.Time) (map[string]int, error) {
+	query := `SELECT i.product_id, SUM(i.quantity)
+		FROM order_items i JOIN orders o ON o.id = i.order_id
+		WHERE o.created_at >= $1 AND o.status NOT IN ($2, $3)
+		GROUP BY i.product_id`
+	return t.unitsByProduct(ctx, query, t.timeArg(since), models.OrderStatusCancelled, models.OrderStatusReturned)
+}
+
+// unitsByProduct runs a query selecting product IDs and unit counts and
+// collects its rows into a map.
+func (t *sqlStoreTx) unitsByProduct(ctx context.Context, query string, args ...interface{}) (map[string]int, error) {
+	rows, err := t.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := make(map[string]int)
+	for rows.Next() {
+		var productID string
+		var count int
+		if err := rows.Scan(&productID, &count); err != nil {
+			return nil, err
+		}
+		units[productID] = count
+	}
+	return units, rows.Err()
+}
+
+func (t *sqlStoreTx) AppendStatusChange(ctx context.Context, change models.OrderStatusChange) error {
+	var from sql.NullString
+	if change.From != "" {
+		from = sql.NullString{String: change.From, Valid: true}
+	}
+	query := `INSERT INTO order_status_history (order_id, from_status, to_status, actor) VALUES ($1, $2, $3, $4)`
+	_, err := t.exec(ctx, query, change.OrderID, from, change.To, change.Actor)
+	return err
+}
+
+func (t *sqlStoreTx) ListStatusChanges(ctx context.Context, orderID string) ([]models.OrderStatusChange, error) {
+	query := `SELECT order_id, COALESCE(from_status, ''), to_status, actor, changed_at
+		FROM order_status_history WHERE order_id = $1 ORDER BY changed_at, id`
+	rows, err := t.query(ctx, query, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var timeline []models.OrderStatusChange
+	for rows.Next() {
+		var change models.OrderStatusChange
+		if err := rows.Scan(&change.OrderID, &change.From, &change.To, &change.Actor, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		timeline = append(timeline, change)
+	}
+	return timeline, rows.Err()
+}
+
+// parseID parses the ID of a row keyed by a SERIAL column. An ID that is
+// not an integer names no row, so ok is false rather than the query failing
+// on Postgres with an invalid input error.
+func parseID(id string) (n int64, ok bool) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	return n, err == nil
+}
+
+// isUniqueViolation reports whether err is a Postgres or SQLite unique or
+// primary key constraint violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	return false
+}
+
+// isForeignKeyViolation reports whether err is a Postgres or SQLite foreign
+// key constraint violation.
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23503"
+	}
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+	}
+	return false
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// orderColumns lists the orders columns that order queries scan, in order.
+const orderColumns = `id, COALESCE(warehouse_id, ''), total, status, created_at`
+
+// transferColumns lists the transfers columns that scanTransfer reads, in
+// order.
+const transferColumns = `id, source_id, destination_id, status, created_at, shipped_at, received_at`
+
+func scanTransfer(row rowScanner) (models.Transfer, error) {
+	var transfer models.Transfer
+	var shippedAt, receivedAt sql.NullTime
+	err := row.Scan(&transfer.ID, &transfer.SourceID, &transfer.DestinationID, &transfer.Status, &transfer.CreatedAt, &shippedAt, &receivedAt)
+	if shippedAt.Valid {
+		transfer.ShippedAt = &shippedAt.Time
+	}
+	if receivedAt.Valid {
+		transfer.ReceivedAt = &receivedAt.Time
+	}
+	return transfer, err
+}
+
+// supplierColumns lists the suppliers columns that scanSupplier reads, in
+// order.
+const supplierColumns = `id, name, email, phone, lead_time_days`
+
+func scanSupplier(row rowScanner) (models.Supplier, error) {
+	var s models.Supplier
+	err := row.Scan(&s.ID, &s.Name, &s.Email, &s.Phone, &s.LeadTimeDays)
+	return s, err
+}
+
+// purchaseOrderColumns lists the purchase_orders columns that
+// scanPurchaseOrder reads, in order.
+const purchaseOrderColumns = `id, supplier_id, warehouse_id, status, expected_at, created_at, sent_at, closed_at`
+
+func scanPurchaseOrder(row rowScanner) (models.PurchaseOrder, error) {
+	var po models.PurchaseOrder
+	var expectedAt, sentAt, closedAt sql.NullTime
+	err := row.Scan(&po.ID, &po.SupplierID, &po.WarehouseID, &po.Status, &expectedAt, &po.CreatedAt, &sentAt, &closedAt)
+	if expectedAt.Valid {
+		po.ExpectedAt = &expectedAt.Time
+	}
+	if sentAt.Valid {
+		po.SentAt = &sentAt.Time
+	}
+	if closedAt.Valid {
+		po.ClosedAt = &closedAt.Time
+	}
+	return po, err
+}
+
+// reservationColumns lists the reservations columns that scanReservation
+// reads, in order.
+const reservationColumns = `id, product_id, warehouse_id, quantity, status, expires_at, created_at`
+
+func scanReservation(row rowScanner) (models.Reservation, error) {
+	var r models.Reservation
+	err := row.Scan(&r.ID, &r.ProductID, &r.WarehouseID, &r.Quantity, &r.Status, &r.ExpiresAt, &r.CreatedAt)
+	return r, err
+}
+
+// lotColumns lists the lots columns that scanLot reads, in order.
+const lotColumns = `id, product_id, warehouse_id, lot_number, quantity, status, expires_at, created_at, quarantined_at`
+
+func scanLot(row rowScanner) (models.Lot, error) {
+	var lot models.Lot
+	var quarantinedAt sql.NullTime
+	err := row.Scan(&lot.ID, &lot.ProductID, &lot.WarehouseID, &lot.LotNumber, &lot.Quantity, &lot.Status, &lot.ExpiresAt, &lot.CreatedAt, &quarantinedAt)
+	if quarantinedAt.Valid {
+		lot.QuarantinedAt = &quarantinedAt.Time
+	}
+	return lot, err
+}
+
+// stockAlertColumns lists the stock_alerts columns that scanStockAlert
+// reads, in order.
+const stockAlertColumns = `id, product_id, stock, reorder_point, reorder_quantity, created_at, notified_at, resolved_at`
+
+func scanStockAlert(row rowScanner) (models.StockAlert, error) {
+	var alert models.StockAlert
+	var notifiedAt, resolvedAt sql.NullTime
+	err := row.Scan(&alert.ID, &alert.ProductID, &alert.Stock, &alert.ReorderPoint, &alert.ReorderQuantity, &alert.CreatedAt, &notifiedAt, &resolvedAt)
+	if notifiedAt.Valid {
+		alert.NotifiedAt = &notifiedAt.Time
+	}
+	if resolvedAt.Valid {
+		alert.ResolvedAt = &resolvedAt.Time
+	}
+	return alert, err
+}
+
+// productColumns lists the products columns that scanProduct reads, in
+// order.
+const productColumns = `id, name, description, tags, stock, price, reorder_point, reorder_quantity, COALESCE(supplier_id, ''), safety_stock`
+
+func scanProduct(row rowScanner) (models.Product, error) {
+	var product models.Product
+	err := scanProductInto(row, &product)
+	return product, err
+}
+
+// scanProductInto scans the productColumns into product, followed by any
+// further columns into extra.
+func scanProductInto(row rowScanner, product *models.Product, extra ...interface{}) error {
+	var tags []byte
+	dest := append([]interface{}{&product.ID, &product.Name, &product.Description, &tags, &product.Stock, &product.Price, &product.ReorderPoint, &product.ReorderQuantity,
+		&product.SupplierID, &product.SafetyStock}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+	product.Tags = nil
+	if err := json.Unmarshal(tags, &product.Tags); err != nil {
+		return fmt.Errorf("product %s has malformed tags: %w", product.ID, err)
+	}
+	if len(product.Tags) == 0 {
+		product.Tags = nil
+	}
+	return nil
+}
+
+// tagsArg encodes product tags for the JSON tags column.
+func tagsArg(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	encoded, _ := json.Marshal(tags)
+	return string(encoded)
+}
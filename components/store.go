@@ -0,0 +1,273 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"service-weaver-app/models"
+)
+
+var (
+	// errNotFound is wrapped by StoreTx methods when the requested record
+	// does not exist.
+	errNotFound = errors.New("not found")
+	// errInsufficientStock is wrapped by StoreTx.AdjustStock when the
+	// adjustment would leave a product with negative stock.
+	errInsufficientStock = errors.New("insufficient stock")
+	// errDuplicate is wrapped by StoreTx insert methods when a record with
+	// the same key already exists.
+	errDuplicate = errors.New("already exists")
+	// errInUse is wrapped by StoreTx delete methods when other records still
+	// refer to the record.
+	errInUse = errors.New("is in use")
+)
+
+// Store persists the products and orders behind InventoryManagement and
+// OrderProcessing. SQLStore keeps them in a database and MemoryStore keeps
+// them in memory; both must behave identically.
+type Store interface {
+	// InTx runs fn in a transaction, committing it if fn returns nil and
+	// rolling it back otherwise. If ctx already carries a transaction of
+	// this store, fn joins it instead, so components can call each other
+	// inside a single unit of work.
+	InTx(ctx context.Context, fn func(ctx context.Context, tx StoreTx) error) error
+}
+
+// StoreTx holds the operations available inside a Store transaction.
+type StoreTx interface {
+	// InsertProduct adds a new product, holding its Stock at the default
+	// warehouse, and wraps errDuplicate if its ID is taken.
+	InsertProduct(ctx context.Context, product models.Product) error
+	// GetProduct returns a product, wrapping errNotFound if it does not
+	// exist.
+	GetProduct(ctx context.Context, productID string) (models.Product, error)
+	// ListProducts returns the page of products matching filter that page
+	// selects, in the order it reads them.
+	ListProducts(ctx context.Context, filter models.ProductFilter, page pageSpec) ([]models.Product, error)
+	// SearchProducts returns up to limit products that have, for every
+	// term, a word starting with it in their name, tags or description,
+	// highest rank first and then by ID. Terms are lower-case letters and
+	// digits.
+	SearchProducts(ctx context.Context, terms []string, limit int) ([]models.ProductMatch, error)
+	// UpdateProduct applies update to a product and returns the result,
+	// wrapping errNotFound if it does not exist. update.Stock is ignored;
+	// stock only changes through AdjustStock.
+	UpdateProduct(ctx context.Context, productID string, update models.ProductUpdate) (models.Product, error)
+	// DeleteProduct removes a product. It wraps errNotFound if the product
+	// does not exist and errInUse if any order, transfer or purchase order
+	// refers to it.
+	DeleteProduct(ctx context.Context, productID string) error
+	// AdjustStock adds delta to a product's stock at a warehouse, and to its
+	// total Stock, and returns the updated product. It wraps errNotFound if
+	// the product or warehouse does not exist and errInsufficientStock if the
+	// warehouse's stock would fall below its reserved stock, in which case
+	// nothing changes.
+	AdjustStock(ctx context.Context, warehouseID, productID string, delta int) (models.Product, error)
+	// AdjustReserved adds delta to the reserved stock of a product at a
+	// warehouse. It wraps errNotFound if the product or warehouse does not
+	// exist and errInsufficientStock if the reserved stock would exceed the
+	// stock or become negative, in which case nothing changes.
+	AdjustReserved(ctx context.Context, warehouseID, productID string, delta int) error
+	// ListStockLevels returns a product's stock and reserved stock at every
+	// warehouse that has held it, in warehouse priority order. Available is
+	// left zero.
+	ListStockLevels(ctx context.Context, productID string) ([]models.StockLevel, error)
+	// AppendMovement adds an entry to the stock ledger, filling in its ID
+	// and setting its CreatedAt to the current time.
+	AppendMovement(ctx context.Context, movement *models.StockMovement) error
+	// ListMovements returns the page of stock movements matching filter that
+	// page selects, in the order it reads them.
+	ListMovements(ctx context.Context, filter models.MovementFilter, page pageSpec) ([]models.StockMovement, error)
+	// StockAt sums the stock movements of a product made at or before at.
+	// It returns the stock at every warehouse that had a movement, in
+	// warehouse priority order, and the stock in transit between them.
+	StockAt(ctx context.Context, productID string, at time.Time) ([]models.StockLevel, int, error)
+	// TotalStock returns the sum of every product's stock.
+	TotalStock(ctx context.Context) (int, error)
+
+	// InsertWarehouse adds a new warehouse, wrapping errDuplicate if its ID
+	// is taken.
+	InsertWarehouse(ctx context.Context, warehouse models.Warehouse) error
+	// GetWarehouse returns a warehouse, wrapping errNotFound if it does not
+	// exist.
+	GetWarehouse(ctx context.Context, warehouseID string) (models.Warehouse, error)
+	// ListWarehouses returns every warehouse in priority order, then by ID.
+	ListWarehouses(ctx context.Context) ([]models.Warehouse, error)
+
+	// InsertTransfer adds a transfer and its lines, filling in its ID and
+	// creation time.
+	InsertTransfer(ctx context.Context, transfer *models.Transfer) error
+	// GetTransfer returns a transfer with its lines, wrapping errNotFound if
+	// it does not exist.
+	GetTransfer(ctx context.Context, transferID string) (models.Transfer, error)
+	// LockTransfer is GetTransfer, additionally preventing concurrent
+	// transactions from changing the transfer until this one ends.
+	LockTransfer(ctx context.Context, transferID string) (models.Transfer, error)
+	// ListTransfers returns the transfers matching filter with their lines,
+	// newest first.
+	ListTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error)
+	// SetTransferStatus changes a transfer's status, setting its ShippedAt
+	// or ReceivedAt to the current time when it becomes InTransit or
+	// Received.
+	SetTransferStatus(ctx context.Context, transferID string, status string) error
+	// InTransitStock returns the quantity of a product in transfers that are
+	// InTransit.
+	InTransitStock(ctx context.Context, productID string) (int, error)
+
+	// InsertReservation adds a reservation, filling in its ID and creation
+	// time. It does not change the reserved stock.
+	InsertReservation(ctx context.Context, reservation *models.Reservation) error
+	// GetReservation returns a reservation, wrapping errNotFound if it does
+	// not exist.
+	GetReservation(ctx context.Context, reservationID string) (models.Reservation, error)
+	// LockReservation is GetReservation, additionally preventing concurrent
+	// transactions from changing the reservation until this one ends.
+	LockReservation(ctx context.Context, reservationID string) (models.Reservation, error)
+	// ListReservations returns the reservations matching filter, oldest
+	// first.
+	ListReservations(ctx context.Context, filter models.ReservationFilter) ([]models.Reservation, error)
+	// SetReservationStatus changes a reservation's status.
+	SetReservationStatus(ctx context.Context, reservationID string, status string) error
+
+	// OpenStockAlert adds an alert for a product unless the product already
+	// has an open one, filling in its ID and creation time. It reports
+	// whether it added the alert.
+	OpenStockAlert(ctx context.Context, alert *models.StockAlert) (bool, error)
+	// ResolveStockAlert sets the ResolvedAt of a product's open alert, if it
+	// has one, to the current time.
+	ResolveStockAlert(ctx context.Context, productID string) error
+	// ListStockAlerts returns the stock alerts matching filter, oldest first.
+	ListStockAlerts(ctx context.Context, filter models.StockAlertFilter) ([]models.StockAlert, error)
+	// MarkStockAlertNotified sets the NotifiedAt of an alert to the current
+	// time. It reports false if the alert does not exist or was already
+	// notified, so that concurrent callers announce it once.
+	MarkStockAlertNotified(ctx context.Context, alertID string) (bool, error)
+
+	// InsertSupplier adds a new supplier, wrapping errDuplicate if its ID is
+	// taken.
+	InsertSupplier(ctx context.Context, supplier models.Supplier) error
+	// GetSupplier returns a supplier, wrapping errNotFound if it does not
+	// exist.
+	GetSupplier(ctx context.Context, supplierID string) (models.Supplier, error)
+	// ListSuppliers returns every supplier by ID.
+	ListSuppliers(ctx context.Context) ([]models.Supplier, error)
+	// UpdateSupplier replaces the fields of a supplier, wrapping errNotFound
+	// if it does not exist.
+	UpdateSupplier(ctx context.Context, supplier models.Supplier) error
+	// DeleteSupplier removes a supplier. It wraps errNotFound if the supplier
+	// does not exist and errInUse if any purchase order refers to it.
+	DeleteSupplier(ctx context.Context, supplierID string) error
+
+	// InsertPurchaseOrder adds a purchase order and its lines, filling in its
+	// ID and creation time.
+	InsertPurchaseOrder(ctx context.Context, po *models.PurchaseOrder) error
+	// GetPurchaseOrder returns a purchase order with its lines, wrapping
+	// errNotFound if it does not exist.
+	GetPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error)
+	// LockPurchaseOrder is GetPurchaseOrder, additionally preventing
+	// concurrent transactions from changing the purchase order until this
+	// one ends.
+	LockPurchaseOrder(ctx context.Context, poID string) (models.PurchaseOrder, error)
+	// ListPurchaseOrders returns the purchase orders matching filter with
+	// their lines, newest first.
+	ListPurchaseOrders(ctx context.Context, filter models.PurchaseOrderFilter) ([]models.PurchaseOrder, error)
+	// UpdatePurchaseOrder replaces the supplier, warehouse, expected date and
+	// lines of a purchase order, wrapping errNotFound if it does not exist.
+	UpdatePurchaseOrder(ctx context.Context, po models.PurchaseOrder) error
+	// SetPurchaseOrderStatus changes a purchase order's status, setting its
+	// SentAt or ClosedAt to the current time when it becomes Sent or Closed.
+	SetPurchaseOrderStatus(ctx context.Context, poID string, status string) error
+	// AddReceived adds quantity to the received quantity of a purchase order
+	// line, wrapping errNotFound if the line does not exist.
+	AddReceived(ctx context.Context, poID, productID string, quantity int) error
+	// DeletePurchaseOrder removes a purchase order and its lines, wrapping
+	// errNotFound if it does not exist.
+	DeletePurchaseOrder(ctx context.Context, poID string) error
+	// OnOrder returns, by product, the units still to be received on
+	// purchase orders that are Draft, Sent or PartiallyReceived.
+	OnOrder(ctx context.Context) (map[string]int, error)
+
+	// InsertLot adds a lot, filling in its ID and creation time. It wraps
+	// errDuplicate if the product already has a lot with its number at its
+	// warehouse.
+	InsertLot(ctx context.Context, lot *models.Lot) error
+	// GetLot returns a lot, wrapping errNotFound if it does not exist.
+	GetLot(ctx context.Context, lotID string) (models.Lot, error)
+	// LockLot is GetLot, additionally preventing concurrent transactions
+	// from changing the lot until this one ends.
+	LockLot(ctx context.Context, lotID string) (models.Lot, error)
+	// ListLots returns the lots matching filter, earliest expiry first and
+	// then by ID.
+	ListLots(ctx context.Context, filter models.LotFilter) ([]models.Lot, error)
+	// LockActiveLots returns the Active lots of a product at a warehouse
+	// that hold stock, in ListLots order, locking them like LockLot.
+	LockActiveLots(ctx context.Context, warehouseID, productID string) ([]models.Lot, error)
+	// AddLotQuantity adds delta, which may be negative, to the quantity of
+	// a lot. It wraps errNotFound if the lot does not exist and
+	// errInsufficientStock if its quantity would become negative.
+	AddLotQuantity(ctx context.Context, lotID string, delta int) error
+	// SetLotStatus changes a lot's status, setting its QuarantinedAt to the
+	// current time when it becomes Quarantined.
+	SetLotStatus(ctx context.Context, lotID string, status string) error
+	// InsertLotAllocation records that an order line took stock from a lot.
+	InsertLotAllocation(ctx context.Context, allocation models.LotAllocation) error
+	// ListLotAllocations returns the lot allocations of an order's lines,
+	// by line and then in ListLots order.
+	ListLotAllocations(ctx context.Context, orderID string) ([]models.LotAllocation, error)
+	// InsertTransferLot records that a transfer line took stock from a lot,
+	// adding to the quantity if it already took some.
+	InsertTransferLot(ctx context.Context, lot models.TransferLot) error
+	// ListTransferLots returns the lots a transfer's lines took stock from,
+	// by product and then in ListLots order.
+	ListTransferLots(ctx context.Context, transferID string) ([]models.TransferLot, error)
+
+	// InsertOrder adds an order and its items, filling in their IDs and the
+	// order's creation time.
+	InsertOrder(ctx context.Context, order *models.Order) error
+	// GetOrder returns an order with its items, wrapping errNotFound if it
+	// does not exist.
+	GetOrder(ctx context.Context, orderID string) (models.Order, error)
+	// LockOrder is GetOrder, additionally preventing concurrent transactions
+	// from changing the order until this one ends.
+	LockOrder(ctx context.Context, orderID string) (models.Order, error)
+	// ListOrders returns the page of orders matching filter that page
+	// selects, with their items, in the order it reads them.
+	ListOrders(ctx context.Context, filter models.OrderFilter, page pageSpec) ([]models.Order, error)
+	// SetOrderStatus changes an order's status.
+	SetOrderStatus(ctx context.Context, orderID string, status string) error
+	// CountOrdersByStatus returns the number of orders in each status.
+	CountOrdersByStatus(ctx context.Context) (map[string]int, error)
+	// UnitsOrdered returns, by product, the units on orders created at or
+	// after since, leaving out Cancelled and Returned orders.
+	UnitsOrdered(ctx context.Context, since time.Time) (map[string]int, error)
+
+	// AppendStatusChange adds an entry to an order's timeline, setting its
+	// ChangedAt to the current time.
+	AppendStatusChange(ctx context.Context, change models.OrderStatusChange) error
+	// ListStatusChanges returns an order's timeline, oldest first.
+	ListStatusChanges(ctx context.Context, orderID string) ([]models.OrderStatusChange, error)
+}
+
+// txKey is the context key under which a Store records its open transaction.
+type txKey struct{}
+
+// ctxTx is the transaction recorded in a context, with the store it belongs
+// to so that one store never joins another's transaction.
+type ctxTx struct {
+	store Store
+	tx    StoreTx
+}
+
+// txFromContext returns the transaction of store recorded in ctx, if any.
+func txFromContext(ctx context.Context, store Store) (StoreTx, bool) {
+	if v, ok := ctx.Value(txKey{}).(ctxTx); ok && v.store == store {
+		return v.tx, true
+	}
+	return nil, false
+}
+
+// contextWithTx records tx as the open transaction of store in ctx.
+func contextWithTx(ctx context.Context, store Store, tx StoreTx) context.Context {
+	return context.WithValue(ctx, txKey{}, ctxTx{store: store, tx: tx})
+}
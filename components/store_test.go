@@ -0,0 +1,414 @@
+package components
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"service-weaver-app/config"
+	"service-weaver-app/database"
+	"service-weaver-app/models"
+)
+
+// testDB opens a migrated database of the given dialect. SQLite databases
+// are created in a temporary directory; Postgres tests use the database
+// named by TEST_DATABASE_URL and are skipped when it is not set.
+func testDB(t *testing.T, dialect database.Dialect) *sql.DB {
+	t.Helper()
+	connStr := os.Getenv("TEST_DATABASE_URL")
+	if dialect == database.SQLite {
+		connStr = config.Database{Driver: "sqlite", Path: filepath.Join(t.TempDir(), "test.db")}.DSN()
+	} else if connStr == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := database.Open(dialect, connStr)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// forEachDB runs fn as a subtest against a database of every dialect.
+func forEachDB(t *testing.T, fn func(t *testing.T, db *sql.DB, dialect database.Dialect)) {
+	for _, dialect := range []database.Dialect{database.Postgres, database.SQLite} {
+		t.Run(string(dialect), func(t *testing.T) {
+			fn(t, testDB(t, dialect), dialect)
+		})
+	}
+}
+
+// storeHarness is a Store under test.
+type storeHarness struct {
+	Store
+	// productID returns an unused product ID and arranges for the product
+	// and every order and transfer referencing it to be removed when the
+	// test ends.
+	productID func() string
+}
+
+// forEachStore runs fn as a subtest against every Store implementation.
+func forEachStore(t *testing.T, fn func(t *testing.T, h storeHarness)) {
+	t.Run("Memory", func(t *testing.T) {
+		n := 0
+		fn(t, storeHarness{
+			Store:     NewMemoryStore(),
+			productID: func() string { n++; return fmt.Sprintf("p%d", n) },
+		})
+	})
+	forEachDB(t, func(t *testing.T, db *sql.DB, dialect database.Dialect) {
+		fn(t, storeHarness{
+			Store: NewSQLStore(db, dialect),
+			productID: func() string {
+				id := fmt.Sprintf("test-%d", rand.Int63())
+				t.Cleanup(func() { cleanupProducts(db, id) })
+				return id
+			},
+		})
+	})
+}
+
+// cleanupProducts removes the given products and every order, transfer and
+// purchase order that references them.
+func cleanupProducts(db *sql.DB, productIDs ...string) {
+	for _, id := range productIDs {
+		db.Exec(`DELETE FROM orders WHERE id IN (SELECT order_id FROM order_items WHERE product_id = $1)`, id)
+		db.Exec(`DELETE FROM transfers WHERE id IN (SELECT transfer_id FROM transfer_lines WHERE product_id = $1)`, id)
+		db.Exec(`DELETE FROM purchase_orders WHERE id IN (SELECT purchase_order_id FROM purchase_order_lines WHERE product_id = $1)`, id)
+		db.Exec(`DELETE FROM products WHERE id = $1`, id)
+	}
+}
+
+// inTx runs fn in a transaction of s and fails the test on error.
+func inTx(t *testing.T, s Store, fn func(ctx context.Context, tx StoreTx) error) {
+	t.Helper()
+	if err := s.InTx(context.Background(), fn); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreProducts(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		id := h.productID()
+		want := models.Product{ID: id, Name: "widget", Description: "A blue widget", Tags: []string{"blue", "small"}, Stock: 4, Price: 9.99}
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			return tx.InsertProduct(ctx, want)
+		})
+		err := h.InTx(context.Background(), func(ctx context.Context, tx StoreTx) error {
+			return tx.InsertProduct(ctx, want)
+		})
+		if !errors.Is(err, errDuplicate) {
+			t.Errorf("inserting a duplicate product error = %v, want errDuplicate", err)
+		}
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			got, err := tx.GetProduct(ctx, id)
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("GetProduct() = %+v, %v, want %+v", got, err, want)
+			}
+			if _, err := tx.GetProduct(ctx, id+"-missing"); !errors.Is(err, errNotFound) {
+				t.Errorf("GetProduct(missing) error = %v, want errNotFound", err)
+			}
+			products, err := tx.ListProducts(ctx, models.ProductFilter{NameContains: "widget"}, pageSpec{field: "id", limit: MaxPageSize})
+			if err != nil {
+				return err
+			}
+			for _, p := range products {
+				if p.ID == id {
+					return nil
+				}
+			}
+			t.Errorf("ListProducts() = %+v, missing %s", products, id)
+			return nil
+		})
+	})
+}
+
+func TestStoreAdjustStock(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		id := h.productID()
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			return tx.InsertProduct(ctx, models.Product{ID: id, Name: "widget", Stock: 4, Price: 1})
+		})
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			product, err := tx.AdjustStock(ctx, DefaultWarehouseID, id, -3)
+			if err != nil || product.Stock != 1 {
+				t.Errorf("AdjustStock(-3) = %+v, %v, want stock 1", product, err)
+			}
+			if _, err := tx.AdjustStock(ctx, DefaultWarehouseID, id, -2); !errors.Is(err, errInsufficientStock) {
+				t.Errorf("AdjustStock(-2) error = %v, want errInsufficientStock", err)
+			}
+			if _, err := tx.AdjustStock(ctx, DefaultWarehouseID, id+"-missing", 1); !errors.Is(err, errNotFound) {
+				t.Errorf("AdjustStock(missing) error = %v, want errNotFound", err)
+			}
+			product, err = tx.GetProduct(ctx, id)
+			if err != nil || product.Stock != 1 {
+				t.Errorf("stock after failed adjustment = %d, %v, want 1", product.Stock, err)
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreTransactions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		id := h.productID()
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			return tx.InsertProduct(ctx, models.Product{ID: id, Name: "widget", Stock: 10, Price: 1})
+		})
+
+		rollback := errors.New("rollback")
+		err := h.InTx(context.Background(), func(ctx context.Context, tx StoreTx) error {
+			if _, err := tx.AdjustStock(ctx, DefaultWarehouseID, id, -5); err != nil {
+				return err
+			}
+			// A nested InTx joins the outer transaction and sees its writes.
+			err := h.InTx(ctx, func(ctx context.Context, inner StoreTx) error {
+				product, err := inner.GetProduct(ctx, id)
+				if err != nil || product.Stock != 5 {
+					t.Errorf("nested transaction saw stock %d, %v, want 5", product.Stock, err)
+				}
+				_, err = inner.AdjustStock(ctx, DefaultWarehouseID, id, -1)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return rollback
+		})
+		if err != rollback {
+			t.Fatalf("InTx() error = %v, want %v", err, rollback)
+		}
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			product, err := tx.GetProduct(ctx, id)
+			if err != nil || product.Stock != 10 {
+				t.Errorf("stock after rollback = %d, %v, want 10", product.Stock, err)
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreUpdateDeleteProduct(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		id, ordered := h.productID(), h.productID()
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			if err := tx.InsertProduct(ctx, models.Product{ID: id, Name: "widget", Stock: 4, Price: 9.99}); err != nil {
+				return err
+			}
+			if err := tx.InsertProduct(ctx, models.Product{ID: ordered, Name: "gadget", Stock: 4, Price: 1}); err != nil {
+				return err
+			}
+			return tx.InsertOrder(ctx, &models.Order{
+				Items:  []models.OrderItem{{ProductID: ordered, Quantity: 1, UnitPrice: 1, LineTotal: 1}},
+				Total:  1,
+				Status: models.OrderStatusPending,
+			})
+		})
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			name, price, tags := "sprocket", 12.5, []string{"steel"}
+			got, err := tx.UpdateProduct(ctx, id, models.ProductUpdate{Name: &name, Tags: &tags, Price: &price})
+			want := models.Product{ID: id, Name: "sprocket", Tags: []string{"steel"}, Stock: 4, Price: 12.5}
+			if err != nil || !reflect.DeepEqual(got, want) {
+				t.Errorf("UpdateProduct() = %+v, %v, want %+v", got, err, want)
+			}
+			if _, err := tx.UpdateProduct(ctx, id+"-missing", models.ProductUpdate{Name: &name}); !errors.Is(err, errNotFound) {
+				t.Errorf("UpdateProduct(missing) error = %v, want errNotFound", err)
+			}
+			return nil
+		})
+
+		err := h.InTx(context.Background(), func(ctx context.Context, tx StoreTx) error {
+			return tx.DeleteProduct(ctx, ordered)
+		})
+		if !errors.Is(err, errInUse) {
+			t.Errorf("DeleteProduct(ordered) error = %v, want errInUse", err)
+		}
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			if err := tx.DeleteProduct(ctx, id); err != nil {
+				return err
+			}
+			if _, err := tx.GetProduct(ctx, id); !errors.Is(err, errNotFound) {
+				t.Errorf("GetProduct(deleted) error = %v, want errNotFound", err)
+			}
+			if err := tx.DeleteProduct(ctx, id); !errors.Is(err, errNotFound) {
+				t.Errorf("DeleteProduct(deleted) error = %v, want errNotFound", err)
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreOrders(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		id := h.productID()
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			return tx.InsertProduct(ctx, models.Product{ID: id, Name: "widget", Stock: 10, Price: 2})
+		})
+
+		order := models.Order{
+			Items:  []models.OrderItem{{ProductID: id, Quantity: 2, UnitPrice: 2, LineTotal: 4}},
+			Total:  4,
+			Status: models.OrderStatusPending,
+		}
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			if err := tx.InsertOrder(ctx, &order); err != nil {
+				return err
+			}
+			return tx.AppendStatusChange(ctx, models.OrderStatusChange{OrderID: order.ID, To: order.Status, Actor: "tester"})
+		})
+		if order.ID == "" || order.Items[0].ID == "" || order.CreatedAt.IsZero() {
+			t.Fatalf("InsertOrder() did not fill in IDs and creation time: %+v", order)
+		}
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			if err := tx.SetOrderStatus(ctx, order.ID, models.OrderStatusConfirmed); err != nil {
+				return err
+			}
+			return tx.AppendStatusChange(ctx, models.OrderStatusChange{
+				OrderID: order.ID, From: models.OrderStatusPending, To: models.OrderStatusConfirmed, Actor: "tester",
+			})
+		})
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			got, err := tx.LockOrder(ctx, order.ID)
+			if err != nil {
+				return err
+			}
+			if got.Status != models.OrderStatusConfirmed || len(got.Items) != 1 || got.Items[0] != order.Items[0] {
+				t.Errorf("LockOrder() = %+v, want confirmed copy of %+v", got, order)
+			}
+			if _, err := tx.GetOrder(ctx, "999999999"); !errors.Is(err, errNotFound) {
+				t.Errorf("GetOrder(missing) error = %v, want errNotFound", err)
+			}
+			if err := tx.SetOrderStatus(ctx, "999999999", models.OrderStatusPicked); !errors.Is(err, errNotFound) {
+				t.Errorf("SetOrderStatus(missing) error = %v, want errNotFound", err)
+			}
+
+			timeline, err := tx.ListStatusChanges(ctx, order.ID)
+			if err != nil {
+				return err
+			}
+			if len(timeline) != 2 || timeline[0].From != "" || timeline[1].To != models.OrderStatusConfirmed || timeline[1].Actor != "tester" {
+				t.Errorf("ListStatusChanges() = %+v", timeline)
+			}
+
+			counts, err := tx.CountOrdersByStatus(ctx)
+			if err != nil {
+				return err
+			}
+			if counts[models.OrderStatusConfirmed] < 1 {
+				t.Errorf("CountOrdersByStatus() = %v, want at least one Confirmed", counts)
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreNonNumericIDs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			// IDs from the request path name no row unless they are
+			// integers, on every backend.
+			lookups := map[string]func(id string) error{
+				"GetOrder":          func(id string) error { _, err := tx.GetOrder(ctx, id); return err },
+				"LockOrder":         func(id string) error { _, err := tx.LockOrder(ctx, id); return err },
+				"GetTransfer":       func(id string) error { _, err := tx.GetTransfer(ctx, id); return err },
+				"LockTransfer":      func(id string) error { _, err := tx.LockTransfer(ctx, id); return err },
+				"GetReservation":    func(id string) error { _, err := tx.GetReservation(ctx, id); return err },
+				"LockReservation":   func(id string) error { _, err := tx.LockReservation(ctx, id); return err },
+				"GetPurchaseOrder":  func(id string) error { _, err := tx.GetPurchaseOrder(ctx, id); return err },
+				"LockPurchaseOrder": func(id string) error { _, err := tx.LockPurchaseOrder(ctx, id); return err },
+				"GetLot":            func(id string) error { _, err := tx.GetLot(ctx, id); return err },
+				"LockLot":           func(id string) error { _, err := tx.LockLot(ctx, id); return err },
+			}
+			for name, lookup := range lookups {
+				for _, id := range []string{"abc", "1.5", ""} {
+					if err := lookup(id); !errors.Is(err, errNotFound) {
+						t.Errorf("%s(%q) error = %v, want errNotFound", name, id, err)
+					}
+				}
+			}
+			return nil
+		})
+	})
+}
+
+func TestStoreRollbackAfterAppends(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		id := h.productID()
+		movement := func(delta int) *models.StockMovement {
+			return &models.StockMovement{WarehouseID: DefaultWarehouseID, ProductID: id, Delta: delta, Reason: models.MovementReasonAdjustment}
+		}
+		var order models.Order
+		var lot models.Lot
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			if err := tx.InsertProduct(ctx, models.Product{ID: id, Name: "widget", Stock: 10, Price: 1}); err != nil {
+				return err
+			}
+			if err := tx.AppendMovement(ctx, movement(1)); err != nil {
+				return err
+			}
+			order = models.Order{Status: models.OrderStatusPending, Items: []models.OrderItem{{ProductID: id, WarehouseID: DefaultWarehouseID, Quantity: 3, UnitPrice: 1}}}
+			if err := tx.InsertOrder(ctx, &order); err != nil {
+				return err
+			}
+			lot = models.Lot{ProductID: id, WarehouseID: DefaultWarehouseID, LotNumber: "L1", Quantity: 5, Status: models.LotStatusActive, ExpiresAt: time.Now().UTC().AddDate(0, 0, 1)}
+			if err := tx.InsertLot(ctx, &lot); err != nil {
+				return err
+			}
+			return tx.InsertLotAllocation(ctx, models.LotAllocation{OrderItemID: order.Items[0].ID, LotID: lot.ID, Quantity: 1})
+		})
+
+		// The ledger and allocations a failed transaction appended to or
+		// changed in place are left as they were.
+		rollback := errors.New("rollback")
+		err := h.InTx(context.Background(), func(ctx context.Context, tx StoreTx) error {
+			if err := tx.AppendMovement(ctx, movement(2)); err != nil {
+				return err
+			}
+			if err := tx.InsertLotAllocation(ctx, models.LotAllocation{OrderItemID: order.Items[0].ID, LotID: lot.ID, Quantity: 2}); err != nil {
+				return err
+			}
+			return rollback
+		})
+		if err != rollback {
+			t.Fatalf("InTx() error = %v, want %v", err, rollback)
+		}
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			return tx.AppendMovement(ctx, movement(3))
+		})
+
+		inTx(t, h, func(ctx context.Context, tx StoreTx) error {
+			movements, err := tx.ListMovements(ctx, models.MovementFilter{ProductID: id}, pageSpec{field: "created_at", limit: MaxPageSize})
+			if err != nil {
+				return err
+			}
+			var deltas []int
+			for _, m := range movements {
+				deltas = append(deltas, m.Delta)
+			}
+			sort.Ints(deltas)
+			if fmt.Sprint(deltas) != "[1 3]" {
+				t.Errorf("movement deltas after rollback = %v, want [1 3]", deltas)
+			}
+			allocations, err := tx.ListLotAllocations(ctx, order.ID)
+			if err != nil || len(allocations) != 1 || allocations[0].Quantity != 1 {
+				t.Errorf("ListLotAllocations after rollback = %+v, %v, want 1 unit", allocations, err)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,292 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"service-weaver-app/models"
+)
+
+// CreateTransfer records a Draft transfer of stock between two warehouses
+// and returns it with its ID. No stock moves until it ships.
+func (im *InventoryManagementImpl) CreateTransfer(ctx context.Context, transfer models.Transfer) (models.Transfer, error) {
+	if err := validateTransfer(transfer); err != nil {
+		return models.Transfer{}, err
+	}
+	transfer.Status = models.TransferStatusDraft
+	transfer.ShippedAt, transfer.ReceivedAt = nil, nil
+
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		for _, warehouseID := range []string{transfer.SourceID, transfer.DestinationID} {
+			if _, err := tx.GetWarehouse(ctx, warehouseID); errors.Is(err, errNotFound) {
+				return fmt.Errorf("%w: %s", ErrWarehouseNotFound, warehouseID)
+			} else if err != nil {
+				return fmt.Errorf("could not fetch warehouse: %w", err)
+			}
+		}
+		for _, line := range transfer.Lines {
+			if _, err := tx.GetProduct(ctx, line.ProductID); errors.Is(err, errNotFound) {
+				return fmt.Errorf("%w: %s", ErrProductNotFound, line.ProductID)
+			} else if err != nil {
+				return fmt.Errorf("could not fetch product: %w", err)
+			}
+		}
+		if err := tx.InsertTransfer(ctx, &transfer); err != nil {
+			return fmt.Errorf("could not create transfer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	return transfer, nil
+}
+
+// validateTransfer checks the fields of a new transfer.
+func validateTransfer(transfer models.Transfer) error {
+	switch {
+	case transfer.SourceID == "" || transfer.DestinationID == "":
+		return fmt.Errorf("%w: source_id and destination_id are required", ErrInvalidTransfer)
+	case transfer.SourceID == transfer.DestinationID:
+		return fmt.Errorf("%w: source and destination are the same warehouse", ErrInvalidTransfer)
+	case len(transfer.Lines) == 0:
+		return fmt.Errorf("%w: no lines", ErrInvalidTransfer)
+	}
+	seen := make(map[string]bool, len(transfer.Lines))
+	for _, line := range transfer.Lines {
+		switch {
+		case line.ProductID == "":
+			return fmt.Errorf("%w: product_id is required", ErrInvalidTransfer)
+		case line.Quantity <= 0:
+			return fmt.Errorf("%w: quantity must be positive", ErrInvalidTransfer)
+		case seen[line.ProductID]:
+			return fmt.Errorf("%w: product %s has more than one line", ErrInvalidTransfer, line.ProductID)
+		}
+		seen[line.ProductID] = true
+	}
+	return nil
+}
+
+// GetTransfer returns a transfer with its lines.
+func (im *InventoryManagementImpl) GetTransfer(ctx context.Context, transferID string) (models.Transfer, error) {
+	var transfer models.Transfer
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		transfer, err = tx.GetTransfer(ctx, transferID)
+		return err
+	})
+	if errors.Is(err, errNotFound) {
+		return models.Transfer{}, fmt.Errorf("%w: %s", ErrTransferNotFound, transferID)
+	}
+	if err != nil {
+		return models.Transfer{}, fmt.Errorf("could not fetch transfer: %w", err)
+	}
+	return transfer, nil
+}
+
+// GetTransfers returns the transfers matching filter, newest first.
+func (im *InventoryManagementImpl) GetTransfers(ctx context.Context, filter models.TransferFilter) ([]models.Transfer, error) {
+	transfers := []models.Transfer{}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		found, err := tx.ListTransfers(ctx, filter)
+		transfers = append(transfers, found...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+// ShipTransfer takes the stock of every line of a Draft transfer from its
+// source warehouse, from its lots first as any decrease is, and marks it
+// InTransit. If the source cannot cover any line, nothing changes and
+// ErrInsufficientStock is returned.
+func (im *InventoryManagementImpl) ShipTransfer(ctx context.Context, transferID string) (models.Transfer, error) {
+	return im.moveTransfer(ctx, transferID, models.TransferStatusInTransit)
+}
+
+// ReceiveTransfer adds the stock of every line of an InTransit transfer to
+// its destination warehouse and marks it Received. Stock that shipped from a
+// lot goes to the destination's lot of the same number, which is created
+// with the same expiry if the destination has none; what goes to a
+// Quarantined lot is taken straight out of stock again, as when an order is
+// cancelled.
+func (im *InventoryManagementImpl) ReceiveTransfer(ctx context.Context, transferID string) (models.Transfer, error) {
+	return im.moveTransfer(ctx, transferID, models.TransferStatusReceived)
+}
+
+// moveTransfer ships a transfer, when to is InTransit, or receives it, when
+// to is Received, moving the stock of its lines and their lots out of the
+// source or into the destination and recording the movements in the ledger.
+// It all happens in one transaction with the transfer locked, so a transfer
+// ships and is received at most once.
+func (im *InventoryManagementImpl) moveTransfer(ctx context.Context, transferID, to string) (models.Transfer, error) {
+	from := models.TransferStatusDraft
+	if to == models.TransferStatusReceived {
+		from = models.TransferStatusInTransit
+	}
+
+	var transfer models.Transfer
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		transfer, err = tx.LockTransfer(ctx, transferID)
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrTransferNotFound, transferID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not fetch transfer: %w", err)
+		}
+		if transfer.Status != from {
+			return fmt.Errorf("%w: transfer %s is %s, not %s", ErrInvalidTransfer, transferID, transfer.Status, from)
+		}
+
+		// The status changes first so that the reorder point watcher sees
+		// the lines in transit while their stock is out of every warehouse.
+		if err := tx.SetTransferStatus(ctx, transferID, to); err != nil {
+			return fmt.Errorf("could not update transfer status: %w", err)
+		}
+
+		lines := append([]models.TransferLine(nil), transfer.Lines...)
+		sort.Slice(lines, func(i, j int) bool { return lines[i].ProductID < lines[j].ProductID })
+		if to == models.TransferStatusInTransit {
+			for _, line := range lines {
+				if err := shipLine(ctx, tx, transfer, line); err != nil {
+					return err
+				}
+			}
+		} else {
+			shipped, err := tx.ListTransferLots(ctx, transferID)
+			if err != nil {
+				return fmt.Errorf("could not fetch transfer lots: %w", err)
+			}
+			lots := make(map[string][]models.TransferLot)
+			for _, lot := range shipped {
+				lots[lot.ProductID] = append(lots[lot.ProductID], lot)
+			}
+			for _, line := range lines {
+				if err := receiveLine(ctx, tx, transfer, line, lots[line.ProductID]); err != nil {
+					return err
+				}
+			}
+		}
+
+		transfer, err = tx.GetTransfer(ctx, transferID)
+		if err != nil {
+			return fmt.Errorf("could not fetch transfer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	return transfer, nil
+}
+
+// GetTransferLots returns the lots a transfer's lines took stock from at
+// the source, by product.
+func (im *InventoryManagementImpl) GetTransferLots(ctx context.Context, transferID string) ([]models.TransferLot, error) {
+	lots := []models.TransferLot{}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		if _, err := tx.GetTransfer(ctx, transferID); err != nil {
+			if errors.Is(err, errNotFound) {
+				return fmt.Errorf("%w: %s", ErrTransferNotFound, transferID)
+			}
+			return fmt.Errorf("could not fetch transfer: %w", err)
+		}
+		found, err := tx.ListTransferLots(ctx, transferID)
+		if err != nil {
+			return fmt.Errorf("could not fetch transfer lots: %w", err)
+		}
+		lots = append(lots, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return lots, nil
+}
+
+// shipLine takes the stock of a transfer line from the source warehouse and
+// records the lots it came from.
+func shipLine(ctx context.Context, tx StoreTx, transfer models.Transfer, line models.TransferLine) error {
+	_, allocations, err := changeStock(ctx, tx, transfer.SourceID, line.ProductID, -line.Quantity)
+	if err != nil {
+		return err
+	}
+	for _, allocation := range allocations {
+		lot := models.TransferLot{TransferID: transfer.ID, LotID: allocation.LotID, Quantity: allocation.Quantity}
+		if err := tx.InsertTransferLot(ctx, lot); err != nil {
+			return fmt.Errorf("could not record transfer lot: %w", err)
+		}
+	}
+	movement := models.StockMovement{
+		WarehouseID: transfer.SourceID,
+		ProductID:   line.ProductID,
+		Delta:       -line.Quantity,
+		Reason:      models.MovementReasonTransferOut,
+		Reference:   transfer.ID,
+	}
+	return recordMovement(ctx, tx, movement)
+}
+
+// receiveLine adds the stock of a transfer line to the destination
+// warehouse, and what it shipped from lots to the destination's lots.
+func receiveLine(ctx context.Context, tx StoreTx, transfer models.Transfer, line models.TransferLine, shipped []models.TransferLot) error {
+	var quarantined []models.TransferLot
+	for _, source := range shipped {
+		lot, err := receiveLot(ctx, tx, transfer.DestinationID, source)
+		if err != nil {
+			return err
+		}
+		if lot.Status == models.LotStatusQuarantined {
+			source.LotID = lot.ID
+			quarantined = append(quarantined, source)
+		}
+	}
+	movement := models.StockMovement{
+		WarehouseID: transfer.DestinationID,
+		ProductID:   line.ProductID,
+		Delta:       line.Quantity,
+		Reason:      models.MovementReasonTransferIn,
+		Reference:   transfer.ID,
+	}
+	if _, err := adjustStock(ctx, tx, movement); err != nil {
+		return err
+	}
+	for _, lot := range quarantined {
+		if err := quarantineStock(ctx, tx, transfer.DestinationID, lot.ProductID, lot.LotID, lot.Quantity); err != nil {
+			return fmt.Errorf("could not quarantine received stock: %w", err)
+		}
+	}
+	return nil
+}
+
+// receiveLot adds stock shipped from a lot to the lot of the same number at
+// the warehouse, creating it with the same expiry if there is none, and
+// returns the lot it went to.
+func receiveLot(ctx context.Context, tx StoreTx, warehouseID string, source models.TransferLot) (models.Lot, error) {
+	lot, found, err := lockLotByNumber(ctx, tx, source.ProductID, warehouseID, source.LotNumber)
+	if err != nil {
+		return models.Lot{}, err
+	}
+	if !found {
+		lot := models.Lot{
+			ProductID:   source.ProductID,
+			WarehouseID: warehouseID,
+			LotNumber:   source.LotNumber,
+			Quantity:    source.Quantity,
+			ExpiresAt:   source.ExpiresAt,
+		}
+		if err := createLot(ctx, tx, &lot); err != nil {
+			return models.Lot{}, err
+		}
+		return lot, nil
+	}
+	if err := tx.AddLotQuantity(ctx, lot.ID, source.Quantity); err != nil {
+		return models.Lot{}, fmt.Errorf("could not update lot: %w", err)
+	}
+	return lot, nil
+}
@@ -0,0 +1,119 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestTransfers(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+
+		source, destination := h.productID(), h.productID()
+		for i, id := range []string{source, destination} {
+			if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: id, Name: "transfers", Priority: 2000 + i}); err != nil {
+				t.Fatalf("AddWarehouse: %v", err)
+			}
+		}
+		a, b := h.productID(), h.productID()
+		for _, id := range []string{a, b} {
+			if err := inventory.AddProduct(ctx, models.Product{ID: id, Name: "moved", Price: 1}); err != nil {
+				t.Fatalf("AddProduct: %v", err)
+			}
+			if err := inventory.UpdateStockAt(ctx, source, id, 5); err != nil {
+				t.Fatalf("UpdateStockAt: %v", err)
+			}
+		}
+
+		invalid := []models.Transfer{
+			{SourceID: source, DestinationID: source, Lines: []models.TransferLine{{ProductID: a, Quantity: 1}}},
+			{SourceID: source, DestinationID: destination},
+			{SourceID: source, DestinationID: destination, Lines: []models.TransferLine{{ProductID: a, Quantity: 0}}},
+			{SourceID: source, DestinationID: destination, Lines: []models.TransferLine{{ProductID: a, Quantity: 1}, {ProductID: a, Quantity: 2}}},
+		}
+		for _, transfer := range invalid {
+			if _, err := inventory.CreateTransfer(ctx, transfer); !errors.Is(err, ErrInvalidTransfer) {
+				t.Errorf("CreateTransfer(%+v) error = %v, want ErrInvalidTransfer", transfer, err)
+			}
+		}
+		missing := models.Transfer{SourceID: source, DestinationID: destination + "-missing", Lines: []models.TransferLine{{ProductID: a, Quantity: 1}}}
+		if _, err := inventory.CreateTransfer(ctx, missing); !errors.Is(err, ErrWarehouseNotFound) {
+			t.Errorf("CreateTransfer(missing destination) error = %v, want ErrWarehouseNotFound", err)
+		}
+
+		transfer, err := inventory.CreateTransfer(ctx, models.Transfer{
+			SourceID:      source,
+			DestinationID: destination,
+			Lines:         []models.TransferLine{{ProductID: b, Quantity: 2}, {ProductID: a, Quantity: 3}},
+		})
+		if err != nil || transfer.ID == "" || transfer.Status != models.TransferStatusDraft {
+			t.Fatalf("CreateTransfer = %+v, %v", transfer, err)
+		}
+		if _, err := inventory.ReceiveTransfer(ctx, transfer.ID); !errors.Is(err, ErrInvalidTransfer) {
+			t.Errorf("ReceiveTransfer(draft) error = %v, want ErrInvalidTransfer", err)
+		}
+
+		levels := func(productID string) string {
+			report, err := inventory.CheckStockLevels(ctx, productID)
+			if err != nil {
+				t.Fatalf("CheckStockLevels: %v", err)
+			}
+			return fmt.Sprint(report.Total, report.InTransit, report.Levels)
+		}
+
+		shipped, err := inventory.ShipTransfer(ctx, transfer.ID)
+		if err != nil || shipped.Status != models.TransferStatusInTransit || shipped.ShippedAt == nil || len(shipped.Lines) != 2 {
+			t.Fatalf("ShipTransfer = %+v, %v", shipped, err)
+		}
+		if got, want := levels(a), fmt.Sprintf("2 3 [{%s 2 0 0 2}]", source); got != want {
+			t.Errorf("stock of a in transit = %s, want %s", got, want)
+		}
+		if _, err := inventory.ShipTransfer(ctx, transfer.ID); !errors.Is(err, ErrInvalidTransfer) {
+			t.Errorf("ShipTransfer(again) error = %v, want ErrInvalidTransfer", err)
+		}
+
+		received, err := inventory.ReceiveTransfer(ctx, transfer.ID)
+		if err != nil || received.Status != models.TransferStatusReceived || received.ReceivedAt == nil {
+			t.Fatalf("ReceiveTransfer = %+v, %v", received, err)
+		}
+		if got, want := levels(a), fmt.Sprintf("5 0 [{%s 2 0 0 2} {%s 3 0 0 3}]", source, destination); got != want {
+			t.Errorf("stock of a after receiving = %s, want %s", got, want)
+		}
+
+		// Shipping more than the source holds moves nothing.
+		tooMuch, err := inventory.CreateTransfer(ctx, models.Transfer{
+			SourceID:      source,
+			DestinationID: destination,
+			Lines:         []models.TransferLine{{ProductID: a, Quantity: 1}, {ProductID: b, Quantity: 4}},
+		})
+		if err != nil {
+			t.Fatalf("CreateTransfer: %v", err)
+		}
+		if _, err := inventory.ShipTransfer(ctx, tooMuch.ID); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("ShipTransfer(too much) error = %v, want ErrInsufficientStock", err)
+		}
+		if got, want := levels(a), fmt.Sprintf("5 0 [{%s 2 0 0 2} {%s 3 0 0 3}]", source, destination); got != want {
+			t.Errorf("stock of a after failed shipment = %s, want %s", got, want)
+		}
+
+		drafts, err := inventory.GetTransfers(ctx, models.TransferFilter{Status: models.TransferStatusDraft, WarehouseID: destination})
+		if err != nil || len(drafts) != 1 || drafts[0].ID != tooMuch.ID {
+			t.Errorf("GetTransfers(draft) = %+v, %v, want only %s", drafts, err, tooMuch.ID)
+		}
+		all, err := inventory.GetTransfers(ctx, models.TransferFilter{WarehouseID: source})
+		if err != nil || len(all) != 2 || all[0].ID != tooMuch.ID || len(all[1].Lines) != 2 {
+			t.Errorf("GetTransfers = %+v, %v, want both, newest first", all, err)
+		}
+		if _, err := inventory.GetTransfer(ctx, "999999"); !errors.Is(err, ErrTransferNotFound) {
+			t.Errorf("GetTransfer(missing) error = %v, want ErrTransferNotFound", err)
+		}
+		if err := inventory.DeleteProduct(ctx, a); !errors.Is(err, ErrProductInUse) {
+			t.Errorf("DeleteProduct(transferred) error = %v, want ErrProductInUse", err)
+		}
+	})
+}
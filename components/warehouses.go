@@ -0,0 +1,279 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"service-weaver-app/models"
+)
+
+// DefaultWarehouseID is the warehouse created with the schema. It holds the
+// stock of new products and takes the stock changes that do not name a
+// warehouse, so the single-stock methods keep working on the total.
+const DefaultWarehouseID = "main"
+
+// stockKey identifies the stock of a product at a warehouse.
+type stockKey struct {
+	warehouseID string
+	productID   string
+}
+
+// AddWarehouse adds a new stock location.
+func (im *InventoryManagementImpl) AddWarehouse(ctx context.Context, warehouse models.Warehouse) error {
+	switch {
+	case warehouse.ID == "":
+		return fmt.Errorf("%w: id is required", ErrInvalidWarehouse)
+	case warehouse.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidWarehouse)
+	}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		return tx.InsertWarehouse(ctx, warehouse)
+	})
+	if errors.Is(err, errDuplicate) {
+		return fmt.Errorf("%w: %s", ErrDuplicateWarehouse, warehouse.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not add warehouse: %w", err)
+	}
+	return nil
+}
+
+// GetWarehouses returns every warehouse in allocation order: by priority,
+// then by ID.
+func (im *InventoryManagementImpl) GetWarehouses(ctx context.Context) ([]models.Warehouse, error) {
+	var warehouses []models.Warehouse
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		var err error
+		warehouses, err = tx.ListWarehouses(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch warehouses: %w", err)
+	}
+	return warehouses, nil
+}
+
+// CheckStockLevels returns a product's stock at each warehouse, in total
+// and in transit between warehouses, with the part of it held by active
+// reservations, the part in expired lots and the part available.
+func (im *InventoryManagementImpl) CheckStockLevels(ctx context.Context, productID string) (models.StockReport, error) {
+	report := models.StockReport{ProductID: productID, Levels: []models.StockLevel{}}
+	err := im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		product, err := tx.GetProduct(ctx, productID)
+		if errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+		}
+		if err != nil {
+			return fmt.Errorf("could not fetch product: %w", err)
+		}
+		levels, err := tx.ListStockLevels(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("could not fetch stock levels: %w", err)
+		}
+		inTransit, err := tx.InTransitStock(ctx, productID)
+		if err != nil {
+			return fmt.Errorf("could not fetch stock in transit: %w", err)
+		}
+		expired, err := expiredStock(ctx, tx, productID)
+		if err != nil {
+			return err
+		}
+		report.Total = product.Stock
+		report.InTransit = inTransit
+		for _, level := range levels {
+			level.Expired = expired[level.WarehouseID]
+			level.Available = availableStock(level)
+			report.Reserved += level.Reserved
+			report.Expired += level.Expired
+			report.Available += level.Available
+			report.Levels = append(report.Levels, level)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.StockReport{}, err
+	}
+	return report, nil
+}
+
+// UpdateStockAt adds quantity, which may be negative, to the stock of a
+// product at a warehouse. Stock never becomes negative at any warehouse.
+func (im *InventoryManagementImpl) UpdateStockAt(ctx context.Context, warehouseID, productID string, quantity int) error {
+	return im.store.InTx(ctx, func(ctx context.Context, tx StoreTx) error {
+		movement := models.StockMovement{WarehouseID: warehouseID, ProductID: productID, Delta: quantity, Reason: models.MovementReasonAdjustment}
+		_, err := adjustStock(ctx, tx, movement)
+		return err
+	})
+}
+
+// changeStock is changeLevel, additionally taking a decrease from the
+// product's Active lots at the warehouse, earliest expiry first, before the
+// stock in no lot. Expired lots are never taken: if a decrease would need
+// their stock, ErrInsufficientStock is returned. It returns the quantities
+// taken from each lot. Callers record the change with recordMovement; most
+// use adjustStock, which does both.
+func changeStock(ctx context.Context, tx StoreTx, warehouseID, productID string, delta int) (models.Product, []models.LotAllocation, error) {
+	var lots []models.Lot
+	expired := 0
+	if delta < 0 {
+		// Lots are locked before the stock level, as everywhere else, so
+		// that concurrent changes cannot deadlock each other.
+		locked, err := tx.LockActiveLots(ctx, warehouseID, productID)
+		if err != nil {
+			return models.Product{}, nil, fmt.Errorf("could not fetch lots: %w", err)
+		}
+		lots, expired = splitExpired(locked)
+	}
+	product, err := changeLevel(ctx, tx, warehouseID, productID, delta)
+	if err != nil {
+		return models.Product{}, nil, err
+	}
+	if err := keepExpired(ctx, tx, warehouseID, productID, expired); err != nil {
+		return models.Product{}, nil, err
+	}
+	allocations, err := takeFromLots(ctx, tx, lots, -delta)
+	if err != nil {
+		return models.Product{}, nil, err
+	}
+	return product, allocations, nil
+}
+
+// changeLevel is StoreTx.AdjustStock with its errors translated to the
+// component errors, followed by a check of the product's reorder point. It
+// leaves lots alone.
+func changeLevel(ctx context.Context, tx StoreTx, warehouseID, productID string, delta int) (models.Product, error) {
+	product, err := tx.AdjustStock(ctx, warehouseID, productID, delta)
+	if err != nil {
+		return models.Product{}, stockError(ctx, tx, warehouseID, productID, err)
+	}
+	if err := watchReorderPoint(ctx, tx, product); err != nil {
+		return models.Product{}, err
+	}
+	return product, nil
+}
+
+// takeFromLots takes up to quantity from lots in order, returning what it
+// took from each. Lots never hold more than the stock level, so whatever
+// they cannot cover is stock in no lot.
+func takeFromLots(ctx context.Context, tx StoreTx, lots []models.Lot, quantity int) ([]models.LotAllocation, error) {
+	var allocations []models.LotAllocation
+	for _, lot := range lots {
+		if quantity <= 0 {
+			break
+		}
+		taken := min(quantity, lot.Quantity)
+		if err := tx.AddLotQuantity(ctx, lot.ID, -taken); err != nil {
+			return nil, fmt.Errorf("could not update lot: %w", err)
+		}
+		allocations = append(allocations, models.LotAllocation{
+			LotID:       lot.ID,
+			LotNumber:   lot.LotNumber,
+			ProductID:   lot.ProductID,
+			WarehouseID: lot.WarehouseID,
+			ExpiresAt:   lot.ExpiresAt,
+			Quantity:    taken,
+		})
+		quantity -= taken
+	}
+	return allocations, nil
+}
+
+// stockError translates an error from changing the stock of a product at a
+// warehouse to the component errors.
+func stockError(ctx context.Context, tx StoreTx, warehouseID, productID string, err error) error {
+	if errors.Is(err, errNotFound) {
+		if _, err := tx.GetWarehouse(ctx, warehouseID); errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrWarehouseNotFound, warehouseID)
+		}
+		return fmt.Errorf("%w: %s", ErrProductNotFound, productID)
+	}
+	if errors.Is(err, errInsufficientStock) {
+		return fmt.Errorf("%w for product %s at warehouse %s", ErrInsufficientStock, productID, warehouseID)
+	}
+	return fmt.Errorf("could not update stock: %w", err)
+}
+
+// allocate sets the warehouse of every line of a new order. An order that
+// names a warehouse takes every line from it. Otherwise the order comes
+// from the first warehouse, in priority order, that can fill all of it, so
+// that it ships in one piece; failing that, each line comes from the first
+// warehouse that can fill that line. Only available stock is considered,
+// leaving out what reservations hold and what is in expired lots. Stock is
+// read without locking, so a concurrent order can still take it first, in
+// which case reserving the stock fails with ErrInsufficientStock.
+func allocate(ctx context.Context, tx StoreTx, order *models.Order) error {
+	if order.WarehouseID != "" {
+		if _, err := tx.GetWarehouse(ctx, order.WarehouseID); errors.Is(err, errNotFound) {
+			return fmt.Errorf("%w: %s", ErrWarehouseNotFound, order.WarehouseID)
+		} else if err != nil {
+			return fmt.Errorf("could not fetch warehouse: %w", err)
+		}
+		for i := range order.Items {
+			order.Items[i].WarehouseID = order.WarehouseID
+		}
+		return nil
+	}
+
+	warehouses, err := tx.ListWarehouses(ctx)
+	if err != nil {
+		return fmt.Errorf("could not fetch warehouses: %w", err)
+	}
+	available := make(map[stockKey]int)
+	needed := make(map[string]int)
+	for _, item := range order.Items {
+		if _, seen := needed[item.ProductID]; !seen {
+			if _, err := tx.GetProduct(ctx, item.ProductID); errors.Is(err, errNotFound) {
+				return fmt.Errorf("%w: %s", ErrProductNotFound, item.ProductID)
+			} else if err != nil {
+				return fmt.Errorf("could not fetch product: %w", err)
+			}
+			levels, err := tx.ListStockLevels(ctx, item.ProductID)
+			if err != nil {
+				return fmt.Errorf("could not fetch stock levels: %w", err)
+			}
+			expired, err := expiredStock(ctx, tx, item.ProductID)
+			if err != nil {
+				return err
+			}
+			for _, level := range levels {
+				level.Expired = expired[level.WarehouseID]
+				available[stockKey{level.WarehouseID, item.ProductID}] = availableStock(level)
+			}
+		}
+		needed[item.ProductID] += item.Quantity
+	}
+
+	for _, warehouse := range warehouses {
+		fills := true
+		for productID, quantity := range needed {
+			if available[stockKey{warehouse.ID, productID}] < quantity {
+				fills = false
+				break
+			}
+		}
+		if fills {
+			for i := range order.Items {
+				order.Items[i].WarehouseID = warehouse.ID
+			}
+			return nil
+		}
+	}
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.WarehouseID = ""
+		for _, warehouse := range warehouses {
+			key := stockKey{warehouse.ID, item.ProductID}
+			if available[key] >= item.Quantity {
+				available[key] -= item.Quantity
+				item.WarehouseID = warehouse.ID
+				break
+			}
+		}
+		if item.WarehouseID == "" {
+			return fmt.Errorf("%w for product %s", ErrInsufficientStock, item.ProductID)
+		}
+	}
+	return nil
+}
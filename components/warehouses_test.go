@@ -0,0 +1,152 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+func TestWarehouseStock(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+
+		near, far := h.productID(), h.productID()
+		for i, id := range []string{near, far} {
+			if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: id, Name: "warehouse", Priority: 1000 + i}); err != nil {
+				t.Fatalf("AddWarehouse: %v", err)
+			}
+		}
+		if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: near, Name: "again"}); !errors.Is(err, ErrDuplicateWarehouse) {
+			t.Errorf("AddWarehouse(duplicate) error = %v, want ErrDuplicateWarehouse", err)
+		}
+		if err := inventory.AddWarehouse(ctx, models.Warehouse{ID: h.productID()}); !errors.Is(err, ErrInvalidWarehouse) {
+			t.Errorf("AddWarehouse(no name) error = %v, want ErrInvalidWarehouse", err)
+		}
+
+		productID := h.productID()
+		if err := inventory.AddProduct(ctx, models.Product{ID: productID, Name: "spread", Stock: 2, Price: 1}); err != nil {
+			t.Fatalf("AddProduct: %v", err)
+		}
+		if err := inventory.UpdateStockAt(ctx, near, productID, 3); err != nil {
+			t.Fatalf("UpdateStockAt: %v", err)
+		}
+		if err := inventory.UpdateStockAt(ctx, far, productID, 5); err != nil {
+			t.Fatalf("UpdateStockAt: %v", err)
+		}
+		if err := inventory.UpdateStockAt(ctx, near, productID, -4); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("UpdateStockAt(-4) error = %v, want ErrInsufficientStock", err)
+		}
+		if err := inventory.UpdateStockAt(ctx, near+"-missing", productID, 1); !errors.Is(err, ErrWarehouseNotFound) {
+			t.Errorf("UpdateStockAt(missing warehouse) error = %v, want ErrWarehouseNotFound", err)
+		}
+		if err := inventory.UpdateStockAt(ctx, near, productID+"-missing", 1); !errors.Is(err, ErrProductNotFound) {
+			t.Errorf("UpdateStockAt(missing product) error = %v, want ErrProductNotFound", err)
+		}
+
+		report, err := inventory.CheckStockLevels(ctx, productID)
+		if err != nil {
+			t.Fatalf("CheckStockLevels: %v", err)
+		}
+		want := fmt.Sprintf("10 [{%s 2 0 0 2} {%s 3 0 0 3} {%s 5 0 0 5}]", DefaultWarehouseID, near, far)
+		if got := fmt.Sprint(report.Total, report.Levels); got != want {
+			t.Errorf("CheckStockLevels = %s, want %s", got, want)
+		}
+		if stock, err := inventory.CheckStock(ctx, productID); err != nil || stock != 10 {
+			t.Errorf("CheckStock = %d, %v, want 10", stock, err)
+		}
+
+		// Setting the total changes the default warehouse.
+		stock := 9
+		if _, err := inventory.UpdateProduct(ctx, productID, models.ProductUpdate{Stock: &stock}); err != nil {
+			t.Fatalf("UpdateProduct: %v", err)
+		}
+		stock = 5
+		if _, err := inventory.UpdateProduct(ctx, productID, models.ProductUpdate{Stock: &stock}); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("UpdateProduct(stock below other warehouses) error = %v, want ErrInsufficientStock", err)
+		}
+		report, _ = inventory.CheckStockLevels(ctx, productID)
+		if report.Total != 9 || report.Levels[0].Quantity != 1 {
+			t.Errorf("after setting stock to 9: %+v", report)
+		}
+	})
+}
+
+func TestOrderAllocation(t *testing.T) {
+	forEachStore(t, func(t *testing.T, h storeHarness) {
+		ctx := context.Background()
+		inventory := NewInventoryManagement(h)
+		orders := NewOrderProcessing(inventory, h)
+
+		near, far := h.productID(), h.productID()
+		inventory.AddWarehouse(ctx, models.Warehouse{ID: near, Name: "near", Priority: -1000})
+		inventory.AddWarehouse(ctx, models.Warehouse{ID: far, Name: "far", Priority: -999})
+		a, b := h.productID(), h.productID()
+		for _, id := range []string{a, b} {
+			if err := inventory.AddProduct(ctx, models.Product{ID: id, Name: "allocated", Price: 1}); err != nil {
+				t.Fatalf("AddProduct: %v", err)
+			}
+		}
+		inventory.UpdateStockAt(ctx, near, a, 5)
+		inventory.UpdateStockAt(ctx, far, a, 5)
+		inventory.UpdateStockAt(ctx, far, b, 5)
+
+		place := func(warehouseID string, quantities ...int) (models.Order, error) {
+			order := models.Order{WarehouseID: warehouseID}
+			for i, quantity := range quantities {
+				order.Items = append(order.Items, models.OrderItem{ProductID: []string{a, b}[i], Quantity: quantity})
+			}
+			return orders.CreateOrder(ctx, order)
+		}
+		warehousesOf := func(order models.Order) string {
+			var ids []string
+			for _, item := range order.Items {
+				ids = append(ids, item.WarehouseID)
+			}
+			return fmt.Sprint(ids)
+		}
+
+		// Only far holds both products, so it fills the whole order.
+		order, err := place("", 3, 1)
+		if err != nil || warehousesOf(order) != fmt.Sprint([]string{far, far}) {
+			t.Errorf("order for both products = %s, %v, want both from %s", warehousesOf(order), err, far)
+		}
+		// near has the higher priority for a alone.
+		order, err = place("", 2)
+		if err != nil || warehousesOf(order) != fmt.Sprint([]string{near}) {
+			t.Errorf("order for a = %s, %v, want from %s", warehousesOf(order), err, near)
+		}
+		// far has only 2 of a left and near has no b, so the lines split.
+		order, err = place("", 3, 4)
+		if err != nil || warehousesOf(order) != fmt.Sprint([]string{near, far}) {
+			t.Errorf("split order = %s, %v, want [%s %s]", warehousesOf(order), err, near, far)
+		}
+		if _, err := place(near, 1, 1); !errors.Is(err, ErrInsufficientStock) {
+			t.Errorf("order from %s without b error = %v, want ErrInsufficientStock", near, err)
+		}
+		if _, err := place(near+"-missing", 1); !errors.Is(err, ErrWarehouseNotFound) {
+			t.Errorf("order from missing warehouse error = %v, want ErrWarehouseNotFound", err)
+		}
+
+		chosen, err := place(far, 1)
+		if err != nil || chosen.WarehouseID != far || warehousesOf(chosen) != fmt.Sprint([]string{far}) {
+			t.Fatalf("order from %s = %+v, %v", far, chosen, err)
+		}
+		got, err := orders.GetOrder(ctx, chosen.ID)
+		if err != nil || got.WarehouseID != far || warehousesOf(got) != fmt.Sprint([]string{far}) {
+			t.Errorf("GetOrder = %+v, %v, want warehouse %s", got, err, far)
+		}
+
+		// Cancelling returns stock to the warehouse it came from.
+		if err := orders.CancelOrder(ctx, order.ID); err != nil {
+			t.Fatalf("CancelOrder: %v", err)
+		}
+		report, _ := inventory.CheckStockLevels(ctx, a)
+		if got, want := fmt.Sprint(report.Levels), fmt.Sprintf("[{%s 3 0 0 3} {%s 1 0 0 1}]", near, far); got != want {
+			t.Errorf("stock of a after cancelling = %s, want %s", got, want)
+		}
+	})
+}
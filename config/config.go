@@ -0,0 +1,252 @@
+// Package config loads the application configuration from a YAML file with
+// environment variable overrides.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the name of every environment variable that overrides a
+// configuration key. The rest of the name is the key's YAML path in upper
+// case joined by underscores, so database.host is APP_DATABASE_HOST and
+// http.read_timeout is APP_HTTP_READ_TIMEOUT.
+const EnvPrefix = "APP"
+
+// Config is the complete application configuration.
+type Config struct {
+	Database Database `yaml:"database"`
+	HTTP     HTTP     `yaml:"http"`
+}
+
+// Database configures the database connection and its pool.
+type Database struct {
+	// Driver selects the database: "postgres" for a PostgreSQL server or
+	// "sqlite" for a local file at Path. The connection keys other than
+	// Path apply only to PostgreSQL.
+	Driver          string        `yaml:"driver"`
+	Path            string        `yaml:"path"`
+	User            string        `yaml:"user"`
+	Password        string        `yaml:"password"`
+	Host            string        `yaml:"host"`
+	Port            int           `yaml:"port"`
+	Name            string        `yaml:"name"`
+	SSLMode         string        `yaml:"sslmode"`
+	ConnectTimeout  time.Duration `yaml:"connect_timeout"`
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+	// AutoMigrate applies pending schema migrations at startup.
+	AutoMigrate bool `yaml:"auto_migrate"`
+}
+
+// HTTP configures the HTTP server.
+type HTTP struct {
+	Addr            string        `yaml:"addr"`
+	ReadTimeout     time.Duration `yaml:"read_timeout"`
+	WriteTimeout    time.Duration `yaml:"write_timeout"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Default returns the configuration used for keys missing from the file and
+// the environment.
+func Default() Config {
+	return Config{
+		Database: Database{
+			Driver:          "postgres",
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			ConnectTimeout:  5 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			AutoMigrate:     true,
+		},
+		HTTP: HTTP{
+			Addr:            ":8080",
+			ReadTimeout:     10 * time.Second,
+			WriteTimeout:    30 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			ShutdownTimeout: 15 * time.Second,
+		},
+	}
+}
+
+// Load reads the configuration from the YAML file at path on top of the
+// defaults, applies environment variable overrides and validates the result.
+// Unknown keys in the file are an error.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("could not open config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("could not parse config file %s: %w", path, err)
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem(), EnvPrefix, os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides every field of v that has a matching environment
+// variable, recursing into nested structs.
+func applyEnv(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(key)
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnv(fv, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setField(fv, raw); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField parses raw into the string, integer, boolean or duration field fv.
+func setField(fv reflect.Value, raw string) error {
+	switch {
+	case fv.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(d))
+	case fv.Kind() == reflect.String:
+		fv.SetString(raw)
+	case fv.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(int64(n))
+	case fv.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
+
+// Validate reports every problem with the configuration.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	db := c.Database
+	switch db.Driver {
+	case "postgres":
+		check(db.User != "", "database.user", "is required")
+		check(db.Host != "", "database.host", "is required")
+		check(db.Port > 0 && db.Port <= 65535, "database.port", "must be between 1 and 65535, got %d", db.Port)
+		check(db.Name != "", "database.name", "is required")
+		switch db.SSLMode {
+		case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+		default:
+			check(false, "database.sslmode", "unknown mode %q", db.SSLMode)
+		}
+	case "sqlite":
+		check(db.Path != "", "database.path", "is required")
+	default:
+		check(false, "database.driver", "must be postgres or sqlite, got %q", db.Driver)
+	}
+	check(db.ConnectTimeout >= 0, "database.connect_timeout", "must not be negative")
+	check(db.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	check(db.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns,
+		"database.max_idle_conns", "must not exceed max_open_conns (%d)", db.MaxOpenConns)
+	check(db.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "must not be negative")
+	check(db.ConnMaxIdleTime >= 0, "database.conn_max_idle_time", "must not be negative")
+
+	h := c.HTTP
+	if _, _, err := net.SplitHostPort(h.Addr); err != nil {
+		check(false, "http.addr", "must be host:port, got %q", h.Addr)
+	}
+	check(h.ReadTimeout >= 0, "http.read_timeout", "must not be negative")
+	check(h.WriteTimeout >= 0, "http.write_timeout", "must not be negative")
+	check(h.IdleTimeout >= 0, "http.idle_timeout", "must not be negative")
+	check(h.ShutdownTimeout >= 0, "http.shutdown_timeout", "must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// DSN returns the connection string for the database. SQLite databases are
+// opened with foreign keys enforced, write-ahead logging, and transactions
+// that take the write lock up front so concurrent writers queue for up to
+// the busy timeout instead of failing.
+func (d Database) DSN() string {
+	if d.Driver == "sqlite" {
+		q := url.Values{}
+		q.Add("_pragma", "busy_timeout(5000)")
+		q.Add("_pragma", "foreign_keys(1)")
+		q.Add("_pragma", "journal_mode(WAL)")
+		q.Set("_txlock", "immediate")
+		q.Set("_time_format", "sqlite")
+		return "file:" + d.Path + "?" + q.Encode()
+	}
+
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(d.User, d.Password),
+		Host:   net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:   "/" + d.Name,
+	}
+	q := url.Values{}
+	q.Set("sslmode", d.SSLMode)
+	if d.ConnectTimeout > 0 {
+		q.Set("connect_timeout", strconv.Itoa(int(math.Ceil(d.ConnectTimeout.Seconds()))))
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadCheckedInConfig(t *testing.T) {
+	cfg, err := Load("config.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Database.Name != "serviceweaver" || cfg.HTTP.Addr != ":8080" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+}
+
+func TestLoadAppliesDefaultsAndEnv(t *testing.T) {
+	path := writeConfig(t, `
+database:
+  user: app
+  name: shop
+http:
+  read_timeout: 3s
+`)
+	t.Setenv("APP_DATABASE_HOST", "db.internal")
+	t.Setenv("APP_DATABASE_MAX_OPEN_CONNS", "50")
+	t.Setenv("APP_DATABASE_AUTO_MIGRATE", "false")
+	t.Setenv("APP_HTTP_ADDR", "127.0.0.1:9000")
+	t.Setenv("APP_HTTP_WRITE_TIMEOUT", "1m")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Database.Host != "db.internal" || cfg.Database.MaxOpenConns != 50 || cfg.Database.Port != 5432 || cfg.Database.AutoMigrate {
+		t.Errorf("database = %+v", cfg.Database)
+	}
+	if cfg.HTTP.Addr != "127.0.0.1:9000" || cfg.HTTP.ReadTimeout != 3*time.Second || cfg.HTTP.WriteTimeout != time.Minute {
+		t.Errorf("http = %+v", cfg.HTTP)
+	}
+	if got, want := cfg.Database.DSN(), "postgres://app:@db.internal:5432/shop?connect_timeout=5&sslmode=disable"; got != want {
+		t.Errorf("DSN() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadSQLite(t *testing.T) {
+	path := writeConfig(t, `
+database:
+  driver: sqlite
+  path: /var/lib/shop/shop.db
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := "file:/var/lib/shop/shop.db?_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29&_pragma=journal_mode%28WAL%29&_time_format=sqlite&_txlock=immediate"
+	if got := cfg.Database.DSN(); got != want {
+		t.Errorf("DSN() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name, yaml, env, want string
+	}{
+		{"unknown key", "database:\n  user: a\n  name: b\n  hots: x\n", "", "field hots not found"},
+		{"bad port", "database:\n  user: a\n  name: b\n  port: 70000\n", "", "database.port: must be between 1 and 65535"},
+		{"missing name", "database:\n  user: a\n", "", "database.name: is required"},
+		{"bad addr", "database:\n  user: a\n  name: b\nhttp:\n  addr: localhost\n", "", "http.addr: must be host:port"},
+		{"unknown driver", "database:\n  driver: mysql\n", "", `database.driver: must be postgres or sqlite, got "mysql"`},
+		{"sqlite without path", "database:\n  driver: sqlite\n", "", "database.path: is required"},
+		{"bad env", "database:\n  user: a\n  name: b\n", "APP_HTTP_IDLE_TIMEOUT=soon", "invalid value for APP_HTTP_IDLE_TIMEOUT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				key, value, _ := strings.Cut(tt.env, "=")
+				t.Setenv(key, value)
+			}
+			_, err := Load(writeConfig(t, tt.yaml))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
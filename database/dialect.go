@@ -0,0 +1,43 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Dialect identifies the SQL database the application runs against.
+type Dialect string
+
+const (
+	// Postgres is a PostgreSQL server, the default for multi-node
+	// deployments.
+	Postgres Dialect = "postgres"
+	// SQLite is a local SQLite file for single-node and offline
+	// deployments.
+	SQLite Dialect = "sqlite"
+)
+
+// driverName returns the database/sql driver that serves the dialect.
+func (d Dialect) driverName() (string, error) {
+	switch d {
+	case Postgres:
+		return "postgres", nil
+	case SQLite:
+		return "sqlite", nil
+	}
+	return "", fmt.Errorf("unsupported database driver %q", string(d))
+}
+
+// placeholder matches the numbered $n placeholders queries are written with.
+var placeholder = regexp.MustCompile(`\$(\d+)`)
+
+// Rebind rewrites a query written with Postgres $n placeholders for the
+// dialect. SQLite reads $n as a named parameter numbered by first
+// appearance, so it is rewritten to ?n, which SQLite binds by position like
+// Postgres does.
+func (d Dialect) Rebind(query string) string {
+	if d != SQLite {
+		return query
+	}
+	return placeholder.ReplaceAllString(query, "?$1")
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	postgres, err := Migrations(Postgres)
+	if err != nil {
+		t.Fatalf("Migrations(Postgres): %v", err)
+	}
+	if len(postgres) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	sqlite, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatalf("Migrations(SQLite): %v", err)
+	}
+	if len(sqlite) != len(postgres) {
+		t.Fatalf("%d sqlite migrations, want %d like postgres", len(sqlite), len(postgres))
+	}
+	for i, m := range postgres {
+		if m.Version != i+1 {
+			t.Errorf("migration %d has version %d", i, m.Version)
+		}
+		if sqlite[i].Name != m.Name {
+			t.Errorf("sqlite migration %d is %s, want %s", m.Version, sqlite[i].Name, m.Name)
+		}
+	}
+}
+
+func TestMigratorSQLite(t *testing.T) {
+	db, err := connect(SQLite, "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	migrator, err := NewMigrator(db, SQLite)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up: %v", err)
+	}
+	if err := migrator.Down(ctx, len(migrator.migrations)); err != nil {
+		t.Fatalf("Down: %v", err)
+	}
+	if err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up after Down: %v", err)
+	}
+
+	states, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			t.Errorf("migration %d_%s not applied", state.Version, state.Name)
+		}
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+		want  string
+	}{
+		{"missing down", fstest.MapFS{"m/0001_a.up.sql": file("x")}, "needs both an up and a down file"},
+		{"gap", fstest.MapFS{
+			"m/0001_a.up.sql": file("x"), "m/0001_a.down.sql": file("x"),
+			"m/0003_c.up.sql": file("x"), "m/0003_c.down.sql": file("x"),
+		}, "must be contiguous"},
+		{"bad name", fstest.MapFS{"m/first.up.sql": file("x")}, "invalid migration file name"},
+		{"conflicting names", fstest.MapFS{"m/0001_a.up.sql": file("x"), "m/0001_b.down.sql": file("x")}, "conflicting names"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.files, "m")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loadMigrations() error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
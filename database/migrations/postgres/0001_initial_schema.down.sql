@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS public.metrics;
+DROP TABLE IF EXISTS public.order_status_history;
+DROP TABLE IF EXISTS public.order_items;
+DROP TABLE IF EXISTS public.orders;
+DROP TABLE IF EXISTS public.products;
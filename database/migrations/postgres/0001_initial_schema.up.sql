@@ -0,0 +1,46 @@
+-- The schema previously created at startup by ensureTables. Every statement
+-- tolerates existing objects so deployments created before migrations were
+-- introduced adopt this version without changes.
+
+CREATE TABLE IF NOT EXISTS public.products (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	stock INTEGER NOT NULL,
+	price NUMERIC(10, 2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.orders (
+	id SERIAL PRIMARY KEY,
+	total NUMERIC(10, 2) NOT NULL,
+	status VARCHAR(50) DEFAULT 'Pending' NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.order_items (
+	id SERIAL PRIMARY KEY,
+	order_id INTEGER NOT NULL REFERENCES public.orders (id) ON DELETE CASCADE,
+	product_id VARCHAR(255) NOT NULL,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	unit_price NUMERIC(10, 2) NOT NULL,
+	line_total NUMERIC(10, 2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.order_status_history (
+	id SERIAL PRIMARY KEY,
+	order_id INTEGER NOT NULL REFERENCES public.orders (id) ON DELETE CASCADE,
+	from_status VARCHAR(50),
+	to_status VARCHAR(50) NOT NULL,
+	actor VARCHAR(100) NOT NULL,
+	changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS public.metrics (
+	id SERIAL PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	value NUMERIC(10, 2) NOT NULL,
+	time TIMESTAMP DEFAULT now() NOT NULL
+);
+
+ALTER TABLE public.metrics ADD COLUMN IF NOT EXISTS labels JSONB DEFAULT '{}'::jsonb NOT NULL;
+CREATE INDEX IF NOT EXISTS metrics_name_time_idx ON public.metrics (name, time);
+CREATE INDEX IF NOT EXISTS metrics_labels_idx ON public.metrics USING GIN (labels);
@@ -0,0 +1,6 @@
+-- Fails if any product has a non-numeric ID.
+
+CREATE SEQUENCE IF NOT EXISTS public.products_id_seq OWNED BY public.products.id;
+ALTER TABLE public.products ALTER COLUMN id TYPE INTEGER USING id::integer;
+ALTER TABLE public.products ALTER COLUMN id SET DEFAULT nextval('public.products_id_seq');
+SELECT setval('public.products_id_seq', COALESCE((SELECT MAX(id) FROM public.products), 0) + 1, false);
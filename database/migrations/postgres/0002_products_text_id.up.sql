@@ -0,0 +1,6 @@
+-- Product IDs are chosen by the caller (AddProduct inserts strings such as
+-- SKUs), so products.id must be text rather than a SERIAL integer.
+
+ALTER TABLE public.products ALTER COLUMN id DROP DEFAULT;
+ALTER TABLE public.products ALTER COLUMN id TYPE VARCHAR(255) USING id::text;
+DROP SEQUENCE IF EXISTS public.products_id_seq;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.order_items_product_id_idx;
+DROP INDEX IF EXISTS public.order_items_order_id_idx;
+ALTER TABLE public.order_items DROP CONSTRAINT IF EXISTS order_items_product_id_fkey;
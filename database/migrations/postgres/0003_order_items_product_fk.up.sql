@@ -0,0 +1,46 @@
+-- Orders created before line items existed kept their single product and
+-- quantity on the orders row. Move them into order_items, drop the legacy
+-- columns, and make every order line reference an existing product,
+-- dropping the lines that cannot.
+
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns
+		WHERE table_schema = 'public' AND table_name = 'orders' AND column_name = 'product_id') THEN
+		INSERT INTO public.order_items (order_id, product_id, quantity, unit_price, line_total)
+		SELECT o.id, o.product_id, o.quantity, o.total / o.quantity, o.total
+		FROM public.orders o
+		WHERE o.product_id IS NOT NULL AND o.quantity > 0
+			AND NOT EXISTS (SELECT 1 FROM public.order_items i WHERE i.order_id = o.id);
+
+		ALTER TABLE public.orders DROP COLUMN product_id;
+		ALTER TABLE public.orders DROP COLUMN quantity;
+	END IF;
+END $$;
+
+-- Legacy orders stored free-form product IDs, so some lines may name
+-- products that no longer exist. The foreign key cannot be added while they
+-- remain; report them and drop them.
+DO $$
+DECLARE
+	orphans bigint;
+	missing text;
+BEGIN
+	SELECT count(*), string_agg(DISTINCT i.product_id::text, ', ')
+	INTO orphans, missing
+	FROM public.order_items i
+	WHERE NOT EXISTS (SELECT 1 FROM public.products p WHERE p.id = i.product_id);
+
+	IF orphans > 0 THEN
+		RAISE WARNING 'deleting % order lines that reference missing products: %', orphans, missing;
+		DELETE FROM public.order_items i
+		WHERE NOT EXISTS (SELECT 1 FROM public.products p WHERE p.id = i.product_id);
+	END IF;
+END $$;
+
+ALTER TABLE public.order_items
+	ADD CONSTRAINT order_items_product_id_fkey
+	FOREIGN KEY (product_id) REFERENCES public.products (id);
+
+CREATE INDEX order_items_order_id_idx ON public.order_items (order_id);
+CREATE INDEX order_items_product_id_idx ON public.order_items (product_id);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS public.products_search_idx;
+ALTER TABLE public.products
+	DROP COLUMN IF EXISTS search_vector,
+	DROP COLUMN IF EXISTS tags,
+	DROP COLUMN IF EXISTS description;
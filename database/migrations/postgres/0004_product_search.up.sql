@@ -0,0 +1,17 @@
+-- Products get a description and tags, and a generated tsvector over name,
+-- tags and description for catalog search. Words are indexed with the
+-- simple configuration, without stemming, so that prefix queries match the
+-- words people type. Weights rank name matches above tag matches above
+-- description matches.
+ALTER TABLE public.products
+	ADD COLUMN description TEXT NOT NULL DEFAULT '',
+	ADD COLUMN tags JSONB NOT NULL DEFAULT '[]';
+
+ALTER TABLE public.products
+	ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('simple', name), 'A') ||
+		setweight(to_tsvector('simple', tags), 'B') ||
+		setweight(to_tsvector('simple', description), 'C')
+	) STORED;
+
+CREATE INDEX products_search_idx ON public.products USING GIN (search_vector);
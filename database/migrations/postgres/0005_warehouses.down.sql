@@ -0,0 +1,4 @@
+ALTER TABLE public.order_items DROP COLUMN IF EXISTS warehouse_id;
+ALTER TABLE public.orders DROP COLUMN IF EXISTS warehouse_id;
+DROP TABLE IF EXISTS public.stock_levels;
+DROP TABLE IF EXISTS public.warehouses;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.transfer_lines;
+DROP TABLE IF EXISTS public.transfers;
@@ -0,0 +1,25 @@
+-- Transfers move stock between warehouses. Their lines hold one product
+-- each; the stock is taken from the source when the transfer ships and
+-- added to the destination when it is received.
+
+CREATE TABLE public.transfers (
+	id SERIAL PRIMARY KEY,
+	source_id VARCHAR(255) NOT NULL REFERENCES public.warehouses (id),
+	destination_id VARCHAR(255) NOT NULL REFERENCES public.warehouses (id),
+	status VARCHAR(50) DEFAULT 'Draft' NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	shipped_at TIMESTAMP,
+	received_at TIMESTAMP,
+	CHECK (source_id <> destination_id)
+);
+
+CREATE INDEX transfers_status_idx ON public.transfers (status);
+
+CREATE TABLE public.transfer_lines (
+	transfer_id INTEGER NOT NULL REFERENCES public.transfers (id) ON DELETE CASCADE,
+	product_id VARCHAR(255) NOT NULL REFERENCES public.products (id),
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (transfer_id, product_id)
+);
+
+CREATE INDEX transfer_lines_product_id_idx ON public.transfer_lines (product_id);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.stock_movements;
+DROP FUNCTION IF EXISTS public.reject_stock_movement_change();
@@ -0,0 +1,35 @@
+-- The stock ledger. Every change to a stock level is also recorded here,
+-- and rows are never updated or deleted, so stock at any past time is the
+-- sum of the movements up to it. Movements outlive the products, orders and
+-- warehouses they refer to, so there are no foreign keys. Stock held before
+-- the ledger existed is recorded as an opening movement.
+
+CREATE TABLE public.stock_movements (
+	id BIGSERIAL PRIMARY KEY,
+	product_id VARCHAR(255) NOT NULL,
+	warehouse_id VARCHAR(255) NOT NULL,
+	delta INTEGER NOT NULL CHECK (delta <> 0),
+	reason VARCHAR(50) NOT NULL,
+	reference VARCHAR(255),
+	actor VARCHAR(100) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX stock_movements_product_id_created_at_idx ON public.stock_movements (product_id, created_at);
+
+CREATE FUNCTION public.reject_stock_movement_change() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER stock_movements_append_only
+	BEFORE UPDATE OR DELETE ON public.stock_movements
+	FOR EACH ROW EXECUTE FUNCTION public.reject_stock_movement_change();
+
+CREATE TRIGGER stock_movements_no_truncate
+	BEFORE TRUNCATE ON public.stock_movements
+	FOR EACH STATEMENT EXECUTE FUNCTION public.reject_stock_movement_change();
+
+INSERT INTO public.stock_movements (product_id, warehouse_id, delta, reason, actor)
+SELECT product_id, warehouse_id, quantity, 'opening', 'system' FROM public.stock_levels WHERE quantity > 0;
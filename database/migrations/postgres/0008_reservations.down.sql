@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.reservations;
+ALTER TABLE public.stock_levels DROP COLUMN IF EXISTS reserved;
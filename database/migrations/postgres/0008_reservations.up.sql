@@ -0,0 +1,19 @@
+-- Reservations hold stock for a limited time. stock_levels.reserved is the
+-- quantity held by the warehouse's active reservations, which the
+-- application keeps equal to their sum so that holds, like stock changes,
+-- are single conditional updates of the level.
+
+ALTER TABLE public.stock_levels ADD COLUMN reserved INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE public.stock_levels ADD CONSTRAINT stock_levels_reserved_check CHECK (reserved >= 0 AND reserved <= quantity);
+
+CREATE TABLE public.reservations (
+	id SERIAL PRIMARY KEY,
+	product_id VARCHAR(255) NOT NULL REFERENCES public.products (id) ON DELETE CASCADE,
+	warehouse_id VARCHAR(255) NOT NULL REFERENCES public.warehouses (id),
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	status VARCHAR(50) DEFAULT 'Active' NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX reservations_status_expires_at_idx ON public.reservations (status, expires_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.stock_alerts;
+ALTER TABLE public.products DROP COLUMN IF EXISTS reorder_quantity;
+ALTER TABLE public.products DROP COLUMN IF EXISTS reorder_point;
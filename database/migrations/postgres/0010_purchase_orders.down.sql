@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.purchase_order_lines;
+DROP TABLE IF EXISTS public.purchase_orders;
+DROP TABLE IF EXISTS public.suppliers;
@@ -0,0 +1,37 @@
+-- Purchase orders buy stock from suppliers for a warehouse. Each line holds
+-- one product, with the quantity ordered and the quantity received so far,
+-- which exceeds it when a supplier delivers more than was ordered; receipts
+-- add to the warehouse's stock as they arrive.
+
+CREATE TABLE public.suppliers (
+	id VARCHAR(255) PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	email VARCHAR(255) DEFAULT '' NOT NULL,
+	phone VARCHAR(50) DEFAULT '' NOT NULL,
+	lead_time_days INTEGER DEFAULT 0 NOT NULL CHECK (lead_time_days >= 0)
+);
+
+CREATE TABLE public.purchase_orders (
+	id SERIAL PRIMARY KEY,
+	supplier_id VARCHAR(255) NOT NULL REFERENCES public.suppliers (id),
+	warehouse_id VARCHAR(255) NOT NULL REFERENCES public.warehouses (id),
+	status VARCHAR(50) DEFAULT 'Draft' NOT NULL,
+	expected_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	sent_at TIMESTAMP,
+	closed_at TIMESTAMP
+);
+
+CREATE INDEX purchase_orders_supplier_id_idx ON public.purchase_orders (supplier_id);
+CREATE INDEX purchase_orders_status_idx ON public.purchase_orders (status);
+
+CREATE TABLE public.purchase_order_lines (
+	purchase_order_id INTEGER NOT NULL REFERENCES public.purchase_orders (id) ON DELETE CASCADE,
+	product_id VARCHAR(255) NOT NULL REFERENCES public.products (id),
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	received INTEGER DEFAULT 0 NOT NULL CHECK (received >= 0),
+	unit_cost NUMERIC(10, 2) DEFAULT 0 NOT NULL CHECK (unit_cost >= 0),
+	PRIMARY KEY (purchase_order_id, product_id)
+);
+
+CREATE INDEX purchase_order_lines_product_id_idx ON public.purchase_order_lines (product_id);
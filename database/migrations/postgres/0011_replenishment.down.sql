@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.orders_created_at_idx;
+DROP INDEX IF EXISTS public.products_supplier_id_idx;
+ALTER TABLE public.products DROP COLUMN IF EXISTS safety_stock;
+ALTER TABLE public.products DROP COLUMN IF EXISTS supplier_id;
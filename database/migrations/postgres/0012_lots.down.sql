@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.order_item_lots;
+DROP TABLE IF EXISTS public.lots;
@@ -0,0 +1,28 @@
+-- Lots split the stock of a product at a warehouse into batches with an
+-- expiry date. The application keeps the quantity of a warehouse's Active
+-- lots within its stock level; the rest of the level is stock in no lot.
+-- order_item_lots records the lots each order line took its stock from.
+
+CREATE TABLE public.lots (
+	id SERIAL PRIMARY KEY,
+	product_id VARCHAR(255) NOT NULL REFERENCES public.products (id) ON DELETE CASCADE,
+	warehouse_id VARCHAR(255) NOT NULL REFERENCES public.warehouses (id),
+	lot_number VARCHAR(255) NOT NULL,
+	quantity INTEGER NOT NULL CHECK (quantity >= 0),
+	status VARCHAR(50) DEFAULT 'Active' NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	quarantined_at TIMESTAMP,
+	UNIQUE (product_id, warehouse_id, lot_number)
+);
+
+CREATE INDEX lots_status_expires_at_idx ON public.lots (status, expires_at);
+
+CREATE TABLE public.order_item_lots (
+	order_item_id INTEGER NOT NULL REFERENCES public.order_items (id) ON DELETE CASCADE,
+	lot_id INTEGER NOT NULL REFERENCES public.lots (id) ON DELETE CASCADE,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (order_item_id, lot_id)
+);
+
+CREATE INDEX order_item_lots_lot_id_idx ON public.order_item_lots (lot_id);
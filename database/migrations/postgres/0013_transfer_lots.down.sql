@@ -0,0 +1 @@
+DROP TABLE IF EXISTS public.transfer_lots;
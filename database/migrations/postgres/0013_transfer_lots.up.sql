@@ -0,0 +1,12 @@
+-- transfer_lots records the lots each transfer line took its stock from at
+-- the source warehouse, so that receiving the transfer can add the stock to
+-- the lots of the same number at the destination.
+
+CREATE TABLE public.transfer_lots (
+	transfer_id INTEGER NOT NULL REFERENCES public.transfers (id) ON DELETE CASCADE,
+	lot_id INTEGER NOT NULL REFERENCES public.lots (id) ON DELETE CASCADE,
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (transfer_id, lot_id)
+);
+
+CREATE INDEX transfer_lots_lot_id_idx ON public.transfer_lots (lot_id);
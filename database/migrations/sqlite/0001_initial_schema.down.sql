@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS metrics;
+DROP TABLE IF EXISTS order_status_history;
+DROP TABLE IF EXISTS order_items;
+DROP TABLE IF EXISTS orders;
+DROP TABLE IF EXISTS products;
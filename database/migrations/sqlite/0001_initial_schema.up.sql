@@ -0,0 +1,43 @@
+CREATE TABLE IF NOT EXISTS products (
+	id VARCHAR(255) PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	stock INTEGER NOT NULL,
+	price NUMERIC(10, 2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS orders (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	total NUMERIC(10, 2) NOT NULL,
+	status VARCHAR(50) DEFAULT 'Pending' NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+-- SQLite cannot add a foreign key to an existing table, so the reference to
+-- products that Postgres gains in migration 3 is declared here.
+CREATE TABLE IF NOT EXISTS order_items (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	product_id VARCHAR(255) NOT NULL REFERENCES products (id),
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	unit_price NUMERIC(10, 2) NOT NULL,
+	line_total NUMERIC(10, 2) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	order_id INTEGER NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+	from_status VARCHAR(50),
+	to_status VARCHAR(50) NOT NULL,
+	actor VARCHAR(100) NOT NULL,
+	changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS metrics (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name VARCHAR(100) NOT NULL,
+	value NUMERIC(10, 2) NOT NULL,
+	time TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	labels TEXT DEFAULT '{}' NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS metrics_name_time_idx ON metrics (name, time);
@@ -0,0 +1,2 @@
+-- products.id is created as text in migration 1; nothing to change.
+SELECT 1;
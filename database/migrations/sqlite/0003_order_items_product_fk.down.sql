@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS order_items_product_id_idx;
+DROP INDEX IF EXISTS order_items_order_id_idx;
@@ -0,0 +1,3 @@
+-- The foreign key itself is declared in migration 1.
+CREATE INDEX order_items_order_id_idx ON order_items (order_id);
+CREATE INDEX order_items_product_id_idx ON order_items (product_id);
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS products_search_delete;
+DROP TRIGGER IF EXISTS products_search_update;
+DROP TRIGGER IF EXISTS products_search_insert;
+DROP TABLE IF EXISTS products_search;
+ALTER TABLE products DROP COLUMN tags;
+ALTER TABLE products DROP COLUMN description;
@@ -0,0 +1,29 @@
+-- Products get a description and tags, stored as a JSON array, and an FTS5
+-- index over name, tags and description for catalog search. The index is a
+-- standalone table keyed by product_id, since products has no integer
+-- primary key and its implicit rowids may change on VACUUM; triggers keep it
+-- in step with changes to the indexed columns.
+ALTER TABLE products ADD COLUMN description TEXT NOT NULL DEFAULT '';
+ALTER TABLE products ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+
+CREATE VIRTUAL TABLE products_search USING fts5(
+	product_id UNINDEXED, name, tags, description, tokenize='unicode61'
+);
+
+CREATE TRIGGER products_search_insert AFTER INSERT ON products BEGIN
+	INSERT INTO products_search (product_id, name, tags, description)
+	VALUES (new.id, new.name, new.tags, new.description);
+END;
+
+CREATE TRIGGER products_search_update AFTER UPDATE OF id, name, tags, description ON products BEGIN
+	DELETE FROM products_search WHERE product_id = old.id;
+	INSERT INTO products_search (product_id, name, tags, description)
+	VALUES (new.id, new.name, new.tags, new.description);
+END;
+
+CREATE TRIGGER products_search_delete AFTER DELETE ON products BEGIN
+	DELETE FROM products_search WHERE product_id = old.id;
+END;
+
+INSERT INTO products_search (product_id, name, tags, description)
+SELECT id, name, tags, description FROM products;
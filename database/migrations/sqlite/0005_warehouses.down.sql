@@ -0,0 +1,4 @@
+ALTER TABLE order_items DROP COLUMN warehouse_id;
+ALTER TABLE orders DROP COLUMN warehouse_id;
+DROP TABLE IF EXISTS stock_levels;
+DROP TABLE IF EXISTS warehouses;
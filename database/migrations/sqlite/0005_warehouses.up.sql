@@ -0,0 +1,31 @@
+-- Stock is held per warehouse. products.stock stays as the total across
+-- warehouses, which the application keeps equal to the sum of the product's
+-- stock_levels. Existing stock moves to the main warehouse, which also takes
+-- stock changes that do not name a warehouse.
+
+CREATE TABLE warehouses (
+	id VARCHAR(255) PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	priority INTEGER DEFAULT 0 NOT NULL
+);
+
+INSERT INTO warehouses (id, name) VALUES ('main', 'Main warehouse');
+
+CREATE TABLE stock_levels (
+	warehouse_id VARCHAR(255) NOT NULL REFERENCES warehouses (id),
+	product_id VARCHAR(255) NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+	quantity INTEGER NOT NULL CHECK (quantity >= 0),
+	PRIMARY KEY (warehouse_id, product_id)
+);
+
+CREATE INDEX stock_levels_product_id_idx ON stock_levels (product_id);
+
+INSERT INTO stock_levels (warehouse_id, product_id, quantity)
+SELECT 'main', id, stock FROM products WHERE stock > 0;
+
+-- Orders record the warehouse chosen for them, if any, and every line the
+-- warehouse its stock was taken from. SQLite cannot drop a column that is
+-- part of a foreign key, so unlike Postgres these columns have none.
+ALTER TABLE orders ADD COLUMN warehouse_id VARCHAR(255);
+ALTER TABLE order_items ADD COLUMN warehouse_id VARCHAR(255);
+UPDATE order_items SET warehouse_id = 'main';
@@ -0,0 +1,25 @@
+-- Transfers move stock between warehouses. Their lines hold one product
+-- each; the stock is taken from the source when the transfer ships and
+-- added to the destination when it is received.
+
+CREATE TABLE transfers (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	source_id VARCHAR(255) NOT NULL REFERENCES warehouses (id),
+	destination_id VARCHAR(255) NOT NULL REFERENCES warehouses (id),
+	status VARCHAR(50) DEFAULT 'Draft' NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	shipped_at TIMESTAMP,
+	received_at TIMESTAMP,
+	CHECK (source_id <> destination_id)
+);
+
+CREATE INDEX transfers_status_idx ON transfers (status);
+
+CREATE TABLE transfer_lines (
+	transfer_id INTEGER NOT NULL REFERENCES transfers (id) ON DELETE CASCADE,
+	product_id VARCHAR(255) NOT NULL REFERENCES products (id),
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	PRIMARY KEY (transfer_id, product_id)
+);
+
+CREATE INDEX transfer_lines_product_id_idx ON transfer_lines (product_id);
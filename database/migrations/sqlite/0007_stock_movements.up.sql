@@ -0,0 +1,31 @@
+-- The stock ledger. Every change to a stock level is also recorded here,
+-- and rows are never updated or deleted, so stock at any past time is the
+-- sum of the movements up to it. Movements outlive the products, orders and
+-- warehouses they refer to, so there are no foreign keys. Stock held before
+-- the ledger existed is recorded as an opening movement.
+
+CREATE TABLE stock_movements (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id VARCHAR(255) NOT NULL,
+	warehouse_id VARCHAR(255) NOT NULL,
+	delta INTEGER NOT NULL CHECK (delta <> 0),
+	reason VARCHAR(50) NOT NULL,
+	reference VARCHAR(255),
+	actor VARCHAR(100) NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX stock_movements_product_id_created_at_idx ON stock_movements (product_id, created_at);
+
+CREATE TRIGGER stock_movements_no_update BEFORE UPDATE ON stock_movements
+BEGIN
+	SELECT RAISE(ABORT, 'stock_movements is append-only');
+END;
+
+CREATE TRIGGER stock_movements_no_delete BEFORE DELETE ON stock_movements
+BEGIN
+	SELECT RAISE(ABORT, 'stock_movements is append-only');
+END;
+
+INSERT INTO stock_movements (product_id, warehouse_id, delta, reason, actor)
+SELECT product_id, warehouse_id, quantity, 'opening', 'system' FROM stock_levels WHERE quantity > 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reservations;
+ALTER TABLE stock_levels DROP COLUMN reserved;
@@ -0,0 +1,18 @@
+-- Reservations hold stock for a limited time. stock_levels.reserved is the
+-- quantity held by the warehouse's active reservations, which the
+-- application keeps equal to their sum so that holds, like stock changes,
+-- are single conditional updates of the level.
+
+ALTER TABLE stock_levels ADD COLUMN reserved INTEGER DEFAULT 0 NOT NULL CHECK (reserved >= 0 AND reserved <= quantity);
+
+CREATE TABLE reservations (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id VARCHAR(255) NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+	warehouse_id VARCHAR(255) NOT NULL REFERENCES warehouses (id),
+	quantity INTEGER NOT NULL CHECK (quantity > 0),
+	status VARCHAR(50) DEFAULT 'Active' NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX reservations_status_expires_at_idx ON reservations (status, expires_at);
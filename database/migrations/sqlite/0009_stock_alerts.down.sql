@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS stock_alerts;
+ALTER TABLE products DROP COLUMN reorder_quantity;
+ALTER TABLE products DROP COLUMN reorder_point;
@@ -0,0 +1,19 @@
+-- Products raise a stock alert when their stock falls to their reorder
+-- point. The partial unique index allows one open alert per product, so a
+-- crossing is alerted once however many stock changes follow it.
+
+ALTER TABLE products ADD COLUMN reorder_point INTEGER DEFAULT 0 NOT NULL CHECK (reorder_point >= 0);
+ALTER TABLE products ADD COLUMN reorder_quantity INTEGER DEFAULT 0 NOT NULL CHECK (reorder_quantity >= 0);
+
+CREATE TABLE stock_alerts (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	product_id VARCHAR(255) NOT NULL REFERENCES products (id) ON DELETE CASCADE,
+	stock INTEGER NOT NULL,
+	reorder_point INTEGER NOT NULL,
+	reorder_quantity INTEGER NOT NULL,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	notified_at TIMESTAMP,
+	resolved_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX stock_alerts_open_idx ON stock_alerts (product_id) WHERE resolved_at IS NULL;
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS orders_created_at_idx;
+DROP INDEX IF EXISTS products_supplier_id_idx;
+ALTER TABLE products DROP COLUMN safety_stock;
+ALTER TABLE products DROP COLUMN supplier_id;
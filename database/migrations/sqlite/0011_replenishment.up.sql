@@ -0,0 +1,9 @@
+-- Products name the supplier they are usually bought from and the safety
+-- stock to keep on top of the demand expected during that supplier's lead
+-- time. Deleting a supplier leaves its products without one.
+
+ALTER TABLE products ADD COLUMN supplier_id VARCHAR(255) REFERENCES suppliers (id) ON DELETE SET NULL;
+ALTER TABLE products ADD COLUMN safety_stock INTEGER DEFAULT 0 NOT NULL CHECK (safety_stock >= 0);
+
+CREATE INDEX products_supplier_id_idx ON products (supplier_id);
+CREATE INDEX orders_created_at_idx ON orders (created_at);
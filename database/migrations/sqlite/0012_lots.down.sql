@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS order_item_lots;
+DROP TABLE IF EXISTS lots;
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"service-weaver-app/components"
+)
+
+// errorResponse is the JSON body of an error response. Code is a stable,
+// machine-readable identifier for the kind of error; Message is meant for
+// people and may change.
+type errorResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// errorStatuses maps component errors to their HTTP status and error code.
+// Errors that match none of them are internal server errors.
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{components.ErrProductNotFound, http.StatusNotFound, "product_not_found"},
+	{components.ErrOrderNotFound, http.StatusNotFound, "order_not_found"},
+	{components.ErrWarehouseNotFound, http.StatusNotFound, "warehouse_not_found"},
+	{components.ErrTransferNotFound, http.StatusNotFound, "transfer_not_found"},
+	{components.ErrReservationNotFound, http.StatusNotFound, "reservation_not_found"},
+	{components.ErrSupplierNotFound, http.StatusNotFound, "supplier_not_found"},
+	{components.ErrPurchaseOrderNotFound, http.StatusNotFound, "purchase_order_not_found"},
+	{components.ErrLotNotFound, http.StatusNotFound, "lot_not_found"},
+	{components.ErrDuplicateProduct, http.StatusConflict, "duplicate_product"},
+	{components.ErrProductInUse, http.StatusConflict, "product_in_use"},
+	{components.ErrDuplicateWarehouse, http.StatusConflict, "duplicate_warehouse"},
+	{components.ErrInsufficientStock, http.StatusConflict, "insufficient_stock"},
+	{components.ErrReservationExpired, http.StatusConflict, "reservation_expired"},
+	{components.ErrDuplicateSupplier, http.StatusConflict, "duplicate_supplier"},
+	{components.ErrSupplierInUse, http.StatusConflict, "supplier_in_use"},
+	{components.ErrDuplicateLot, http.StatusConflict, "duplicate_lot"},
+	{components.ErrInvalidTransition, http.StatusUnprocessableEntity, "invalid_transition"},
+	{components.ErrInvalidProduct, http.StatusUnprocessableEntity, "invalid_product"},
+	{components.ErrInvalidOrder, http.StatusUnprocessableEntity, "invalid_order"},
+	{components.ErrInvalidWarehouse, http.StatusUnprocessableEntity, "invalid_warehouse"},
+	{components.ErrInvalidTransfer, http.StatusUnprocessableEntity, "invalid_transfer"},
+	{components.ErrInvalidReservation, http.StatusUnprocessableEntity, "invalid_reservation"},
+	{components.ErrInvalidSupplier, http.StatusUnprocessableEntity, "invalid_supplier"},
+	{components.ErrInvalidPurchaseOrder, http.StatusUnprocessableEntity, "invalid_purchase_order"},
+	{components.ErrInvalidReceipt, http.StatusUnprocessableEntity, "invalid_receipt"},
+	{components.ErrInvalidLot, http.StatusUnprocessableEntity, "invalid_lot"},
+	{components.ErrInvalidQuery, http.StatusBadRequest, "invalid_query"},
+}
+
+// writeError responds with the HTTP status and JSON error body for err.
+// Errors that map to no status are logged and answered with a generic 500,
+// so that their detail does not leak to clients.
+func writeError(w http.ResponseWriter, err error) {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			writeErrorCode(w, e.status, e.code, err.Error())
+			return
+		}
+	}
+	log.Printf("Internal error: %v", err)
+	writeErrorCode(w, http.StatusInternalServerError, "internal", "internal error")
+}
+
+// writeErrorCode responds with status and a JSON error body.
+func writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Code: code, Message: message})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"service-weaver-app/components"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("%w: p1", components.ErrProductNotFound), http.StatusNotFound, "product_not_found"},
+		{fmt.Errorf("%w: 7", components.ErrOrderNotFound), http.StatusNotFound, "order_not_found"},
+		{fmt.Errorf("%w: east", components.ErrWarehouseNotFound), http.StatusNotFound, "warehouse_not_found"},
+		{fmt.Errorf("%w: transfer 3 is Received, not Draft", components.ErrInvalidTransfer), http.StatusUnprocessableEntity, "invalid_transfer"},
+		{fmt.Errorf("%w: p1", components.ErrDuplicateProduct), http.StatusConflict, "duplicate_product"},
+		{fmt.Errorf("%w for product p1", components.ErrInsufficientStock), http.StatusConflict, "insufficient_stock"},
+		{fmt.Errorf("%w: reservation 4 expired", components.ErrReservationExpired), http.StatusConflict, "reservation_expired"},
+		{fmt.Errorf("%w: supplier acme", components.ErrSupplierInUse), http.StatusConflict, "supplier_in_use"},
+		{fmt.Errorf("%w: purchase order 9 has no line for product p1", components.ErrInvalidReceipt), http.StatusUnprocessableEntity, "invalid_receipt"},
+		{fmt.Errorf("%w: 3", components.ErrLotNotFound), http.StatusNotFound, "lot_not_found"},
+		{fmt.Errorf("%w: lot L1 of product p1 at main", components.ErrDuplicateLot), http.StatusConflict, "duplicate_lot"},
+		{&components.InvalidTransitionError{From: "Pending", To: "Shipped"}, http.StatusUnprocessableEntity, "invalid_transition"},
+		{fmt.Errorf("%w: no items", components.ErrInvalidOrder), http.StatusUnprocessableEntity, "invalid_order"},
+		{fmt.Errorf("%w: malformed cursor", components.ErrInvalidQuery), http.StatusBadRequest, "invalid_query"},
+		{errors.New("connection refused"), http.StatusInternalServerError, "internal"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeError(rec, tt.err)
+
+		var body errorResponse
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("%v: decode body: %v", tt.err, err)
+		}
+		message := tt.err.Error()
+		if tt.status == http.StatusInternalServerError {
+			message = "internal error"
+		}
+		if rec.Code != tt.status || body.Code != tt.code || body.Message != message {
+			t.Errorf("%v: got %d %+v, want %d with code %s", tt.err, rec.Code, body, tt.status, tt.code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%v: Content-Type = %q", tt.err, ct)
+		}
+	}
+}
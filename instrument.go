@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"service-weaver-app/components"
+	"service-weaver-app/telemetry"
+)
+
+// registry holds the metrics served at /metrics.
+var registry = telemetry.NewRegistry()
+
+var (
+	httpRequests = registry.NewCounter("http_requests_total",
+		"Total HTTP requests by handler and status code.", "handler", "code")
+	httpDuration = registry.NewHistogram("http_request_duration_seconds",
+		"HTTP request latency by handler.", telemetry.DefaultBuckets, "handler")
+	analyticsEvents = registry.NewCounter("analytics_metric_events_total",
+		"Total metrics tracked through Analytics by name.", "name")
+	analyticsValues = registry.NewCounter("analytics_metric_value_total",
+		"Sum of non-negative metric values tracked through Analytics by name.", "name")
+)
+
+// routes lists every pattern registered through handle.
+var routes []string
+
+// handle registers handler for pattern on the default mux, instrumented with
+// request counts and latencies labelled by pattern.
+func handle(pattern string, handler http.HandlerFunc) {
+	routes = append(routes, pattern)
+	http.Handle(pattern, instrument(pattern, handler))
+}
+
+// instrument records the status code and latency of every request served by
+// next under the given handler label.
+func instrument(name string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		httpRequests.Inc(name, strconv.Itoa(rec.status))
+		httpDuration.Observe(time.Since(start).Seconds(), name)
+	})
+}
+
+// statusRecorder remembers the status code written through it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// registerCollectors registers the database pool statistics and business
+// gauges that are read at scrape time.
+func registerCollectors(db *sql.DB) {
+	telemetry.RegisterDBStats(registry, db)
+
+	registry.GaugeFunc("inventory_stock_units", "Total units in stock across all products.", nil,
+		func() ([]telemetry.Sample, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			total, err := inventory.TotalStock(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return []telemetry.Sample{{Value: float64(total)}}, nil
+		})
+
+	registry.GaugeFunc("orders", "Number of orders by status.", []string{"status"},
+		func() ([]telemetry.Sample, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			counts, err := orders.CountOrdersByStatus(ctx)
+			if err != nil {
+				return nil, err
+			}
+			samples := make([]telemetry.Sample, 0, len(counts))
+			for status, count := range counts {
+				samples = append(samples, telemetry.Sample{LabelValues: []string{status}, Value: float64(count)})
+			}
+			return samples, nil
+		})
+}
+
+// countingAnalytics passes every tracked metric on and feeds the ones
+// Analytics accepts into the analytics counters.
+type countingAnalytics struct {
+	components.Analytics
+}
+
+func (a countingAnalytics) TrackMetric(ctx context.Context, name string, value float64) error {
+	return a.TrackLabeledMetric(ctx, name, value, nil)
+}
+
+func (a countingAnalytics) TrackLabeledMetric(ctx context.Context, name string, value float64, labels map[string]string) error {
+	if err := a.Analytics.TrackLabeledMetric(ctx, name, value, labels); err != nil {
+		return err
+	}
+	analyticsEvents.Inc(name)
+	if value > 0 {
+		analyticsValues.Add(value, name)
+	}
+	return nil
+}
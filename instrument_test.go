@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"service-weaver-app/components"
+)
+
+// rejectingAnalytics accepts every metric except those named rejected.
+type rejectingAnalytics struct {
+	components.Analytics
+}
+
+func (rejectingAnalytics) TrackLabeledMetric(ctx context.Context, name string, value float64, labels map[string]string) error {
+	if name == "rejected" {
+		return errors.New("value out of range")
+	}
+	return nil
+}
+
+func TestCountingAnalytics(t *testing.T) {
+	ctx := context.Background()
+	analytics := countingAnalytics{rejectingAnalytics{}}
+	if err := analytics.TrackMetric(ctx, "accepted", 2.5); err != nil {
+		t.Fatalf("TrackMetric(accepted): %v", err)
+	}
+	if err := analytics.TrackMetric(ctx, "rejected", 1e8); err == nil {
+		t.Fatal("TrackMetric(rejected) succeeded, want the error passed on")
+	}
+
+	var buf bytes.Buffer
+	if err := registry.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	text := buf.String()
+	for _, want := range []string{
+		`analytics_metric_events_total{name="accepted"} 1`,
+		`analytics_metric_value_total{name="accepted"} 2.5`,
+	} {
+		if !strings.Contains(text, want+"\n") {
+			t.Errorf("metrics lack %q:\n%s", want, text)
+		}
+	}
+	// A rejected metric leaves the counters unchanged.
+	if strings.Contains(text, `name="rejected"`) {
+		t.Errorf("metrics count the rejected metric:\n%s", text)
+	}
+}
@@ -1,43 +1,149 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"service-weaver-app/components"
+	"service-weaver-app/config"
 	"service-weaver-app/database"
 	"service-weaver-app/models"
 )
 
 var inventory components.InventoryManagement
 var orders components.OrderProcessing
+var purchasing components.Purchasing
+var analytics components.Analytics
 
 func main() {
+	configPath := flag.String("config", "config/config.yaml", "path to the configuration file")
+	flag.Parse()
+
+	// Load the configuration
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Run the migrate command instead of the server if requested
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg, flag.Args()[1:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	// Initialize the database connection
-	if err := database.InitDB(); err != nil {
+	db, err := database.InitDB(cfg.Database)
+	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
+	defer db.Close()
 
 	// Initialize components with database
-	inventory = components.NewInventoryManagement(database.DB)
-	orders = components.NewOrderProcessing(inventory, database.DB)
+	dialect := database.Dialect(cfg.Database.Driver)
+	store := components.NewSQLStore(db, dialect)
+	inventory = components.NewInventoryManagement(store)
+	orders = components.NewOrderProcessing(inventory, store)
+	purchasing = components.NewPurchasing(store)
+	analyticsImpl := components.NewAnalytics(db, dialect)
+	defer analyticsImpl.Close()
+	analytics = countingAnalytics{analyticsImpl}
+	registerCollectors(db)
 
 	// Define routes
-	http.HandleFunc("/", rootHandler)
-	http.HandleFunc("/add-product-form", addProductFormHandler)
-	http.HandleFunc("/create-order-form", createOrderFormHandler)
-	http.HandleFunc("/view-products", viewProductsHandler)
-	http.HandleFunc("/view-orders", viewOrdersHandler)
-	http.HandleFunc("/add-product", addProductHandler)
-	http.HandleFunc("/create-order", createOrderHandler)
+	registerRoutes()
+
+	server := &http.Server{
+		Addr:         cfg.HTTP.Addr,
+		Handler:      withActor(http.DefaultServeMux),
+		ReadTimeout:  cfg.HTTP.ReadTimeout,
+		WriteTimeout: cfg.HTTP.WriteTimeout,
+		IdleTimeout:  cfg.HTTP.IdleTimeout,
+	}
 
+	// Shut down gracefully on interrupt so in-flight requests finish and
+	// buffered metrics are written.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to shut down server: %v", err)
+		}
+	}()
+
+	// Release reservations whose holds have expired until shutdown.
+	go components.SweepReservations(ctx, inventory, components.ReservationSweepInterval)
+	// Announce products that have fallen to their reorder point.
+	go components.DispatchStockAlerts(ctx, inventory, components.StockAlertInterval, notifyStockAlert)
+	// Take lots out of stock once they expire.
+	go components.SweepExpiredLots(ctx, inventory, components.LotSweepInterval)
 
 	// Start the server
-	log.Println("Server running on http://localhost:8080")
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	log.Printf("Server running on %s", cfg.HTTP.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
+
+// registerRoutes registers every HTTP route on the default mux.
+func registerRoutes() {
+	handle("/", rootHandler)
+	handle("/add-product-form", addProductFormHandler)
+	handle("/create-order-form", createOrderFormHandler)
+	handle("/view-products", viewProductsHandler)
+	handle("/view-orders", viewOrdersHandler)
+	handle("/needs-reorder", needsReorderHandler)
+	handle("/view-suppliers", viewSuppliersHandler)
+	handle("/view-purchase-orders", viewPurchaseOrdersHandler)
+	handle("/create-purchase-order-form", createPurchaseOrderFormHandler)
+	handle("/replenishment", replenishmentHandler)
+	handle("/expiring-lots", expiringLotsHandler)
+	handle("/add-product", addProductHandler)
+	handle("/create-order", createOrderHandler)
+	handle("/update-order-status", updateOrderStatusHandler)
+	handle("/cancel-order", cancelOrderHandler)
+	handle("/order-timeline", orderTimelineHandler)
+	handle("/add-supplier", addSupplierHandler)
+	handle("/create-purchase-order", createPurchaseOrderHandler)
+	handle("/send-purchase-order", sendPurchaseOrderFormHandler)
+	handle("/receive-purchase-order", receivePurchaseOrderFormHandler)
+	handle("/close-purchase-order", closePurchaseOrderFormHandler)
+	handle("/create-suggested-purchase-orders", createSuggestedPurchaseOrdersFormHandler)
+	handle("/add-lot", addLotHandler)
+	handle("/quarantine-lot", quarantineLotFormHandler)
+	handle("/quarantine-expired-lots", quarantineExpiredLotsFormHandler)
+	handle("/api/metrics", metricsHandler)
+	handle("/api/metrics/aggregate", aggregateMetricsHandler)
+	handle("GET /api/openapi.json", openAPIHandler)
+	handle("/metrics", registry.Handler().ServeHTTP)
+	registerAPI()
+}
+
+// withActor records the caller named in the X-Actor header as the actor for
+// changes made while handling the request.
+func withActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := r.Header.Get("X-Actor"); actor != "" {
+			r = r.WithContext(components.WithActor(r.Context(), actor))
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 // Handlers
@@ -67,6 +173,12 @@ func addProductHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		product.ID = r.FormValue("id")
 		product.Name = r.FormValue("name")
+		product.Description = r.FormValue("description")
+		for _, tag := range strings.Split(r.FormValue("tags"), ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				product.Tags = append(product.Tags, tag)
+			}
+		}
 		product.Stock, err = strconv.Atoi(r.FormValue("stock"))
 		if err != nil {
 			http.Error(w, "Invalid stock value", http.StatusBadRequest)
@@ -77,6 +189,25 @@ func addProductHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid price value", http.StatusBadRequest)
 			return
 		}
+		if value := r.FormValue("reorder_point"); value != "" {
+			if product.ReorderPoint, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid reorder point value", http.StatusBadRequest)
+				return
+			}
+		}
+		if value := r.FormValue("reorder_quantity"); value != "" {
+			if product.ReorderQuantity, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid reorder quantity value", http.StatusBadRequest)
+				return
+			}
+		}
+		product.SupplierID = r.FormValue("supplier_id")
+		if value := r.FormValue("safety_stock"); value != "" {
+			if product.SafetyStock, err = strconv.Atoi(value); err != nil {
+				http.Error(w, "Invalid safety stock value", http.StatusBadRequest)
+				return
+			}
+		}
 	} else {
 		// Handle JSON payload
 		if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
@@ -87,7 +218,7 @@ func addProductHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Add product to the database via the inventory component
 	if err := inventory.AddProduct(r.Context(), product); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
 		return
 	}
 
@@ -95,6 +226,17 @@ func addProductHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Product added successfully"})
 }
 
+// createOrderRequest is the JSON body accepted by /create-order. Items holds
+// the order lines and WarehouseID optionally chooses the warehouse to fill
+// them from; ProductID and Quantity are still accepted as a shorthand for a
+// single-line order.
+type createOrderRequest struct {
+	Items       []models.OrderItem `json:"items"`
+	WarehouseID string             `json:"warehouse_id"`
+	ProductID   string             `json:"product_id"`
+	Quantity    int                `json:"quantity"`
+}
+
 // Create order handler for processing orders
 func createOrderHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -111,59 +253,766 @@ func createOrderHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Invalid form data", http.StatusBadRequest)
 			return
 		}
-		order.ProductID = r.FormValue("product_id")
-		order.Quantity, err = strconv.Atoi(r.FormValue("quantity"))
-		if err != nil {
-			http.Error(w, "Invalid quantity value", http.StatusBadRequest)
+		order.WarehouseID = r.PostForm.Get("warehouse_id")
+		productIDs := r.PostForm["product_id"]
+		quantities := r.PostForm["quantity"]
+		if len(productIDs) != len(quantities) {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
 			return
 		}
+		for i, productID := range productIDs {
+			// Blank rows in the form are unused lines.
+			if productID == "" && quantities[i] == "" {
+				continue
+			}
+			quantity, err := strconv.Atoi(quantities[i])
+			if err != nil {
+				http.Error(w, "Invalid quantity value", http.StatusBadRequest)
+				return
+			}
+			order.Items = append(order.Items, models.OrderItem{ProductID: productID, Quantity: quantity})
+		}
 	} else {
 		// Handle JSON payload
-		if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
+		var req createOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "Invalid request body", http.StatusBadRequest)
 			return
 		}
+		order.Items = req.Items
+		order.WarehouseID = req.WarehouseID
+		if len(order.Items) == 0 && req.ProductID != "" {
+			order.Items = []models.OrderItem{{ProductID: req.ProductID, Quantity: req.Quantity}}
+		}
 	}
 
 	// Create the order via the orders component
 	createdOrder, err := orders.CreateOrder(r.Context(), order)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeError(w, err)
+		return
+	}
+
+	trackOrderCreated(r.Context(), createdOrder)
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createdOrder)
+}
+
+// Update order status handler for moving an order through its lifecycle
+func updateOrderStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		OrderID string `json:"order_id"`
+		Status  string `json:"status"`
+	}
+
+	// Handle form data
+	if r.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		req.OrderID = r.FormValue("order_id")
+		req.Status = r.FormValue("status")
+	} else {
+		// Handle JSON payload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := orders.UpdateOrderStatus(r.Context(), req.OrderID, req.Status)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"message": "Order status updated successfully"})
+}
+
+// Cancel order handler for cancelling an order and restocking its items
+func cancelOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		OrderID string `json:"order_id"`
+	}
+
+	// Handle form data
+	isForm := r.Header.Get("Content-Type") == "application/x-www-form-urlencoded"
+	if isForm {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "Invalid form data", http.StatusBadRequest)
+			return
+		}
+		req.OrderID = r.FormValue("order_id")
+	} else {
+		// Handle JSON payload
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := orders.CancelOrder(r.Context(), req.OrderID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// The cancel button on the orders page posts a form; send it back there.
+	if isForm {
+		http.Redirect(w, r, "/view-orders", http.StatusSeeOther)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"message": "Order cancelled successfully"})
+}
+
+// Order timeline handler for listing an order's status changes
+func orderTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	timeline, err := orders.GetOrderTimeline(r.Context(), r.URL.Query().Get("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(timeline)
+}
+
+// Metrics handler for querying tracked metrics by name and time range
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := models.MetricQuery{Name: r.URL.Query().Get("name")}
+	var err error
+	if query.Labels, err = parseLabelParams(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.From, err = parseTimeParam(r, "from"); err != nil {
+		http.Error(w, "Invalid from value", http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseTimeParam(r, "to"); err != nil {
+		http.Error(w, "Invalid to value", http.StatusBadRequest)
+		return
+	}
+
+	metrics, err := analytics.GetMetrics(r.Context(), query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(metrics)
+}
+
+// Aggregate metrics handler for serving bucketed metric aggregates
+func aggregateMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := models.AggregateQuery{
+		Name:    r.URL.Query().Get("name"),
+		Bucket:  r.URL.Query().Get("bucket"),
+		GroupBy: r.URL.Query()["group_by"],
+	}
+	if query.Name == "" {
+		http.Error(w, "Missing name value", http.StatusBadRequest)
+		return
+	}
+	if query.Bucket == "" {
+		query.Bucket = models.BucketHour
+	}
+	var err error
+	if query.Labels, err = parseLabelParams(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.From, err = parseTimeParam(r, "from"); err != nil {
+		http.Error(w, "Invalid from value", http.StatusBadRequest)
+		return
+	}
+	if query.To, err = parseTimeParam(r, "to"); err != nil {
+		http.Error(w, "Invalid to value", http.StatusBadRequest)
+		return
+	}
+	switch query.Bucket {
+	case models.BucketMinute, models.BucketHour, models.BucketDay:
+	default:
+		http.Error(w, "Invalid bucket value", http.StatusBadRequest)
+		return
+	}
+
+	points, err := analytics.AggregateMetrics(r.Context(), query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(points)
+}
+
+// parseTimeParam parses an optional RFC 3339 query parameter, returning the
+// zero time when it is absent.
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseLabelParams parses repeated label=key:value query parameters into a
+// label filter.
+func parseLabelParams(r *http.Request) (map[string]string, error) {
+	params := r.URL.Query()["label"]
+	if len(params) == 0 {
+		return nil, nil
+	}
+	labels := make(map[string]string, len(params))
+	for _, param := range params {
+		key, value, ok := strings.Cut(param, ":")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("Invalid label value %q", param)
+		}
+		labels[key] = value
+	}
+	return labels, nil
+}
+
+// trackMetric records a metric, logging rather than failing the request if
+// it cannot be tracked.
+func trackMetric(ctx context.Context, name string, value float64) {
+	trackLabeledMetric(ctx, name, value, nil)
+}
+
+// trackLabeledMetric records a metric with labels, logging rather than
+// failing the request if it cannot be tracked.
+func trackLabeledMetric(ctx context.Context, name string, value float64, labels map[string]string) {
+	if err := analytics.TrackLabeledMetric(ctx, name, value, labels); err != nil {
+		log.Printf("Failed to track metric %s: %v", name, err)
+	}
+}
+
+// notifyStockAlert announces that a product has fallen to its reorder
+// point.
+func notifyStockAlert(ctx context.Context, alert models.StockAlert) {
+	log.Printf("Stock alert: product %s is down to %d units, at or below its reorder point of %d; reorder %d",
+		alert.ProductID, alert.Stock, alert.ReorderPoint, alert.ReorderQuantity)
+	trackLabeledMetric(ctx, "stock_alerts", 1, map[string]string{"product_id": alert.ProductID})
+}
+
+func viewProductsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseProductQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// A search replaces the listing with the best matches, unpaged.
+	var page models.ProductPage
+	if text := r.URL.Query().Get("q"); text != "" {
+		var matches []models.ProductMatch
+		matches, err = inventory.SearchProducts(r.Context(), text, q.Limit)
+		for _, match := range matches {
+			page.Products = append(page.Products, match.Product)
+		}
+	} else {
+		page, err = inventory.GetProducts(r.Context(), q)
+	}
+	if errors.Is(err, components.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch products: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	viewProductsTemplate.Execute(w, map[string]interface{}{
+		"Products": page.Products,
+		"Params":   r.URL.Query(),
+		"Sorts":    productSorts,
+		"Next":     pageLink(r, page.NextCursor),
+		"Prev":     pageLink(r, page.PrevCursor),
+	})
+}
+
+func viewOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseOrderQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := orders.GetOrders(r.Context(), q)
+	if errors.Is(err, components.ErrInvalidQuery) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch orders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	viewOrdersTemplate.Execute(w, map[string]interface{}{
+		"Orders":   page.Orders,
+		"Params":   r.URL.Query(),
+		"Sorts":    orderSorts,
+		"Statuses": orderStatuses,
+		"Next":     pageLink(r, page.NextCursor),
+		"Prev":     pageLink(r, page.PrevCursor),
+	})
+}
+
+// needsReorderHandler lists the products with an open stock alert.
+func needsReorderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	alerts, err := inventory.GetStockAlerts(r.Context(), models.StockAlertFilter{Open: true})
+	if err != nil {
+		http.Error(w, "Failed to fetch stock alerts: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	needsReorderTemplate.Execute(w, alerts)
+}
+
+// viewSuppliersHandler lists the suppliers, with a form to add one.
+func viewSuppliersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	suppliers, err := purchasing.GetSuppliers(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch suppliers: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	viewSuppliersTemplate.Execute(w, suppliers)
+}
+
+// Add supplier handler for the form on the suppliers page
+func addSupplierHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	supplier := models.Supplier{
+		ID:    r.FormValue("id"),
+		Name:  r.FormValue("name"),
+		Email: r.FormValue("email"),
+		Phone: r.FormValue("phone"),
+	}
+	if value := r.FormValue("lead_time_days"); value != "" {
+		var err error
+		if supplier.LeadTimeDays, err = strconv.Atoi(value); err != nil {
+			http.Error(w, "Invalid lead time value", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := purchasing.AddSupplier(r.Context(), supplier); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/view-suppliers", http.StatusSeeOther)
+}
+
+// viewPurchaseOrdersHandler lists the purchase orders, optionally those in
+// one status, with buttons to send, receive and close them.
+func viewPurchaseOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	filter := models.PurchaseOrderFilter{Status: params.Get("status"), SupplierID: params.Get("supplier_id")}
+	pos, err := purchasing.GetPurchaseOrders(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Failed to fetch purchase orders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	viewPurchaseOrdersTemplate.Execute(w, map[string]interface{}{
+		"PurchaseOrders": pos,
+		"Params":         params,
+		"Statuses":       purchaseOrderStatuses,
+	})
+}
+
+// Create purchase order form handler
+func createPurchaseOrderFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	suppliers, err := purchasing.GetSuppliers(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch suppliers: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	warehouses, err := inventory.GetWarehouses(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch warehouses: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	createPurchaseOrderFormTemplate.Execute(w, map[string]interface{}{
+		"Suppliers":  suppliers,
+		"Warehouses": warehouses,
+	})
+}
+
+// Create purchase order handler for the purchase order form. The form sends
+// one product_id, quantity and unit_cost per line; blank lines are skipped.
+func createPurchaseOrderHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	po := models.PurchaseOrder{
+		SupplierID:  r.PostForm.Get("supplier_id"),
+		WarehouseID: r.PostForm.Get("warehouse_id"),
+	}
+	if value := r.PostForm.Get("expected_at"); value != "" {
+		expectedAt, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "Invalid expected date", http.StatusBadRequest)
+			return
+		}
+		po.ExpectedAt = &expectedAt
+	}
+	productIDs := r.PostForm["product_id"]
+	quantities := r.PostForm["quantity"]
+	unitCosts := r.PostForm["unit_cost"]
+	if len(productIDs) != len(quantities) || len(productIDs) != len(unitCosts) {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	for i, productID := range productIDs {
+		if productID == "" && quantities[i] == "" {
+			continue
+		}
+		quantity, err := strconv.Atoi(quantities[i])
+		if err != nil {
+			http.Error(w, "Invalid quantity value", http.StatusBadRequest)
+			return
+		}
+		var unitCost float64
+		if unitCosts[i] != "" {
+			if unitCost, err = strconv.ParseFloat(unitCosts[i], 64); err != nil {
+				http.Error(w, "Invalid unit cost value", http.StatusBadRequest)
+				return
+			}
+		}
+		po.Lines = append(po.Lines, models.PurchaseOrderLine{ProductID: productID, Quantity: quantity, UnitCost: unitCost})
+	}
+
+	if _, err := purchasing.CreatePurchaseOrder(r.Context(), po); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/view-purchase-orders", http.StatusSeeOther)
+}
+
+// Send purchase order handler for the send button on the purchase orders
+// page
+func sendPurchaseOrderFormHandler(w http.ResponseWriter, r *http.Request) {
+	purchaseOrderAction(w, r, purchasing.SendPurchaseOrder)
+}
+
+// Close purchase order handler for the close button on the purchase orders
+// page
+func closePurchaseOrderFormHandler(w http.ResponseWriter, r *http.Request) {
+	purchaseOrderAction(w, r, purchasing.ClosePurchaseOrder)
+}
+
+// purchaseOrderAction applies action to the purchase order named by the
+// posted id and returns to the purchase orders page.
+func purchaseOrderAction(w http.ResponseWriter, r *http.Request, action func(ctx context.Context, poID string) (models.PurchaseOrder, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	if _, err := action(r.Context(), r.FormValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/view-purchase-orders", http.StatusSeeOther)
+}
+
+// replenishmentHandler reports the suggested purchases over the demand
+// window and supplier in the query, with a button to order them as Draft
+// purchase orders.
+func replenishmentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseReplenishmentQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	suggestions, err := purchasing.SuggestReplenishment(r.Context(), q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	replenishmentTemplate.Execute(w, map[string]interface{}{
+		"Suggestions":   suggestions,
+		"Params":        r.URL.Query(),
+		"DefaultWindow": components.DefaultDemandWindowDays,
+	})
+}
+
+// Create suggested purchase orders handler for the button on the
+// replenishment page, which posts the page's query in its URL
+func createSuggestedPurchaseOrdersFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	q, err := parseReplenishmentQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := purchasing.CreateSuggestedPurchaseOrders(r.Context(), q); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/view-purchase-orders?status="+models.PurchaseOrderStatusDraft, http.StatusSeeOther)
+}
+
+// expiringLotsHandler reports the Active lots expiring within the days in
+// the query, with buttons to quarantine them and a form to receive a lot.
+func expiringLotsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	days, err := parseExpiringDays(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	lots, err := inventory.GetExpiringLots(r.Context(), days)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	expiringLotsTemplate.Execute(w, map[string]interface{}{
+		"Lots":             lots,
+		"Params":           r.URL.Query(),
+		"Now":              time.Now(),
+		"DefaultDays":      components.DefaultExpiringDays,
+		"DefaultWarehouse": components.DefaultWarehouseID,
+	})
+}
+
+// Add lot handler for the form on the expiring lots page. The lot expires
+// at the start of its expires_on date, in UTC.
+func addLotHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+
+	quantity, err := strconv.Atoi(r.FormValue("quantity"))
+	if err != nil {
+		http.Error(w, "Invalid quantity value", http.StatusBadRequest)
+		return
+	}
+	expiresAt, err := time.Parse("2006-01-02", r.FormValue("expires_on"))
+	if err != nil {
+		http.Error(w, "Invalid expiry date", http.StatusBadRequest)
+		return
+	}
+	lot := models.Lot{
+		ProductID:   r.FormValue("product_id"),
+		WarehouseID: r.FormValue("warehouse_id"),
+		LotNumber:   r.FormValue("lot_number"),
+		Quantity:    quantity,
+		ExpiresAt:   expiresAt,
+	}
+	if _, err := inventory.AddLot(r.Context(), lot); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/expiring-lots", http.StatusSeeOther)
+}
+
+// Quarantine lot handler for the quarantine buttons on the expiring lots
+// page
+func quarantineLotFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
 		return
 	}
-
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(createdOrder)
+	if _, err := inventory.QuarantineLot(r.Context(), r.FormValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/expiring-lots", http.StatusSeeOther)
 }
 
-func viewProductsHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// Quarantine expired lots handler for the button on the expiring lots page
+func quarantineExpiredLotsFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
-
-	products, err := inventory.GetProducts(r.Context())
-	if err != nil {
-		http.Error(w, "Failed to fetch products: "+err.Error(), http.StatusInternalServerError)
+	if _, err := inventory.QuarantineExpiredLots(r.Context()); err != nil {
+		writeError(w, err)
 		return
 	}
-
-	viewProductsTemplate.Execute(w, products)
+	http.Redirect(w, r, "/expiring-lots", http.StatusSeeOther)
 }
 
-func viewOrdersHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+// Receive purchase order handler for the receive form on the purchase
+// orders page. The form sends a product_id, quantity, lot_number and
+// expires_on for each line still outstanding; lines left blank or zero were
+// not delivered, and lines with a lot number arrive as a lot.
+func receivePurchaseOrderFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
 
-	allOrders, err := orders.GetOrders(r.Context())
-	if err != nil {
-		http.Error(w, "Failed to fetch orders: "+err.Error(), http.StatusInternalServerError)
+	productIDs := r.PostForm["product_id"]
+	quantities := r.PostForm["quantity"]
+	lotNumbers := r.PostForm["lot_number"]
+	expiries := r.PostForm["expires_on"]
+	if len(productIDs) != len(quantities) || len(lotNumbers) != len(quantities) || len(expiries) != len(quantities) {
+		http.Error(w, "Invalid form data", http.StatusBadRequest)
+		return
+	}
+	var lines []models.ReceiptLine
+	for i, productID := range productIDs {
+		if quantities[i] == "" || quantities[i] == "0" {
+			continue
+		}
+		quantity, err := strconv.Atoi(quantities[i])
+		if err != nil {
+			http.Error(w, "Invalid quantity value", http.StatusBadRequest)
+			return
+		}
+		line := models.ReceiptLine{ProductID: productID, Quantity: quantity, LotNumber: lotNumbers[i]}
+		if expiries[i] != "" {
+			expiresAt, err := time.Parse("2006-01-02", expiries[i])
+			if err != nil {
+				http.Error(w, "Invalid expiry date", http.StatusBadRequest)
+				return
+			}
+			line.ExpiresAt = &expiresAt
+		}
+		lines = append(lines, line)
+	}
+
+	if _, err := purchasing.ReceivePurchaseOrder(r.Context(), r.PostForm.Get("id"), lines); err != nil {
+		writeError(w, err)
 		return
 	}
+	http.Redirect(w, r, "/view-purchase-orders", http.StatusSeeOther)
+}
+
+// sortOption is a choice in the sort menu of a listing page.
+type sortOption struct {
+	Value string
+	Label string
+}
+
+var productSorts = []sortOption{
+	{"", "ID"},
+	{"name", "Name"},
+	{"stock", "Stock, lowest first"},
+	{"-stock", "Stock, highest first"},
+	{"price", "Price, lowest first"},
+	{"-price", "Price, highest first"},
+}
+
+var orderSorts = []sortOption{
+	{"", "Oldest first"},
+	{"-created_at", "Newest first"},
+	{"total", "Total, lowest first"},
+	{"-total", "Total, highest first"},
+}
+
+var orderStatuses = []string{
+	models.OrderStatusPending,
+	models.OrderStatusConfirmed,
+	models.OrderStatusPicked,
+	models.OrderStatusShipped,
+	models.OrderStatusDelivered,
+	models.OrderStatusCancelled,
+	models.OrderStatusReturned,
+}
 
-	viewOrdersTemplate.Execute(w, allOrders)
+var purchaseOrderStatuses = []string{
+	models.PurchaseOrderStatusDraft,
+	models.PurchaseOrderStatusSent,
+	models.PurchaseOrderStatusPartiallyReceived,
+	models.PurchaseOrderStatusReceived,
+	models.PurchaseOrderStatusClosed,
 }
 
 // Add product form handler
@@ -172,7 +1021,12 @@ func addProductFormHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
-	addProductFormTemplate.Execute(w, nil)
+	suppliers, err := purchasing.GetSuppliers(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch suppliers: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	addProductFormTemplate.Execute(w, suppliers)
 }
 
 // Create order form handler
@@ -181,7 +1035,12 @@ func createOrderFormHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
 		return
 	}
-	createOrderFormTemplate.Execute(w, nil)
+	warehouses, err := inventory.GetWarehouses(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to fetch warehouses: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	createOrderFormTemplate.Execute(w, warehouses)
 }
 
 var viewOrdersTemplate = template.Must(template.New("viewOrders").Parse(`
@@ -194,25 +1053,367 @@ var viewOrdersTemplate = template.Must(template.New("viewOrders").Parse(`
 <body>
     <div class="container mt-4">
         <h1>Order List</h1>
+        <form class="row g-2 mb-3" method="GET">
+            <div class="col-md-2">
+                <select name="status" class="form-select">
+                    <option value="">Any status</option>
+                    {{range $status := .Statuses}}
+                    <option {{if eq ($.Params.Get "status") $status}}selected{{end}}>{{$status}}</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="col-md-2"><input type="text" name="product_id" class="form-control" placeholder="Product ID" value="{{.Params.Get "product_id"}}"></div>
+            <div class="col-md-2"><input type="text" name="from" class="form-control" placeholder="From (RFC 3339)" value="{{.Params.Get "from"}}"></div>
+            <div class="col-md-2"><input type="text" name="to" class="form-control" placeholder="To (RFC 3339)" value="{{.Params.Get "to"}}"></div>
+            <div class="col-md-2">
+                <select name="sort" class="form-select">
+                    {{range $sort := .Sorts}}
+                    <option value="{{$sort.Value}}" {{if eq ($.Params.Get "sort") $sort.Value}}selected{{end}}>{{$sort.Label}}</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="col-md-2"><button type="submit" class="btn btn-primary">Filter</button></div>
+        </form>
         <table class="table table-striped">
             <thead>
                 <tr>
                     <th>Order ID</th>
-                    <th>Product ID</th>
-                    <th>Quantity</th>
+                    <th>Items</th>
                     <th>Total</th>
                     <th>Status</th>
+                    <th>Created</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Orders}}
+                <tr>
+                    <td>{{.ID}}</td>
+                    <td>
+                        <ul class="list-unstyled mb-0">
+                            {{range .Items}}
+                            <li>{{.ProductID}} &times; {{.Quantity}} @ {{.UnitPrice}} = {{.LineTotal}}</li>
+                            {{end}}
+                        </ul>
+                    </td>
+                    <td>{{.Total}}</td>
+                    <td>{{.Status}}</td>
+                    <td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td>
+                    <td>
+                        {{if or (eq .Status "Pending") (eq .Status "Confirmed") (eq .Status "Picked")}}
+                        <form action="/cancel-order" method="POST">
+                            <input type="hidden" name="order_id" value="{{.ID}}">
+                            <button type="submit" class="btn btn-sm btn-outline-danger">Cancel</button>
+                        </form>
+                        {{end}}
+                    </td>
+                </tr>
+                {{end}}
+            </tbody>
+        </table>
+        <nav class="d-flex justify-content-between">
+            {{if .Prev}}<a class="btn btn-outline-secondary" href="{{.Prev}}">&laquo; Previous</a>{{else}}<span></span>{{end}}
+            {{if .Next}}<a class="btn btn-outline-secondary" href="{{.Next}}">Next &raquo;</a>{{end}}
+        </nav>
+    </div>
+</body>
+</html>
+`))
+var needsReorderTemplate = template.Must(template.New("needsReorder").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Needs Reorder</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha3/dist/css/bootstrap.min.css" rel="stylesheet">
+</head>
+<body>
+    <div class="container mt-4">
+        <h1>Needs Reorder</h1>
+        <table class="table table-striped">
+            <thead>
+                <tr>
+                    <th>Product ID</th>
+                    <th>Stock</th>
+                    <th>Reorder Point</th>
+                    <th>Reorder Quantity</th>
+                    <th>Since</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .}}
+                <tr>
+                    <td>{{.ProductID}}</td>
+                    <td>{{.Stock}}</td>
+                    <td>{{.ReorderPoint}}</td>
+                    <td>{{.ReorderQuantity}}</td>
+                    <td>{{.CreatedAt.Format "2006-01-02 15:04"}}</td>
+                </tr>
+                {{else}}
+                <tr><td colspan="5">No product is at or below its reorder point.</td></tr>
+                {{end}}
+            </tbody>
+        </table>
+    </div>
+</body>
+</html>
+`))
+var viewSuppliersTemplate = template.Must(template.New("viewSuppliers").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Suppliers</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha3/dist/css/bootstrap.min.css" rel="stylesheet">
+</head>
+<body>
+    <div class="container mt-4">
+        <h1>Suppliers</h1>
+        <table class="table table-striped">
+            <thead>
+                <tr>
+                    <th>Supplier ID</th>
+                    <th>Name</th>
+                    <th>Email</th>
+                    <th>Phone</th>
+                    <th>Lead Time (days)</th>
+                    <th></th>
                 </tr>
             </thead>
             <tbody>
                 {{range .}}
                 <tr>
                     <td>{{.ID}}</td>
+                    <td>{{.Name}}</td>
+                    <td>{{.Email}}</td>
+                    <td>{{.Phone}}</td>
+                    <td>{{.LeadTimeDays}}</td>
+                    <td><a href="/view-purchase-orders?supplier_id={{.ID}}">Purchase orders</a></td>
+                </tr>
+                {{else}}
+                <tr><td colspan="6">No suppliers yet.</td></tr>
+                {{end}}
+            </tbody>
+        </table>
+        <h2>Add Supplier</h2>
+        <form class="row g-2" action="/add-supplier" method="POST">
+            <div class="col-md-2"><input type="text" name="id" class="form-control" placeholder="Supplier ID" required></div>
+            <div class="col-md-3"><input type="text" name="name" class="form-control" placeholder="Name" required></div>
+            <div class="col-md-2"><input type="email" name="email" class="form-control" placeholder="Email"></div>
+            <div class="col-md-2"><input type="text" name="phone" class="form-control" placeholder="Phone"></div>
+            <div class="col-md-2"><input type="number" min="0" name="lead_time_days" class="form-control" placeholder="Lead time (days)"></div>
+            <div class="col-md-1"><button type="submit" class="btn btn-primary">Add</button></div>
+        </form>
+    </div>
+</body>
+</html>
+`))
+
+var replenishmentTemplate = template.Must(template.New("replenishment").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Replenishment</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha3/dist/css/bootstrap.min.css" rel="stylesheet">
+</head>
+<body>
+    <div class="container mt-4">
+        <h1>Replenishment</h1>
+        <form class="row g-2 mb-3" method="GET">
+            <div class="col-md-3"><input type="number" min="1" name="window_days" class="form-control" placeholder="Demand window ({{.DefaultWindow}} days)" value="{{.Params.Get "window_days"}}"></div>
+            <div class="col-md-3"><input type="text" name="supplier_id" class="form-control" placeholder="Supplier ID" value="{{.Params.Get "supplier_id"}}"></div>
+            <div class="col-md-2"><button type="submit" class="btn btn-primary">Update</button></div>
+        </form>
+        <table class="table table-striped">
+            <thead>
+                <tr>
+                    <th>Product ID</th>
+                    <th>Supplier</th>
+                    <th>Daily Demand</th>
+                    <th>Lead Time (days)</th>
+                    <th>Lead Time Demand</th>
+                    <th>Safety Stock</th>
+                    <th>Stock</th>
+                    <th>In Transit</th>
+                    <th>On Order</th>
+                    <th>Reorder Level</th>
+                    <th>Suggested</th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Suggestions}}
+                <tr>
+                    <td>{{.ProductID}}</td>
+                    <td>{{if .SupplierID}}{{.SupplierID}}{{else}}<em>none</em>{{end}}</td>
+                    <td>{{printf "%.2f" .DailyDemand}}</td>
+                    <td>{{.LeadTimeDays}}</td>
+                    <td>{{.LeadTimeDemand}}</td>
+                    <td>{{.SafetyStock}}</td>
+                    <td>{{.Stock}}</td>
+                    <td>{{.InTransit}}</td>
+                    <td>{{.OnOrder}}</td>
+                    <td>{{.ReorderLevel}}</td>
+                    <td>{{.SuggestedQuantity}}</td>
+                </tr>
+                {{else}}
+                <tr><td colspan="11">Nothing needs to be reordered.</td></tr>
+                {{end}}
+            </tbody>
+        </table>
+        {{if .Suggestions}}
+        <form action="/create-suggested-purchase-orders?window_days={{.Params.Get "window_days"}}&supplier_id={{.Params.Get "supplier_id"}}" method="POST">
+            <button type="submit" class="btn btn-success">Create Draft Purchase Orders</button>
+            <span class="text-muted ms-2">One per supplier; products without a supplier are left out.</span>
+        </form>
+        {{end}}
+    </div>
+</body>
+</html>
+`))
+
+var expiringLotsTemplate = template.Must(template.New("expiringLots").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Expiring Lots</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha3/dist/css/bootstrap.min.css" rel="stylesheet">
+</head>
+<body>
+    <div class="container mt-4">
+        <h1>Expiring Lots</h1>
+        <form class="row g-2 mb-3" method="GET">
+            <div class="col-md-3"><input type="number" min="0" name="days" class="form-control" placeholder="Within {{.DefaultDays}} days" value="{{.Params.Get "days"}}"></div>
+            <div class="col-md-2"><button type="submit" class="btn btn-primary">Update</button></div>
+        </form>
+        <table class="table table-striped">
+            <thead>
+                <tr>
+                    <th>Lot</th>
+                    <th>Product ID</th>
+                    <th>Warehouse</th>
+                    <th>Quantity</th>
+                    <th>Expires</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .Lots}}
+                <tr {{if .ExpiresAt.Before $.Now}}class="table-danger"{{end}}>
+                    <td>{{.LotNumber}}</td>
                     <td>{{.ProductID}}</td>
+                    <td>{{.WarehouseID}}</td>
                     <td>{{.Quantity}}</td>
-                    <td>{{.Total}}</td>
+                    <td>{{.ExpiresAt.Format "2006-01-02 15:04"}}</td>
+                    <td>
+                        <form action="/quarantine-lot" method="POST">
+                            <input type="hidden" name="id" value="{{.ID}}">
+                            <button type="submit" class="btn btn-sm btn-outline-danger">Quarantine</button>
+                        </form>
+                    </td>
+                </tr>
+                {{else}}
+                <tr><td colspan="6">No lots expire in this window.</td></tr>
+                {{end}}
+            </tbody>
+        </table>
+        <form action="/quarantine-expired-lots" method="POST" class="mb-4">
+            <button type="submit" class="btn btn-danger">Quarantine Expired Lots</button>
+            <span class="text-muted ms-2">Expired lots are taken out of stock so they cannot be sold.</span>
+        </form>
+        <h2>Receive a Lot</h2>
+        <form action="/add-lot" method="POST" class="row g-2">
+            <div class="col-md-2"><input type="text" name="product_id" class="form-control" placeholder="Product ID" required></div>
+            <div class="col-md-2"><input type="text" name="warehouse_id" class="form-control" placeholder="Warehouse ({{.DefaultWarehouse}})"></div>
+            <div class="col-md-2"><input type="text" name="lot_number" class="form-control" placeholder="Lot number" required></div>
+            <div class="col-md-2"><input type="number" min="1" name="quantity" class="form-control" placeholder="Quantity" required></div>
+            <div class="col-md-2"><input type="date" name="expires_on" class="form-control" required></div>
+            <div class="col-md-2"><button type="submit" class="btn btn-success">Add Lot</button></div>
+        </form>
+    </div>
+</body>
+</html>
+`))
+
+var viewPurchaseOrdersTemplate = template.Must(template.New("viewPurchaseOrders").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Purchase Orders</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha3/dist/css/bootstrap.min.css" rel="stylesheet">
+</head>
+<body>
+    <div class="container mt-4">
+        <h1>Purchase Orders</h1>
+        <form class="row g-2 mb-3" method="GET">
+            <div class="col-md-3">
+                <select name="status" class="form-select">
+                    <option value="">Any status</option>
+                    {{range $status := .Statuses}}
+                    <option {{if eq ($.Params.Get "status") $status}}selected{{end}}>{{$status}}</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="col-md-3"><input type="text" name="supplier_id" class="form-control" placeholder="Supplier ID" value="{{.Params.Get "supplier_id"}}"></div>
+            <div class="col-md-2"><button type="submit" class="btn btn-primary">Filter</button></div>
+            <div class="col-md-4 text-end"><a href="/create-purchase-order-form" class="btn btn-success">Create Purchase Order</a></div>
+        </form>
+        <table class="table table-striped">
+            <thead>
+                <tr>
+                    <th>PO ID</th>
+                    <th>Supplier</th>
+                    <th>Warehouse</th>
+                    <th>Lines</th>
+                    <th>Status</th>
+                    <th>Expected</th>
+                    <th></th>
+                </tr>
+            </thead>
+            <tbody>
+                {{range .PurchaseOrders}}
+                <tr>
+                    <td>{{.ID}}</td>
+                    <td>{{.SupplierID}}</td>
+                    <td>{{.WarehouseID}}</td>
+                    <td>
+                        <ul class="list-unstyled mb-0">
+                            {{range .Lines}}
+                            <li>{{.ProductID}}: {{.Received}} of {{.Quantity}} received @ {{.UnitCost}}{{if .OverReceived}} <span class="badge bg-warning text-dark">{{.OverReceived}} over</span>{{end}}</li>
+                            {{end}}
+                        </ul>
+                    </td>
                     <td>{{.Status}}</td>
+                    <td>{{if .ExpectedAt}}{{.ExpectedAt.Format "2006-01-02"}}{{end}}</td>
+                    <td>
+                        {{if eq .Status "Draft"}}
+                        <form action="/send-purchase-order" method="POST" class="mb-1">
+                            <input type="hidden" name="id" value="{{.ID}}">
+                            <button type="submit" class="btn btn-sm btn-primary">Send</button>
+                        </form>
+                        {{end}}
+                        {{if or (eq .Status "Sent") (eq .Status "PartiallyReceived")}}
+                        <form action="/receive-purchase-order" method="POST" class="mb-1">
+                            <input type="hidden" name="id" value="{{.ID}}">
+                            {{range .Lines}}{{if lt .Received .Quantity}}
+                            <div class="input-group input-group-sm mb-1">
+                                <span class="input-group-text">{{.ProductID}}</span>
+                                <input type="hidden" name="product_id" value="{{.ProductID}}">
+                                <input type="number" min="0" name="quantity" class="form-control" placeholder="Quantity">
+                                <input type="text" name="lot_number" class="form-control" placeholder="Lot (optional)">
+                                <input type="date" name="expires_on" class="form-control">
+                            </div>
+                            {{end}}{{end}}
+                            <button type="submit" class="btn btn-sm btn-success">Receive</button>
+                        </form>
+                        {{end}}
+                        {{if ne .Status "Closed"}}
+                        <form action="/close-purchase-order" method="POST">
+                            <input type="hidden" name="id" value="{{.ID}}">
+                            <button type="submit" class="btn btn-sm btn-outline-danger">Close</button>
+                        </form>
+                        {{end}}
+                    </td>
                 </tr>
+                {{else}}
+                <tr><td colspan="7">No purchase orders.</td></tr>
                 {{end}}
             </tbody>
         </table>
@@ -220,6 +1421,73 @@ var viewOrdersTemplate = template.Must(template.New("viewOrders").Parse(`
 </body>
 </html>
 `))
+
+var createPurchaseOrderFormTemplate = template.Must(template.New("createPurchaseOrderForm").Parse(`
+<!DOCTYPE html>
+<html lang="en">
+<head>
+    <title>Create Purchase Order</title>
+    <link href="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha3/dist/css/bootstrap.min.css" rel="stylesheet">
+</head>
+<body>
+    <div class="container mt-4">
+        <h1>Create Purchase Order</h1>
+        <form action="/create-purchase-order" method="POST">
+            <div class="mb-3">
+                <label for="supplier" class="form-label">Supplier</label>
+                <select class="form-select" id="supplier" name="supplier_id" required>
+                    {{range .Suppliers}}
+                    <option value="{{.ID}}">{{.Name}} ({{.ID}})</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="mb-3">
+                <label for="warehouse" class="form-label">Deliver to</label>
+                <select class="form-select" id="warehouse" name="warehouse_id">
+                    {{range .Warehouses}}
+                    <option value="{{.ID}}">{{.Name}} ({{.ID}})</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="mb-3">
+                <label for="expectedAt" class="form-label">Expected</label>
+                <input type="date" class="form-control" id="expectedAt" name="expected_at">
+            </div>
+            <table class="table" id="poLines">
+                <thead>
+                    <tr>
+                        <th>Product ID</th>
+                        <th>Quantity</th>
+                        <th>Unit Cost</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    <tr>
+                        <td><input type="text" class="form-control" name="product_id" required></td>
+                        <td><input type="number" min="1" class="form-control" name="quantity" required></td>
+                        <td><input type="number" min="0" step="0.01" class="form-control" name="unit_cost"></td>
+                    </tr>
+                </tbody>
+            </table>
+            <button type="button" class="btn btn-secondary" id="addLine">Add Line</button>
+            <button type="submit" class="btn btn-primary">Create Purchase Order</button>
+        </form>
+    </div>
+    <script>
+        document.getElementById("addLine").addEventListener("click", function () {
+            var body = document.querySelector("#poLines tbody");
+            var row = body.rows[0].cloneNode(true);
+            row.querySelectorAll("input").forEach(function (input) {
+                input.value = "";
+                input.required = false;
+            });
+            body.appendChild(row);
+        });
+    </script>
+</body>
+</html>
+`))
+
 var tmpl = template.Must(template.New("index").Parse(`
 <!DOCTYPE html>
 <html lang="en">
@@ -253,7 +1521,7 @@ var tmpl = template.Must(template.New("index").Parse(`
                 <div class="card">
                     <div class="card-body">
                         <h5 class="card-title">Create Order</h5>
-                        <p class="card-text">Place a new order for a product.</p>
+                        <p class="card-text">Place a new order for one or more products.</p>
                         <a href="/create-order-form" class="btn btn-primary">Create Order</a>
                     </div>
                 </div>
@@ -276,6 +1544,51 @@ var tmpl = template.Must(template.New("index").Parse(`
                     </div>
                 </div>
             </div>
+            <div class="col-md-3 mt-3">
+                <div class="card">
+                    <div class="card-body">
+                        <h5 class="card-title">Needs Reorder</h5>
+                        <p class="card-text">Check the products at or below their reorder point.</p>
+                        <a href="/needs-reorder" class="btn btn-primary">Needs Reorder</a>
+                    </div>
+                </div>
+            </div>
+            <div class="col-md-3 mt-3">
+                <div class="card">
+                    <div class="card-body">
+                        <h5 class="card-title">Suppliers</h5>
+                        <p class="card-text">Check and add the suppliers stock is bought from.</p>
+                        <a href="/view-suppliers" class="btn btn-primary">View Suppliers</a>
+                    </div>
+                </div>
+            </div>
+            <div class="col-md-3 mt-3">
+                <div class="card">
+                    <div class="card-body">
+                        <h5 class="card-title">Purchase Orders</h5>
+                        <p class="card-text">Create, send and receive purchase orders.</p>
+                        <a href="/view-purchase-orders" class="btn btn-primary">View Purchase Orders</a>
+                    </div>
+                </div>
+            </div>
+            <div class="col-md-3 mt-3">
+                <div class="card">
+                    <div class="card-body">
+                        <h5 class="card-title">Replenishment</h5>
+                        <p class="card-text">Check suggested purchases based on recent sales.</p>
+                        <a href="/replenishment" class="btn btn-primary">View Suggestions</a>
+                    </div>
+                </div>
+            </div>
+            <div class="col-md-3 mt-3">
+                <div class="card">
+                    <div class="card-body">
+                        <h5 class="card-title">Expiring Lots</h5>
+                        <p class="card-text">Receive lots and quarantine those about to expire.</p>
+                        <a href="/expiring-lots" class="btn btn-primary">View Lots</a>
+                    </div>
+                </div>
+            </div>
         </div>
     </div>
     <script src="https://cdn.jsdelivr.net/npm/bootstrap@5.3.0-alpha3/dist/js/bootstrap.bundle.min.js"></script>
@@ -302,6 +1615,14 @@ var addProductFormTemplate = template.Must(template.New("addProductForm").Parse(
                 <label for="productName" class="form-label">Product Name</label>
                 <input type="text" class="form-control" id="productName" name="name" required>
             </div>
+            <div class="mb-3">
+                <label for="productDescription" class="form-label">Description</label>
+                <textarea class="form-control" id="productDescription" name="description" rows="3"></textarea>
+            </div>
+            <div class="mb-3">
+                <label for="productTags" class="form-label">Tags</label>
+                <input type="text" class="form-control" id="productTags" name="tags" placeholder="Comma-separated">
+            </div>
             <div class="mb-3">
                 <label for="productStock" class="form-label">Stock</label>
                 <input type="number" class="form-control" id="productStock" name="stock" required>
@@ -310,6 +1631,27 @@ var addProductFormTemplate = template.Must(template.New("addProductForm").Parse(
                 <label for="productPrice" class="form-label">Price</label>
                 <input type="number" step="0.01" class="form-control" id="productPrice" name="price" required>
             </div>
+            <div class="mb-3">
+                <label for="productReorderPoint" class="form-label">Reorder Point</label>
+                <input type="number" min="0" class="form-control" id="productReorderPoint" name="reorder_point" placeholder="Alert when stock falls to this level; blank for none">
+            </div>
+            <div class="mb-3">
+                <label for="productReorderQuantity" class="form-label">Reorder Quantity</label>
+                <input type="number" min="0" class="form-control" id="productReorderQuantity" name="reorder_quantity">
+            </div>
+            <div class="mb-3">
+                <label for="productSupplier" class="form-label">Supplier</label>
+                <select class="form-select" id="productSupplier" name="supplier_id">
+                    <option value="">None</option>
+                    {{range .}}
+                    <option value="{{.ID}}">{{.Name}} ({{.ID}})</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="mb-3">
+                <label for="productSafetyStock" class="form-label">Safety Stock</label>
+                <input type="number" min="0" class="form-control" id="productSafetyStock" name="safety_stock" placeholder="Units to keep on hand beyond expected demand">
+            </div>
             <button type="submit" class="btn btn-primary">Add Product</button>
         </form>
     </div>
@@ -329,16 +1671,43 @@ var createOrderFormTemplate = template.Must(template.New("createOrderForm").Pars
         <h1>Create Order</h1>
         <form action="/create-order" method="POST">
             <div class="mb-3">
-                <label for="productID" class="form-label">Product ID</label>
-                <input type="text" class="form-control" id="productID" name="product_id" required>
-            </div>
-            <div class="mb-3">
-                <label for="quantity" class="form-label">Quantity</label>
-                <input type="number" class="form-control" id="quantity" name="quantity" required>
+                <label for="warehouse" class="form-label">Warehouse</label>
+                <select class="form-select" id="warehouse" name="warehouse_id">
+                    <option value="">Choose automatically</option>
+                    {{range .}}
+                    <option value="{{.ID}}">{{.Name}} ({{.ID}})</option>
+                    {{end}}
+                </select>
             </div>
+            <table class="table" id="orderLines">
+                <thead>
+                    <tr>
+                        <th>Product ID</th>
+                        <th>Quantity</th>
+                    </tr>
+                </thead>
+                <tbody>
+                    <tr>
+                        <td><input type="text" class="form-control" name="product_id" required></td>
+                        <td><input type="number" min="1" class="form-control" name="quantity" required></td>
+                    </tr>
+                </tbody>
+            </table>
+            <button type="button" class="btn btn-secondary" id="addLine">Add Line</button>
             <button type="submit" class="btn btn-primary">Create Order</button>
         </form>
     </div>
+    <script>
+        document.getElementById("addLine").addEventListener("click", function () {
+            var body = document.querySelector("#orderLines tbody");
+            var row = body.rows[0].cloneNode(true);
+            row.querySelectorAll("input").forEach(function (input) {
+                input.value = "";
+                input.required = false;
+            });
+            body.appendChild(row);
+        });
+    </script>
 </body>
 </html>
 `))
@@ -353,27 +1722,66 @@ var viewProductsTemplate = template.Must(template.New("viewProducts").Parse(`
 <body>
     <div class="container mt-4">
         <h1>Product List</h1>
+        <form class="row g-2 mb-3" method="GET" role="search">
+            <div class="col-md-10">
+                <input type="search" name="q" class="form-control" placeholder="Search name, tags and description" value="{{.Params.Get "q"}}" list="productSuggestions" autocomplete="off" id="productSearch">
+                <datalist id="productSuggestions"></datalist>
+            </div>
+            <div class="col-md-2"><button type="submit" class="btn btn-primary">Search</button></div>
+        </form>
+        <form class="row g-2 mb-3" method="GET">
+            <div class="col-md-3"><input type="text" name="name" class="form-control" placeholder="Name contains" value="{{.Params.Get "name"}}"></div>
+            <div class="col-md-2"><input type="number" name="stock_below" class="form-control" placeholder="Stock below" value="{{.Params.Get "stock_below"}}"></div>
+            <div class="col-md-2"><input type="number" step="0.01" name="min_price" class="form-control" placeholder="Min price" value="{{.Params.Get "min_price"}}"></div>
+            <div class="col-md-2"><input type="number" step="0.01" name="max_price" class="form-control" placeholder="Max price" value="{{.Params.Get "max_price"}}"></div>
+            <div class="col-md-2">
+                <select name="sort" class="form-select">
+                    {{range $sort := .Sorts}}
+                    <option value="{{$sort.Value}}" {{if eq ($.Params.Get "sort") $sort.Value}}selected{{end}}>{{$sort.Label}}</option>
+                    {{end}}
+                </select>
+            </div>
+            <div class="col-md-1"><button type="submit" class="btn btn-primary">Filter</button></div>
+        </form>
         <table class="table table-striped">
             <thead>
                 <tr>
                     <th>ID</th>
                     <th>Name</th>
+                    <th>Tags</th>
                     <th>Stock</th>
                     <th>Price</th>
                 </tr>
             </thead>
             <tbody>
-                {{range .}}
+                {{range .Products}}
                 <tr>
                     <td>{{.ID}}</td>
-                    <td>{{.Name}}</td>
+                    <td>{{.Name}}{{if .Description}}<div class="small text-muted">{{.Description}}</div>{{end}}</td>
+                    <td>{{range .Tags}}<span class="badge bg-secondary me-1">{{.}}</span>{{end}}</td>
                     <td>{{.Stock}}</td>
                     <td>{{.Price}}</td>
                 </tr>
                 {{end}}
             </tbody>
         </table>
+        <nav class="d-flex justify-content-between">
+            {{if .Prev}}<a class="btn btn-outline-secondary" href="{{.Prev}}">&laquo; Previous</a>{{else}}<span></span>{{end}}
+            {{if .Next}}<a class="btn btn-outline-secondary" href="{{.Next}}">Next &raquo;</a>{{end}}
+        </nav>
     </div>
+    <script>
+        // Suggest products as the search text is typed.
+        const search = document.getElementById("productSearch");
+        const suggestions = document.getElementById("productSuggestions");
+        search.addEventListener("input", async () => {
+            const response = await fetch("/api/v1/autocomplete/products?q=" + encodeURIComponent(search.value));
+            if (!response.ok) {
+                return;
+            }
+            suggestions.replaceChildren(...(await response.json()).map(s => new Option(s.id, s.name)));
+        });
+    </script>
 </body>
 </html>
 `))
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// StockAlert records that a product's stock fell to its reorder point. It
+// stays open until the stock rises above the reorder point again, and a
+// product has at most one open alert, so each crossing raises one alert.
+// Stock, ReorderPoint and ReorderQuantity are the product's when the alert
+// was raised.
+type StockAlert struct {
+	ID              string     `json:"id"`
+	ProductID       string     `json:"product_id"`
+	Stock           int        `json:"stock"`
+	ReorderPoint    int        `json:"reorder_point"`
+	ReorderQuantity int        `json:"reorder_quantity"`
+	CreatedAt       time.Time  `json:"created_at"`
+	NotifiedAt      *time.Time `json:"notified_at,omitempty"`
+	ResolvedAt      *time.Time `json:"resolved_at,omitempty"`
+}
+
+// StockAlertFilter selects stock alerts. Zero fields match every alert.
+type StockAlertFilter struct {
+	ProductID string
+	// Open matches alerts that are not resolved.
+	Open bool
+	// Unnotified matches alerts that have not been announced.
+	Unnotified bool
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Lot is a batch of a product at a warehouse that expires at ExpiresAt.
+// Stock leaves a warehouse from its Active lots first, earliest expiry
+// first, and only then from stock in no lot. A transfer carries its lots to
+// the destination, adding to the lot of the same number there or creating
+// it with the same expiry. Once a lot expires nothing can sell or reserve
+// its units, which stay in stock until it is quarantined. Quarantining a lot
+// takes its units out of stock; a Quarantined lot's Quantity is what it has
+// taken out, what it held when it was quarantined and any units cancelled
+// orders and received transfers have added to it since.
+type Lot struct {
+	ID            string     `json:"id"`
+	ProductID     string     `json:"product_id"`
+	WarehouseID   string     `json:"warehouse_id"`
+	LotNumber     string     `json:"lot_number"`
+	Quantity      int        `json:"quantity"`
+	Status        string     `json:"status"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	QuarantinedAt *time.Time `json:"quarantined_at,omitempty"`
+}
+
+// Lot statuses.
+const (
+	LotStatusActive      = "Active"
+	LotStatusQuarantined = "Quarantined"
+)
+
+// LotFilter selects lots. Zero fields match every lot.
+type LotFilter struct {
+	ProductID   string
+	WarehouseID string
+	Status      string
+	LotNumber   string
+	// ExpiresBy matches lots whose ExpiresAt is not after it.
+	ExpiresBy time.Time
+}
+
+// LotAllocation is the quantity of an order line that was taken from a
+// lot.
+type LotAllocation struct {
+	OrderItemID string    `json:"order_item_id"`
+	LotID       string    `json:"lot_id"`
+	LotNumber   string    `json:"lot_number"`
+	ProductID   string    `json:"product_id"`
+	WarehouseID string    `json:"warehouse_id"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Quantity    int       `json:"quantity"`
+}
@@ -1,7 +1,52 @@
 package models
 
+import "time"
+
 type Metric struct {
-	Name  string  `json:"name"`
-	Value float64 `json:"value"`
-	Time  int64   `json:"time"` // Unix timestamp
+	Name   string            `json:"name"`
+	Value  float64           `json:"value"`
+	Time   int64             `json:"time"` // Unix timestamp
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// MetricQuery selects metrics by name, time range and labels. An empty Name
+// matches every metric, a zero From or To leaves that end of the range open,
+// and only metrics carrying every label in Labels match.
+type MetricQuery struct {
+	Name   string
+	From   time.Time
+	To     time.Time
+	Labels map[string]string
+}
+
+// Aggregation bucket sizes.
+const (
+	BucketMinute = "minute"
+	BucketHour   = "hour"
+	BucketDay    = "day"
+)
+
+// AggregateQuery selects the metric, time range and labels to aggregate and
+// the size of the buckets to aggregate it into. GroupBy names label keys
+// whose values split each bucket into separate series.
+type AggregateQuery struct {
+	Name    string
+	Bucket  string
+	From    time.Time
+	To      time.Time
+	Labels  map[string]string
+	GroupBy []string
+}
+
+// AggregatePoint holds the aggregates of one metric over one time bucket.
+type AggregatePoint struct {
+	Bucket int64   `json:"bucket"` // Unix timestamp of the start of the bucket
+	Count  int64   `json:"count"`
+	Sum    float64 `json:"sum"`
+	Avg    float64 `json:"avg"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	P95    float64 `json:"p95"`
+	// Labels holds the value of each GroupBy label for this series.
+	Labels map[string]string `json:"labels,omitempty"`
 }
@@ -0,0 +1,85 @@
+package models
+
+import "time"
+
+// StockMovement is an entry of the stock ledger: a change of Delta units to
+// the stock of a product at a warehouse. Reference identifies what caused
+// it, such as the order or transfer ID, and Actor who made it. Movements are
+// never changed or removed, so summing them up to a point in time gives the
+// stock at that time.
+type StockMovement struct {
+	ID          string    `json:"id"`
+	ProductID   string    `json:"product_id"`
+	WarehouseID string    `json:"warehouse_id"`
+	Delta       int       `json:"delta"`
+	Reason      string    `json:"reason"`
+	Reference   string    `json:"reference,omitempty"`
+	Actor       string    `json:"actor"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Stock movement reasons.
+const (
+	// MovementReasonOpening is the stock a product held when the ledger was
+	// introduced.
+	MovementReasonOpening = "opening"
+	// MovementReasonInitial is the stock a product is created with.
+	MovementReasonInitial = "initial"
+	// MovementReasonAdjustment is a manual change of stock.
+	MovementReasonAdjustment = "adjustment"
+	// MovementReasonSale is stock taken by an order; the reference is the
+	// order ID.
+	MovementReasonSale = "sale"
+	// MovementReasonCancellation is stock returned by a cancelled order; the
+	// reference is the order ID.
+	MovementReasonCancellation = "cancellation"
+	// MovementReasonReservation is stock taken by a confirmed reservation;
+	// the reference is the reservation ID.
+	MovementReasonReservation = "reservation"
+	// MovementReasonTransferOut and MovementReasonTransferIn are stock
+	// leaving the source of a transfer when it ships and reaching its
+	// destination when it is received; the reference is the transfer ID.
+	MovementReasonTransferOut = "transfer_out"
+	MovementReasonTransferIn  = "transfer_in"
+	// MovementReasonReceipt is stock received against a purchase order; the
+	// reference is the purchase order ID.
+	MovementReasonReceipt = "receipt"
+	// MovementReasonLot is stock received as a new lot other than against a
+	// purchase order; the reference is the lot ID.
+	MovementReasonLot = "lot"
+	// MovementReasonQuarantine is the stock of a quarantined lot taken out
+	// of stock; the reference is the lot ID.
+	MovementReasonQuarantine = "quarantine"
+)
+
+// MovementFilter selects stock movements. Zero fields match every movement.
+type MovementFilter struct {
+	ProductID   string
+	WarehouseID string
+	Reason      string
+	// From and To bound the creation time; From is inclusive and To
+	// exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// MovementQuery selects one page of stock movements.
+type MovementQuery struct {
+	MovementFilter
+	// Sort is id or created_at, prefixed with - for descending order. It
+	// defaults to id, which is also the order movements were made in.
+	Sort string
+	// Cursor is the NextCursor or PrevCursor of a previous page with the
+	// same filter and sort; empty for the first page.
+	Cursor string
+	// Limit is the page size. Zero means the default.
+	Limit int
+}
+
+// MovementPage is one page of stock movements. The cursors are empty when
+// there is no page in that direction.
+type MovementPage struct {
+	Movements  []StockMovement `json:"movements"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+}
@@ -1,9 +1,79 @@
 package models
 
+import "time"
+
 type Order struct {
-	ID        string  `json:"id"`
-	ProductID string  `json:"product_id"`
-	Quantity  int     `json:"quantity"`
-	Total     float64 `json:"total"`
-	Status    string  `json:"status"`
+	ID    string      `json:"id"`
+	Items []OrderItem `json:"items"`
+	// WarehouseID is the warehouse chosen to fulfil every line, or empty
+	// if each line was allocated automatically.
+	WarehouseID string    `json:"warehouse_id,omitempty"`
+	Total       float64   `json:"total"`
+	Status      string    `json:"status"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// OrderItem is a single line of an order. WarehouseID is the warehouse its
+// stock was taken from, UnitPrice is the product price at the time of sale
+// and LineTotal is UnitPrice multiplied by Quantity.
+type OrderItem struct {
+	ID          string  `json:"id,omitempty"`
+	ProductID   string  `json:"product_id"`
+	WarehouseID string  `json:"warehouse_id,omitempty"`
+	Quantity    int     `json:"quantity"`
+	UnitPrice   float64 `json:"unit_price"`
+	LineTotal   float64 `json:"line_total"`
+}
+
+// Order lifecycle statuses.
+const (
+	OrderStatusPending   = "Pending"
+	OrderStatusConfirmed = "Confirmed"
+	OrderStatusPicked    = "Picked"
+	OrderStatusShipped   = "Shipped"
+	OrderStatusDelivered = "Delivered"
+	OrderStatusCancelled = "Cancelled"
+	OrderStatusReturned  = "Returned"
+)
+
+// OrderStatusChange is a single entry in an order's timeline. From is empty
+// for the entry recorded when the order is created.
+type OrderStatusChange struct {
+	OrderID   string    `json:"order_id"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to"`
+	Actor     string    `json:"actor"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// OrderFilter selects orders. Zero fields match every order.
+type OrderFilter struct {
+	Status string
+	// ProductID matches orders with a line for the product.
+	ProductID string
+	// From and To bound the creation time; From is inclusive and To
+	// exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// OrderQuery selects one page of orders.
+type OrderQuery struct {
+	OrderFilter
+	// Sort is id, created_at or total, prefixed with - for descending
+	// order. It defaults to id.
+	Sort string
+	// Cursor is the NextCursor or PrevCursor of a previous page with the
+	// same filter and sort; empty for the first page.
+	Cursor string
+	// Limit is the page size. Zero means the default.
+	Limit int
+}
+
+// OrderPage is one page of orders. The cursors are empty when there is no
+// page in that direction.
+type OrderPage struct {
+	Orders     []Order `json:"orders"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
 }
@@ -1,8 +1,80 @@
 package models
 
+// Product is an item the inventory holds. When its Stock falls to
+// ReorderPoint or below a stock alert is raised, asking for ReorderQuantity
+// more units; a zero ReorderPoint raises no alerts. SupplierID names the
+// supplier it is usually bought from, and SafetyStock the stock to keep on
+// top of the demand expected while that supplier delivers.
 type Product struct {
-	ID    string  `json:"id"`
-	Name  string  `json:"name"`
-	Stock int     `json:"stock"`
-	Price float64 `json:"price"`
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	Stock           int      `json:"stock"`
+	Price           float64  `json:"price"`
+	ReorderPoint    int      `json:"reorder_point"`
+	ReorderQuantity int      `json:"reorder_quantity"`
+	SupplierID      string   `json:"supplier_id,omitempty"`
+	SafetyStock     int      `json:"safety_stock"`
+}
+
+// ProductUpdate lists the product fields to change. Nil fields keep their
+// current value; an empty SupplierID removes the product's supplier.
+type ProductUpdate struct {
+	Name            *string   `json:"name,omitempty"`
+	Description     *string   `json:"description,omitempty"`
+	Tags            *[]string `json:"tags,omitempty"`
+	Stock           *int      `json:"stock,omitempty"`
+	Price           *float64  `json:"price,omitempty"`
+	ReorderPoint    *int      `json:"reorder_point,omitempty"`
+	ReorderQuantity *int      `json:"reorder_quantity,omitempty"`
+	SupplierID      *string   `json:"supplier_id,omitempty"`
+	SafetyStock     *int      `json:"safety_stock,omitempty"`
+}
+
+// ProductFilter selects products. Zero or nil fields match every product.
+type ProductFilter struct {
+	// NameContains matches products whose name contains it, ignoring case.
+	NameContains string
+	// StockBelow matches products with fewer units in stock.
+	StockBelow *int
+	// MinPrice and MaxPrice bound the price, inclusive.
+	MinPrice *float64
+	MaxPrice *float64
+	// SupplierID matches the products bought from this supplier.
+	SupplierID string
+}
+
+// ProductQuery selects one page of products.
+type ProductQuery struct {
+	ProductFilter
+	// Sort is id, name, stock or price, prefixed with - for descending
+	// order. It defaults to id.
+	Sort string
+	// Cursor is the NextCursor or PrevCursor of a previous page with the
+	// same filter and sort; empty for the first page.
+	Cursor string
+	// Limit is the page size. Zero means the default.
+	Limit int
+}
+
+// ProductPage is one page of products. The cursors are empty when there is
+// no page in that direction.
+type ProductPage struct {
+	Products   []Product `json:"products"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+}
+
+// ProductMatch is a product found by a search, with its relevance. Higher
+// ranks are better matches; ranks are only comparable within one search.
+type ProductMatch struct {
+	Product Product `json:"product"`
+	Rank    float64 `json:"rank"`
+}
+
+// ProductSuggestion is an autocomplete suggestion for a product search.
+type ProductSuggestion struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
 }
@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// PurchaseOrder buys stock from a supplier for a warehouse. It is created as
+// a Draft, which can still be edited, and becomes Sent once it goes to the
+// supplier. Receipts against a Sent order add stock to the warehouse and
+// make it PartiallyReceived, or Received once every line has arrived in
+// full. Closing it, for instance to accept a short delivery, ends it.
+type PurchaseOrder struct {
+	ID          string              `json:"id"`
+	SupplierID  string              `json:"supplier_id"`
+	WarehouseID string              `json:"warehouse_id"`
+	Lines       []PurchaseOrderLine `json:"lines"`
+	Status      string              `json:"status"`
+	ExpectedAt  *time.Time          `json:"expected_at,omitempty"`
+	CreatedAt   time.Time           `json:"created_at"`
+	SentAt      *time.Time          `json:"sent_at,omitempty"`
+	ClosedAt    *time.Time          `json:"closed_at,omitempty"`
+}
+
+// PurchaseOrderLine is the quantity of one product ordered by a purchase
+// order, and how much of it has been received. A supplier may deliver more
+// than was ordered; OverReceived is how far Received exceeds Quantity.
+type PurchaseOrderLine struct {
+	ProductID    string  `json:"product_id"`
+	Quantity     int     `json:"quantity"`
+	Received     int     `json:"received"`
+	OverReceived int     `json:"over_received"`
+	UnitCost     float64 `json:"unit_cost"`
+}
+
+// Purchase order statuses.
+const (
+	PurchaseOrderStatusDraft             = "Draft"
+	PurchaseOrderStatusSent              = "Sent"
+	PurchaseOrderStatusPartiallyReceived = "PartiallyReceived"
+	PurchaseOrderStatusReceived          = "Received"
+	PurchaseOrderStatusClosed            = "Closed"
+)
+
+// PurchaseOrderFilter selects purchase orders. Zero fields match every
+// purchase order.
+type PurchaseOrderFilter struct {
+	Status     string
+	SupplierID string
+}
+
+// ReceiptLine is a quantity of one product received against a purchase
+// order. A line with a LotNumber and ExpiresAt receives the quantity as a
+// new lot.
+type ReceiptLine struct {
+	ProductID string     `json:"product_id"`
+	Quantity  int        `json:"quantity"`
+	LotNumber string     `json:"lot_number,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,36 @@
+package models
+
+// ReplenishmentQuery selects the products to suggest purchases for.
+type ReplenishmentQuery struct {
+	// WindowDays is the number of days of order history that average daily
+	// demand is computed over. Zero means the default.
+	WindowDays int
+	// SupplierID limits the suggestions to products bought from this
+	// supplier.
+	SupplierID string
+}
+
+// ReplenishmentSuggestion is a suggested purchase of one product.
+//
+// DailyDemand is the units ordered over the window divided by its length,
+// and LeadTimeDemand the demand it gives over the supplier's lead time,
+// rounded up. The product should be reordered when its Stock, the quantity
+// InTransit between warehouses and the quantity OnOrder from open purchase
+// orders fall below ReorderLevel, the lead time demand plus the product's
+// safety stock. SuggestedQuantity makes up the difference and adds another
+// lead time's demand, so that the purchase lasts until the next one can
+// arrive.
+type ReplenishmentSuggestion struct {
+	ProductID         string  `json:"product_id"`
+	SupplierID        string  `json:"supplier_id,omitempty"`
+	UnitsOrdered      int     `json:"units_ordered"`
+	DailyDemand       float64 `json:"daily_demand"`
+	LeadTimeDays      int     `json:"lead_time_days"`
+	LeadTimeDemand    int     `json:"lead_time_demand"`
+	SafetyStock       int     `json:"safety_stock"`
+	Stock             int     `json:"stock"`
+	InTransit         int     `json:"in_transit"`
+	OnOrder           int     `json:"on_order"`
+	ReorderLevel      int     `json:"reorder_level"`
+	SuggestedQuantity int     `json:"suggested_quantity"`
+}
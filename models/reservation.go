@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Reservation holds stock of a product at a warehouse for a limited time,
+// such as while a customer checks out. Held stock is not available to
+// orders or other reservations. An Active reservation is either confirmed,
+// which takes the stock for good, or released, which frees it; one that
+// reaches ExpiresAt first is released as Expired.
+type Reservation struct {
+	ID          string    `json:"id"`
+	ProductID   string    `json:"product_id"`
+	WarehouseID string    `json:"warehouse_id"`
+	Quantity    int       `json:"quantity"`
+	Status      string    `json:"status"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Reservation statuses.
+const (
+	ReservationStatusActive    = "Active"
+	ReservationStatusConfirmed = "Confirmed"
+	ReservationStatusReleased  = "Released"
+	ReservationStatusExpired   = "Expired"
+)
+
+// ReservationFilter selects reservations. Zero fields match every
+// reservation.
+type ReservationFilter struct {
+	ProductID string
+	Status    string
+	// ExpiredBy matches reservations whose ExpiresAt is not after it.
+	ExpiredBy time.Time
+}
@@ -0,0 +1,11 @@
+package models
+
+// Supplier is a company that stock is bought from. LeadTimeDays is how long
+// its deliveries usually take to arrive once a purchase order is sent.
+type Supplier struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Email        string `json:"email,omitempty"`
+	Phone        string `json:"phone,omitempty"`
+	LeadTimeDays int    `json:"lead_time_days"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// Transfer moves stock from one warehouse to another. Its stock leaves the
+// source when it ships and reaches the destination when it is received; in
+// between it is in transit and counted at neither. Stock that leaves the
+// source from lots arrives in the destination's lots of the same numbers.
+type Transfer struct {
+	ID            string         `json:"id"`
+	SourceID      string         `json:"source_id"`
+	DestinationID string         `json:"destination_id"`
+	Lines         []TransferLine `json:"lines"`
+	Status        string         `json:"status"`
+	CreatedAt     time.Time      `json:"created_at"`
+	ShippedAt     *time.Time     `json:"shipped_at,omitempty"`
+	ReceivedAt    *time.Time     `json:"received_at,omitempty"`
+}
+
+// TransferLine is the quantity of one product moved by a transfer.
+type TransferLine struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// TransferLot is the quantity of a transfer line that was taken from a lot
+// at the source. LotID is the source lot.
+type TransferLot struct {
+	TransferID string    `json:"transfer_id"`
+	LotID      string    `json:"lot_id"`
+	LotNumber  string    `json:"lot_number"`
+	ProductID  string    `json:"product_id"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Quantity   int       `json:"quantity"`
+}
+
+// Transfer statuses. A transfer is created as a Draft, becomes InTransit
+// when it ships and Received when it arrives.
+const (
+	TransferStatusDraft     = "Draft"
+	TransferStatusInTransit = "InTransit"
+	TransferStatusReceived  = "Received"
+)
+
+// TransferFilter selects transfers. Zero fields match every transfer.
+type TransferFilter struct {
+	Status string
+	// WarehouseID matches transfers from or to the warehouse.
+	WarehouseID string
+}
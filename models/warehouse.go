@@ -0,0 +1,35 @@
+package models
+
+// Warehouse is a location that holds stock. Orders that do not choose a
+// warehouse are allocated from warehouses in ascending Priority order.
+type Warehouse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+}
+
+// StockLevel is the stock of a product held at one warehouse. Reserved is
+// the part of Quantity held by active reservations and Expired the part in
+// Active lots past their expiry, waiting to be quarantined. Available is
+// the rest, which orders and new reservations can take.
+type StockLevel struct {
+	WarehouseID string `json:"warehouse_id"`
+	Quantity    int    `json:"quantity"`
+	Reserved    int    `json:"reserved"`
+	Expired     int    `json:"expired"`
+	Available   int    `json:"available"`
+}
+
+// StockReport is a product's stock at each warehouse that has held it, and
+// the totals across them; Total is the product's Stock. InTransit is the
+// stock on its way between warehouses in shipped transfers, which is not
+// part of Total.
+type StockReport struct {
+	ProductID string       `json:"product_id"`
+	Total     int          `json:"total"`
+	Reserved  int          `json:"reserved"`
+	Expired   int          `json:"expired"`
+	Available int          `json:"available"`
+	InTransit int          `json:"in_transit"`
+	Levels    []StockLevel `json:"levels"`
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"service-weaver-app/models"
+)
+
+// openAPIDoc is the part of the OpenAPI document the drift tests inspect.
+type openAPIDoc struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Required   []string                   `json:"required"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadOpenAPI(t *testing.T) openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("parse api/openapi.json: %v", err)
+	}
+	return doc
+}
+
+// httpMethods are the path item keys that describe operations.
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	newTestServer(t)
+	doc := loadOpenAPI(t)
+
+	// Every registered route is documented.
+	registered := make(map[string][]string)
+	for _, pattern := range routes {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "", pattern
+		}
+		registered[path] = append(registered[path], strings.ToLower(method))
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			t.Errorf("route %q is missing from the OpenAPI paths", pattern)
+			continue
+		}
+		if _, ok := item[strings.ToLower(method)]; method != "" && !ok {
+			t.Errorf("route %q is missing from the OpenAPI operations of %s", pattern, path)
+		}
+	}
+
+	// Every documented operation is served.
+	for path, item := range doc.Paths {
+		methods, ok := registered[path]
+		if !ok {
+			t.Errorf("OpenAPI path %s is not registered", path)
+			continue
+		}
+		for _, method := range httpMethods {
+			if _, documented := item[method]; !documented {
+				continue
+			}
+			served := false
+			for _, m := range methods {
+				served = served || m == "" || m == method
+			}
+			if !served {
+				t.Errorf("OpenAPI operation %s %s is not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadOpenAPI(t)
+	schemas := map[string]reflect.Type{
+		"Product":                 reflect.TypeOf(models.Product{}),
+		"ProductUpdate":           reflect.TypeOf(models.ProductUpdate{}),
+		"ProductPage":             reflect.TypeOf(models.ProductPage{}),
+		"ProductMatch":            reflect.TypeOf(models.ProductMatch{}),
+		"ProductSuggestion":       reflect.TypeOf(models.ProductSuggestion{}),
+		"Order":                   reflect.TypeOf(models.Order{}),
+		"OrderItem":               reflect.TypeOf(models.OrderItem{}),
+		"OrderStatusChange":       reflect.TypeOf(models.OrderStatusChange{}),
+		"OrderPage":               reflect.TypeOf(models.OrderPage{}),
+		"Warehouse":               reflect.TypeOf(models.Warehouse{}),
+		"StockLevel":              reflect.TypeOf(models.StockLevel{}),
+		"StockReport":             reflect.TypeOf(models.StockReport{}),
+		"Transfer":                reflect.TypeOf(models.Transfer{}),
+		"TransferLine":            reflect.TypeOf(models.TransferLine{}),
+		"TransferLot":             reflect.TypeOf(models.TransferLot{}),
+		"Reservation":             reflect.TypeOf(models.Reservation{}),
+		"Lot":                     reflect.TypeOf(models.Lot{}),
+		"LotAllocation":           reflect.TypeOf(models.LotAllocation{}),
+		"StockAlert":              reflect.TypeOf(models.StockAlert{}),
+		"Supplier":                reflect.TypeOf(models.Supplier{}),
+		"PurchaseOrder":           reflect.TypeOf(models.PurchaseOrder{}),
+		"PurchaseOrderLine":       reflect.TypeOf(models.PurchaseOrderLine{}),
+		"ReplenishmentSuggestion": reflect.TypeOf(models.ReplenishmentSuggestion{}),
+		"StockMovement":           reflect.TypeOf(models.StockMovement{}),
+		"MovementPage":            reflect.TypeOf(models.MovementPage{}),
+		"Metric":                  reflect.TypeOf(models.Metric{}),
+		"AggregatePoint":          reflect.TypeOf(models.AggregatePoint{}),
+		"Error":                   reflect.TypeOf(errorResponse{}),
+	}
+	for name, typ := range schemas {
+		schema, ok := doc.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing", name)
+			continue
+		}
+		var documented []string
+		for property := range schema.Properties {
+			documented = append(documented, property)
+		}
+		sort.Strings(documented)
+		if fields := jsonFields(typ); !reflect.DeepEqual(documented, fields) {
+			t.Errorf("schema %s has properties %v, %s has JSON fields %v", name, documented, typ, fields)
+		}
+		for _, required := range schema.Required {
+			if _, ok := schema.Properties[required]; !ok {
+				t.Errorf("schema %s requires unknown property %s", name, required)
+			}
+		}
+	}
+}
+
+// jsonFields returns the sorted JSON names of the fields of a struct type.
+func jsonFields(typ reflect.Type) []string {
+	var fields []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func TestOpenAPIReferences(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(openAPISpec, &doc); err != nil {
+		t.Fatalf("parse api/openapi.json: %v", err)
+	}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				var target interface{} = doc
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]interface{})
+					target = m[part]
+				}
+				if target == nil {
+					t.Errorf("unresolved reference %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestOpenAPIServed(t *testing.T) {
+	server := newTestServer(t)
+	var doc openAPIDoc
+	if code := call(t, server, "GET", "/api/openapi.json", "", &doc); code != http.StatusOK || !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("GET /api/openapi.json: %d, openapi %q", code, doc.OpenAPI)
+	}
+}
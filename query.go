@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"service-weaver-app/components"
+	"service-weaver-app/models"
+)
+
+// parseProductQuery reads a product listing query from the request's query
+// parameters: name, stock_below, min_price, max_price and supplier_id
+// filter the products, and sort, limit and cursor select the page.
+// Malformed values wrap components.ErrInvalidQuery.
+func parseProductQuery(r *http.Request) (models.ProductQuery, error) {
+	params := r.URL.Query()
+	q := models.ProductQuery{
+		ProductFilter: models.ProductFilter{NameContains: params.Get("name"), SupplierID: params.Get("supplier_id")},
+		Sort:          params.Get("sort"),
+		Cursor:        params.Get("cursor"),
+	}
+	var err error
+	if q.StockBelow, err = optionalInt(params, "stock_below"); err != nil {
+		return models.ProductQuery{}, err
+	}
+	if q.MinPrice, err = optionalFloat(params, "min_price"); err != nil {
+		return models.ProductQuery{}, err
+	}
+	if q.MaxPrice, err = optionalFloat(params, "max_price"); err != nil {
+		return models.ProductQuery{}, err
+	}
+	if q.Limit, err = parseLimit(params); err != nil {
+		return models.ProductQuery{}, err
+	}
+	return q, nil
+}
+
+// parseOrderQuery reads an order listing query from the request's query
+// parameters: status, product_id, and the RFC 3339 times from and to filter
+// the orders, and sort, limit and cursor select the page. Malformed values
+// wrap components.ErrInvalidQuery.
+func parseOrderQuery(r *http.Request) (models.OrderQuery, error) {
+	params := r.URL.Query()
+	q := models.OrderQuery{
+		OrderFilter: models.OrderFilter{Status: params.Get("status"), ProductID: params.Get("product_id")},
+		Sort:        params.Get("sort"),
+		Cursor:      params.Get("cursor"),
+	}
+	var err error
+	if q.From, err = optionalTime(params, "from"); err != nil {
+		return models.OrderQuery{}, err
+	}
+	if q.To, err = optionalTime(params, "to"); err != nil {
+		return models.OrderQuery{}, err
+	}
+	if q.Limit, err = parseLimit(params); err != nil {
+		return models.OrderQuery{}, err
+	}
+	return q, nil
+}
+
+// parseMovementQuery reads a stock movement listing query from the
+// request's query parameters: warehouse_id, reason, and the RFC 3339 times
+// from and to filter the movements, and sort, limit and cursor select the
+// page. Malformed values wrap components.ErrInvalidQuery.
+func parseMovementQuery(r *http.Request) (models.MovementQuery, error) {
+	params := r.URL.Query()
+	q := models.MovementQuery{
+		MovementFilter: models.MovementFilter{WarehouseID: params.Get("warehouse_id"), Reason: params.Get("reason")},
+		Sort:           params.Get("sort"),
+		Cursor:         params.Get("cursor"),
+	}
+	var err error
+	if q.From, err = optionalTime(params, "from"); err != nil {
+		return models.MovementQuery{}, err
+	}
+	if q.To, err = optionalTime(params, "to"); err != nil {
+		return models.MovementQuery{}, err
+	}
+	if q.Limit, err = parseLimit(params); err != nil {
+		return models.MovementQuery{}, err
+	}
+	return q, nil
+}
+
+// parseReplenishmentQuery reads a replenishment query from the request's
+// query parameters: window_days is the demand window and supplier_id limits
+// the products. Malformed values wrap components.ErrInvalidQuery.
+func parseReplenishmentQuery(r *http.Request) (models.ReplenishmentQuery, error) {
+	params := r.URL.Query()
+	q := models.ReplenishmentQuery{SupplierID: params.Get("supplier_id")}
+	window, err := optionalInt(params, "window_days")
+	if err != nil {
+		return models.ReplenishmentQuery{}, err
+	}
+	if window != nil {
+		if *window < 1 {
+			return models.ReplenishmentQuery{}, fmt.Errorf("%w: window_days must be positive", components.ErrInvalidQuery)
+		}
+		q.WindowDays = *window
+	}
+	return q, nil
+}
+
+// parseLotFilter reads a lot filter from the request's query parameters:
+// product_id, warehouse_id and status.
+func parseLotFilter(r *http.Request) models.LotFilter {
+	params := r.URL.Query()
+	return models.LotFilter{
+		ProductID:   params.Get("product_id"),
+		WarehouseID: params.Get("warehouse_id"),
+		Status:      params.Get("status"),
+	}
+}
+
+// parseExpiringDays reads the days parameter of the expiring lots report,
+// defaulting to components.DefaultExpiringDays. Malformed values wrap
+// components.ErrInvalidQuery.
+func parseExpiringDays(r *http.Request) (int, error) {
+	days, err := optionalInt(r.URL.Query(), "days")
+	if err != nil {
+		return 0, err
+	}
+	if days == nil {
+		return components.DefaultExpiringDays, nil
+	}
+	if *days < 0 {
+		return 0, fmt.Errorf("%w: days must not be negative", components.ErrInvalidQuery)
+	}
+	return *days, nil
+}
+
+func parseLimit(params url.Values) (int, error) {
+	limit, err := optionalInt(params, "limit")
+	if err != nil || limit == nil {
+		return 0, err
+	}
+	if *limit < 1 {
+		return 0, fmt.Errorf("%w: limit must be positive", components.ErrInvalidQuery)
+	}
+	return *limit, nil
+}
+
+func optionalInt(params url.Values, key string) (*int, error) {
+	raw := params.Get(key)
+	if raw == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be an integer, got %q", components.ErrInvalidQuery, key, raw)
+	}
+	return &n, nil
+}
+
+func optionalFloat(params url.Values, key string) (*float64, error) {
+	raw := params.Get(key)
+	if raw == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s must be a number, got %q", components.ErrInvalidQuery, key, raw)
+	}
+	return &f, nil
+}
+
+func optionalTime(params url.Values, key string) (time.Time, error) {
+	raw := params.Get(key)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s must be an RFC 3339 time, got %q", components.ErrInvalidQuery, key, raw)
+	}
+	return t, nil
+}
+
+// pageLink returns the URL of the request with its cursor replaced, or ""
+// if there is no cursor to link to.
+func pageLink(r *http.Request, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	params := r.URL.Query()
+	params.Set("cursor", cursor)
+	return r.URL.Path + "?" + params.Encode()
+}
@@ -0,0 +1,325 @@
+// Package telemetry collects application metrics and exposes them in the
+// Prometheus text exposition format.
+package telemetry
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets suited to HTTP request latencies in
+// seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// family is a named group of samples written under one HELP and TYPE line.
+type family interface {
+	name() string
+	write(w *bufio.Writer) error
+}
+
+// Registry holds metric families and renders them for scraping.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+func (r *Registry) register(f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.families[f.name()]; exists {
+		panic(fmt.Sprintf("telemetry: metric %s registered twice", f.name()))
+	}
+	r.families[f.name()] = f
+}
+
+// WriteText writes every registered family to w in the Prometheus text
+// exposition format, sorted by name.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := make([]family, 0, len(r.families))
+	for _, f := range r.families {
+		families = append(families, f)
+	}
+	r.mu.Unlock()
+	sort.Slice(families, func(i, j int) bool { return families[i].name() < families[j].name() })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		if err := f.write(bw); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler serves the registry in the Prometheus text exposition format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.WriteText(w); err != nil {
+			log.Printf("Failed to write metrics: %v", err)
+		}
+	})
+}
+
+// Counter is a monotonically increasing value per combination of label
+// values.
+type Counter struct {
+	vec *vec
+}
+
+// NewCounter registers a counter with the given label names.
+func (r *Registry) NewCounter(name, help string, labelNames ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labelNames)}
+	r.register(c.vec)
+	return c
+}
+
+// Inc adds one to the counter for the given label values.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter for the given label
+// values.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("telemetry: counter cannot decrease")
+	}
+	c.vec.update(labelValues, func(s *series) { s.value += v })
+}
+
+// Gauge is a value that can go up and down per combination of label values.
+type Gauge struct {
+	vec *vec
+}
+
+// NewGauge registers a gauge with the given label names.
+func (r *Registry) NewGauge(name, help string, labelNames ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labelNames)}
+	r.register(g.vec)
+	return g
+}
+
+// Set sets the gauge for the given label values.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.vec.update(labelValues, func(s *series) { s.value = v })
+}
+
+// Histogram counts observations into cumulative buckets per combination of
+// label values.
+type Histogram struct {
+	vec *vec
+}
+
+// NewHistogram registers a histogram with the given upper bucket bounds,
+// which must be sorted in increasing order, and label names.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	v := newVec(name, help, "histogram", labelNames)
+	v.buckets = buckets
+	r.register(v)
+	return &Histogram{vec: v}
+}
+
+// Observe records one observation for the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	h.vec.update(labelValues, func(s *series) {
+		if s.counts == nil {
+			s.counts = make([]uint64, len(h.vec.buckets))
+		}
+		for i, upper := range h.vec.buckets {
+			if v <= upper {
+				s.counts[i]++
+			}
+		}
+		s.count++
+		s.value += v
+	})
+}
+
+// Sample is one value reported by a collect function.
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc registers a gauge whose samples are produced by collect each
+// time the registry is written.
+func (r *Registry) GaugeFunc(name, help string, labelNames []string, collect func() ([]Sample, error)) {
+	r.register(&funcFamily{meta: meta{name: name, help: help, kind: "gauge", labelNames: labelNames}, collect: collect})
+}
+
+// CounterFunc registers a counter whose samples are produced by collect each
+// time the registry is written.
+func (r *Registry) CounterFunc(name, help string, labelNames []string, collect func() ([]Sample, error)) {
+	r.register(&funcFamily{meta: meta{name: name, help: help, kind: "counter", labelNames: labelNames}, collect: collect})
+}
+
+// meta is the description shared by every kind of family.
+type meta struct {
+	name       string
+	help       string
+	kind       string
+	labelNames []string
+}
+
+func (m *meta) writeHeader(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", m.name, escapeHelp(m.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+}
+
+// series is the state of one combination of label values.
+type series struct {
+	labelValues []string
+	value       float64  // counter or gauge value, or histogram sum
+	count       uint64   // histogram observation count
+	counts      []uint64 // histogram cumulative bucket counts
+}
+
+// vec is a family whose series are updated by the application.
+type vec struct {
+	meta
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newVec(name, help, kind string, labelNames []string) *vec {
+	return &vec{
+		meta:   meta{name: name, help: help, kind: kind, labelNames: labelNames},
+		series: make(map[string]*series),
+	}
+}
+
+func (v *vec) name() string { return v.meta.name }
+
+func (v *vec) update(labelValues []string, fn func(*series)) {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("telemetry: %s expects %d label values, got %d", v.meta.name, len(v.labelNames), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[key] = s
+	}
+	fn(s)
+}
+
+func (v *vec) write(w *bufio.Writer) error {
+	v.mu.Lock()
+	all := make([]series, 0, len(v.series))
+	for _, s := range v.series {
+		copied := *s
+		copied.counts = append([]uint64(nil), s.counts...)
+		all = append(all, copied)
+	}
+	v.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return lessLabels(all[i].labelValues, all[j].labelValues) })
+
+	v.writeHeader(w)
+	for _, s := range all {
+		if v.kind != "histogram" {
+			writeSample(w, v.meta.name, v.labelNames, s.labelValues, s.value)
+			continue
+		}
+		names := append(append([]string(nil), v.labelNames...), "le")
+		for i, upper := range v.buckets {
+			var n uint64
+			if s.counts != nil {
+				n = s.counts[i]
+			}
+			writeSample(w, v.meta.name+"_bucket", names, append(append([]string(nil), s.labelValues...), formatFloat(upper)), float64(n))
+		}
+		writeSample(w, v.meta.name+"_bucket", names, append(append([]string(nil), s.labelValues...), "+Inf"), float64(s.count))
+		writeSample(w, v.meta.name+"_sum", v.labelNames, s.labelValues, s.value)
+		writeSample(w, v.meta.name+"_count", v.labelNames, s.labelValues, float64(s.count))
+	}
+	return nil
+}
+
+// funcFamily is a family whose samples are collected when it is written.
+type funcFamily struct {
+	meta
+	collect func() ([]Sample, error)
+}
+
+func (f *funcFamily) name() string { return f.meta.name }
+
+func (f *funcFamily) write(w *bufio.Writer) error {
+	samples, err := f.collect()
+	if err != nil {
+		// Skip the family rather than failing the whole scrape.
+		log.Printf("Failed to collect metric %s: %v", f.meta.name, err)
+		return nil
+	}
+	sort.Slice(samples, func(i, j int) bool { return lessLabels(samples[i].LabelValues, samples[j].LabelValues) })
+
+	f.writeHeader(w)
+	for _, s := range samples {
+		writeSample(w, f.meta.name, f.labelNames, s.LabelValues, s.Value)
+	}
+	return nil
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames, labelValues []string, value float64) {
+	w.WriteString(name)
+	if len(labelNames) > 0 {
+		w.WriteByte('{')
+		for i, label := range labelNames {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", label, escapeLabelValue(labelValues[i]))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string       { return helpEscaper.Replace(s) }
+func escapeLabelValue(s string) string { return labelEscaper.Replace(s) }
+
+func lessLabels(a, b []string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return len(a) < len(b)
+}
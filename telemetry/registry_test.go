@@ -0,0 +1,82 @@
+package telemetry
+
+import (
+	"bytes"
+	"database/sql"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// checkGolden compares got with testdata/name, rewriting the file instead
+// when the test runs with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output does not match %s\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestRegistryWriteText(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounter("http_requests_total", "Total HTTP requests.", "handler", "code")
+	requests.Inc("/view-products", "200")
+	requests.Inc("/view-products", "200")
+	requests.Add(3, "/create-order", "201")
+	requests.Inc(`/odd"path\`, "500")
+
+	latency := r.NewHistogram("http_request_duration_seconds", "HTTP request latency.", []float64{0.1, 0.5, 1}, "handler")
+	latency.Observe(0.05, "/view-products")
+	latency.Observe(0.3, "/view-products")
+	latency.Observe(2, "/view-products")
+
+	stock := r.NewGauge("inventory_stock_units", "Total units in stock.")
+	stock.Set(42)
+
+	r.GaugeFunc("orders", "Orders by status.\nMultiline help.", []string{"status"}, func() ([]Sample, error) {
+		return []Sample{
+			{LabelValues: []string{"Shipped"}, Value: 2},
+			{LabelValues: []string{"Pending"}, Value: 5},
+		}, nil
+	})
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	checkGolden(t, "registry.golden", buf.Bytes())
+}
+
+func TestRegisterDBStats(t *testing.T) {
+	// sql.Open does not connect, so every statistic is zero.
+	db, err := sql.Open("postgres", "postgres://localhost/unused")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	defer db.Close()
+
+	r := NewRegistry()
+	RegisterDBStats(r, db)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+	checkGolden(t, "dbstats.golden", buf.Bytes())
+}